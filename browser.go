@@ -4,19 +4,22 @@ import (
 	"bytes"
 	"context"
 	"image/png"
+	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"runtime"
-	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/chromedp/cdproto/page"
-	"github.com/chromedp/cdproto/target"
 	"github.com/chromedp/chromedp"
 	"github.com/chromedp/chromedp/device"
 	"github.com/goware/urlx"
+	"golang.org/x/net/html"
 
+	"github.com/Zyko0/go-sdl3/img"
 	"github.com/Zyko0/go-sdl3/sdl"
 )
 
@@ -50,319 +53,28 @@ type BrowserTab struct {
 
 	CreateNewTab atomic.Bool
 	NewTabURL    string
-}
-
-func NewBrowserTab(w, h int, contents *InternetContents) (*BrowserTab, error) {
-
-	if globals.BrowserContext == nil {
-
-		opts := append(
-			[]func(*chromedp.ExecAllocator){},
-			chromedp.Flag("hide-scrollbars", true), // Not sure if we want this or not
-			chromedp.Flag("headless", true),
-			chromedp.Flag("no-first-run", true),
-			chromedp.Flag("no-default-browser-check", true),
-			chromedp.Flag("mute-audio", false),
-			// chromedp.Flag("disable-background-networking", true),
-			// chromedp.Flag("enable-features", "NetworkService,NetworkServiceInProcess"),
-			chromedp.Flag("disable-background-timer-throttling", true),
-			chromedp.Flag("disable-backgrounding-occluded-windows", true),
-			chromedp.Flag("disable-renderer-backgrounding", true),
-			chromedp.Flag("disable-accelerated-2d-canvas", false),
-			// chromedp.Flag("disable-breakpad", true),
-			// chromedp.Flag("disable-client-side-phishing-detection", true),
-			// chromedp.Flag("disable-default-apps", true),
-			// chromedp.Flag("disable-dev-shm-usage", true),
-			chromedp.Flag("disable-extensions", false),
-			// // chromedp.Flag("disable-features", "site-per-process,Translate,BlinkGenPropertyTrees"),
-			// chromedp.Flag("disable-hang-monitor", true),
-			// chromedp.Flag("disable-ipc-flooding-protection", true),
-			// chromedp.Flag("disable-popup-blocking", true),
-			// chromedp.Flag("block-new-web-contents", true),
-			// chromedp.Flag("disable-prompt-on-repost", true),
-			// chromedp.Flag("disable-renderer-backgrounding", true),
-			// chromedp.Flag("disable-sync", true),
-			// chromedp.Flag("force-color-profile", "srgb"),
-			// chromedp.Flag("metrics-recording-only", true),
-			// chromedp.Flag("safebrowsing-disable-auto-update", true),
-			// chromedp.Flag("enable-automation", true),
-			// chromedp.Flag("password-store", "basic"),
-			// chromedp.Flag("use-mock-keychain", true),
-		)
-
-		if browserPath := globals.Settings.Get(SettingsBrowserPath).AsString(); browserPath != "" {
-			opts = append(opts, chromedp.ExecPath(browserPath))
-		}
-
-		if userDataPath := globals.Settings.Get(SettingsBrowserUserDataPath).AsString(); userDataPath != "" {
-			opts = append(opts, chromedp.UserDataDir(userDataPath))
-		}
-
-		alloc, _ := chromedp.NewExecAllocator(context.Background(), opts...)
-
-		// create context
-		browserContext, cancel := chromedp.NewContext(alloc)
-
-		// Try context to confirm it exists and is good
-		if err := chromedp.Run(browserContext, chromedp.Reload()); err != nil {
-			errText := err.Error()
-
-			if errText == `exec: "google-chrome": executable file not found in $PATH` {
-				errText = "Google Chrome executable not found;\nare you sure you have a recent version of Chrome (or a Chrome-based browser) installed?"
-			}
-			globals.EventLog.Log("Error creating web card: %s", true, errText)
-
-			cancel() // Cancel the broken browser context (unsure if this is strictly necessary)
-			return nil, err
-		}
-
-		globals.EventLog.Log("Created web context.", false)
-
-		globals.BrowserContext = browserContext
-
-		go func() {
-
-			for {
-
-				frameStart := time.Now()
-
-				tabs := make([]*BrowserTab, len(globals.BrowserTabs))
-
-				globals.BrowserLock.Lock()
-				copy(tabs, globals.BrowserTabs)
-				globals.BrowserLock.Unlock()
-
-				for _, browserTab := range tabs {
-
-					if quit {
-						break
-					}
-
-					deadlinedTabContext, _ := context.WithTimeout(browserTab.Context, time.Second)
-
-					if !browserTab.Initialized.Load() {
-						continue
-					}
-
-					// log.Println("loop start")
-
-					// Force refreshing for the first few seconds to hopefully ensure cards display when loading a project
-					if time.Since(browserTab.CreationTime) < time.Second*5 || browserTab.ForceRefresh.Load() {
-						browserTab.ForceRefresh.Store(false) // If we're forcing refresh, we refresh this frame, but unset it for the future
-					} else {
-
-						updateOnlyWhen := browserTab.Contents.Card.Properties.Get("update only when").AsString()
-
-						switch updateOnlyWhen {
-						case InternetCardUpdateOptionWhenRecordingInputs:
-							if !browserTab.Contents.RecordInput {
-								continue
-							}
-						case InternetCardUpdateOptionWhenSelected:
-							if !browserTab.Contents.Card.selected {
-								continue
-							}
-							// case WebCardUpdateOptionAlways:
-						}
-
-						updateFPS := browserTab.Contents.Card.Properties.Get("update framerate").AsString()
-						if updateFPS != InternetCardFPSAsOftenAsPossible {
-
-							switch updateFPS {
-							case InternetCardFPS1FPS:
-								if time.Since(browserTab.UpdateFrametime) < time.Second {
-									continue
-								}
-							case InternetCardFPS10FPS:
-								if time.Since(browserTab.UpdateFrametime) < time.Second/10 {
-									continue
-								}
-							case InternetCardFPS20FPS:
-								if time.Since(browserTab.UpdateFrametime) < time.Second/20 {
-									continue
-								}
-							}
-
-						}
-
-					}
-
-					if browserTab.Target != nil {
-						chromedp.Run(deadlinedTabContext, target.ActivateTarget(browserTab.Target.TargetID))
-					}
-
-					// log.Println("action start")
-
-					// c := chromedp.FromContext(tab.Context)
-					// chromedp.Run(deadlinedTabContext, target.ActivateTarget(c.Target.TargetID))
-
-					// for len(tab.Actions) > 0 { // This is laggier when it comes to selecting text for some reason...?
-
-					var actionError error
 
-					for len(browserTab.Actions) > 0 {
-
-						action := <-browserTab.Actions
-
-						err := chromedp.Run(deadlinedTabContext, action)
-
-						if err == context.Canceled {
-							actionError = err
-							break
-						} else if err != nil && err != context.DeadlineExceeded {
-							actionError = err
-							globals.EventLog.Log("error: %s", false, err.Error())
-						}
-
-					}
-
-					if actionError != nil {
-						continue
-					}
-
-					browserTab.UpdateFrametime = time.Now()
-
-					// log.Println("take picture")
-
-					err := chromedp.Run(deadlinedTabContext, chromedp.CaptureScreenshot(&browserTab.ImageBuffer))
-
-					if err == context.Canceled {
-						break
-					} else if err != nil && err != context.DeadlineExceeded {
-						globals.EventLog.Log("error: %s", false, err.Error())
-					} else if err == nil {
-
-						decoded, err := png.Decode(bytes.NewReader(browserTab.ImageBuffer))
-
-						if err != nil {
-							globals.EventLog.Log(err.Error(), true)
-							break
-						}
-
-						browserTab.Pause.Lock()
-
-						imgWidth := decoded.Bounds().Dx()
-						imgHeight := decoded.Bounds().Dy()
-
-						waitGroup := &sync.WaitGroup{}
-
-						decode := func(wg *sync.WaitGroup, yStart, yEnd int) {
-
-							defer wg.Done()
-
-							i := yStart * imgWidth * 4
-
-							for y := yStart; y < yEnd; y++ {
-								for x := 0; x < decoded.Bounds().Dx(); x++ {
-									r, g, b, a := decoded.At(x, y).RGBA()
-									if i >= len(browserTab.RawImage) {
-										return
-									}
-									browserTab.RawImage[i] = byte(a)
-									browserTab.RawImage[i+1] = byte(b)
-									browserTab.RawImage[i+2] = byte(g)
-									browserTab.RawImage[i+3] = byte(r)
-									i += 4
-								}
-							}
-
-						}
-
-						chunkSize := runtime.NumCPU()
-						if chunkSize < 1 {
-							chunkSize = 1
-						}
-
-						imgChunkHeight := float32(imgHeight) / float32(chunkSize)
-
-						for i := float32(0); i < float32(chunkSize); i++ {
-							waitGroup.Add(1)
-							go decode(waitGroup, int(i*imgChunkHeight), int(imgChunkHeight*(i+1)))
-						}
-
-						waitGroup.Wait()
-
-						browserTab.Pause.Unlock()
-
-						browserTab.ImageChanged.Store(true)
-
-						if time.Now().After(browserTab.urlTime) {
-							currentLocation := ""
-
-							// log.Println("get url time")
-
-							// If this errors out, it's nbd; just try again later
-							err := chromedp.Run(deadlinedTabContext, chromedp.Location(&currentLocation))
-
-							if err == context.Canceled {
-								break
-							} else if err != nil && err != context.DeadlineExceeded {
-								globals.EventLog.Log("error: %s", false, err.Error())
-							}
-
-							if currentLocation != "" {
-								browserTab.CurrentURL = currentLocation
-								browserTab.urlTime = time.Now().Add(time.Second / 4)
-							}
-						}
-
-					}
+	Backend BrowserBackend
 
-				}
-
-				if quit {
-					return
-				}
-
-				// Sleep for one frame max, but subtract the time since the frame start
-				time.Sleep((time.Second / time.Duration(targetFPS)) - time.Since(frameStart))
-
-			}
-
-		}()
-
-		chromedp.WaitNewTarget(globals.BrowserContext, func(i *target.Info) bool {
-
-			ctx, _ := chromedp.NewContext(globals.BrowserContext, chromedp.WithTargetID(i.TargetID))
-			if err := chromedp.Run(ctx, page.Close()); err != nil {
-				log.Println(err)
-			}
-
-			if i.URL != "" {
-
-				for _, card := range globals.Project.CurrentPage.Cards {
-					if card.selected && card.ContentType == ContentTypeInternet {
-						browserTab := card.Contents.(*InternetContents).BrowserTab
-						browserTab.CreateNewTab.Store(true)
-						browserTab.NewTabURL = i.URL
-						break
-					}
-				}
-
-			}
-
-			// id := i.TargetID
-
-			// ctx, _ := chromedp.NewContext(globals.BrowserContext, chromedp.WithTargetID(id))
-
-			// intendedLocation := ""
-
-			// if err := chromedp.Run(ctx, chromedp.Location(&intendedLocation), page.Close()); err != nil {
-			// 	log.Println(err)
-			// }
-
-			// ctxID := fmt.Sprintf("%p", browserTab.Context)
-
-			// fmt.Println("new target", ctxID, intendedLocation)
+	sessionLock    sync.Mutex
+	scroll         BrowserScroll // The page's scroll position as of the last check by the rendering loop
+	history        []string      // The navigation history as of the last check by the rendering loop
+	historyIndex   int
+	restoreURL     string // The page to restore the scroll position of once it's finished loading
+	restoreScroll  []float64
+	sessionHistory []string // A restored navigation history, which the tab moves through itself while on one of its pages
+	sessionIndex   int
+}
 
-			// if intendedLocation != "" {
-			// 	browserTab.NewTabURL = intendedLocation
-			// 	browserTab.CreateNewTab.Store(true)
-			// }
+// BrowserSessionHistoryLimit is how many pages of a tab's navigation history are kept in its saved session.
+const BrowserSessionHistoryLimit = 20
 
-			return false
-		})
+func NewBrowserTab(w, h int, contents *InternetContents) (*BrowserTab, error) {
 
+	// The rendering loop handles the tabs of every browser backend, so it only needs to be started once.
+	if !browserRenderLoopStarted {
+		browserRenderLoopStarted = true
+		go browserRenderLoop()
 	}
 
 	browserTab := &BrowserTab{
@@ -372,34 +84,13 @@ func NewBrowserTab(w, h int, contents *InternetContents) (*BrowserTab, error) {
 		CreationTime: time.Now(),
 	}
 
-	newTab, _ := chromedp.NewContext(globals.BrowserContext)
-	// newTab, cancel := chromedp.NewContext(globals.BrowserContext)
-
-	// Attempt to run something; this should create a new tab
-	if err := chromedp.Run(newTab, chromedp.Reload()); err != nil {
+	backend, err := NewBrowserBackend(browserTab)
+	if err != nil {
 		globals.EventLog.Log("Error creating web card: %s", true, err.Error())
 		return nil, err
 	}
 
-	browserTab.Target = chromedp.FromContext(newTab).Target
-
-	chromedp.ListenTarget(newTab, func(ev interface{}) {
-		switch e := ev.(type) {
-
-		case *page.EventLifecycleEvent:
-			if e.Name == "DOMContentLoaded" {
-				browserTab.UpdateZoom()
-				browserTab.LoadingWebpage.Store(true)
-			}
-			if e.Name == "networkIdle" {
-				browserTab.LoadingWebpage.Store(false)
-			}
-		}
-	})
-
-	// browserTab.CancelFunc = cancel
-
-	browserTab.Context = newTab
+	browserTab.Backend = backend
 
 	browserTab.UpdateBufferSize(w, h)
 
@@ -598,6 +289,221 @@ func NewBrowserTab(w, h int, contents *InternetContents) (*BrowserTab, error) {
 
 }
 
+var browserRenderLoopStarted = false
+
+// browserRenderLoop runs the queued actions for each browser tab and renders them, according to their cards' update
+// settings.
+func browserRenderLoop() {
+
+	for {
+
+		frameStart := time.Now()
+
+		tabs := make([]*BrowserTab, len(globals.BrowserTabs))
+
+		globals.BrowserLock.Lock()
+		copy(tabs, globals.BrowserTabs)
+		globals.BrowserLock.Unlock()
+
+		for _, browserTab := range tabs {
+
+			if quit {
+				break
+			}
+
+			deadlinedTabContext, _ := context.WithTimeout(browserTab.Context, time.Second)
+
+			if !browserTab.Initialized.Load() {
+				continue
+			}
+
+			// log.Println("loop start")
+
+			// Force refreshing for the first few seconds to hopefully ensure cards display when loading a project
+			if time.Since(browserTab.CreationTime) < time.Second*5 || browserTab.ForceRefresh.Load() {
+				browserTab.ForceRefresh.Store(false) // If we're forcing refresh, we refresh this frame, but unset it for the future
+			} else {
+
+				updateOnlyWhen := browserTab.Contents.Card.Properties.Get("update only when").AsString()
+
+				switch updateOnlyWhen {
+				case InternetCardUpdateOptionWhenRecordingInputs:
+					if !browserTab.Contents.RecordInput {
+						continue
+					}
+				case InternetCardUpdateOptionWhenSelected:
+					if !browserTab.Contents.Card.selected {
+						continue
+					}
+					// case WebCardUpdateOptionAlways:
+				}
+
+				updateFPS := browserTab.Contents.Card.Properties.Get("update framerate").AsString()
+				if updateFPS != InternetCardFPSAsOftenAsPossible {
+
+					switch updateFPS {
+					case InternetCardFPS1FPS:
+						if time.Since(browserTab.UpdateFrametime) < time.Second {
+							continue
+						}
+					case InternetCardFPS10FPS:
+						if time.Since(browserTab.UpdateFrametime) < time.Second/10 {
+							continue
+						}
+					case InternetCardFPS20FPS:
+						if time.Since(browserTab.UpdateFrametime) < time.Second/20 {
+							continue
+						}
+					}
+
+				}
+
+			}
+
+			// log.Println("action start")
+
+			// c := chromedp.FromContext(tab.Context)
+			// chromedp.Run(deadlinedTabContext, target.ActivateTarget(c.Target.TargetID))
+
+			// for len(tab.Actions) > 0 { // This is laggier when it comes to selecting text for some reason...?
+
+			var actionError error
+
+			for len(browserTab.Actions) > 0 {
+
+				action := <-browserTab.Actions
+
+				err := browserTab.Backend.Run(deadlinedTabContext, action)
+
+				if err == context.Canceled {
+					actionError = err
+					break
+				} else if err != nil && err != context.DeadlineExceeded {
+					actionError = err
+					globals.EventLog.Log("error: %s", false, err.Error())
+				}
+
+			}
+
+			if actionError != nil {
+				continue
+			}
+
+			browserTab.UpdateFrametime = time.Now()
+
+			// log.Println("take picture")
+
+			frame, err := browserTab.Backend.RenderFrame(deadlinedTabContext)
+
+			if err == context.Canceled {
+				break
+			} else if err != nil && err != context.DeadlineExceeded {
+				globals.EventLog.Log("error: %s", false, err.Error())
+			} else if err == nil {
+
+				browserTab.ImageBuffer = frame
+
+				decoded, err := png.Decode(bytes.NewReader(browserTab.ImageBuffer))
+
+				if err != nil {
+					globals.EventLog.Log(err.Error(), true)
+					break
+				}
+
+				browserTab.Pause.Lock()
+
+				imgWidth := decoded.Bounds().Dx()
+				imgHeight := decoded.Bounds().Dy()
+
+				waitGroup := &sync.WaitGroup{}
+
+				decode := func(wg *sync.WaitGroup, yStart, yEnd int) {
+
+					defer wg.Done()
+
+					i := yStart * imgWidth * 4
+
+					for y := yStart; y < yEnd; y++ {
+						for x := 0; x < decoded.Bounds().Dx(); x++ {
+							r, g, b, a := decoded.At(x, y).RGBA()
+							if i >= len(browserTab.RawImage) {
+								return
+							}
+							browserTab.RawImage[i] = byte(a)
+							browserTab.RawImage[i+1] = byte(b)
+							browserTab.RawImage[i+2] = byte(g)
+							browserTab.RawImage[i+3] = byte(r)
+							i += 4
+						}
+					}
+
+				}
+
+				chunkSize := runtime.NumCPU()
+				if chunkSize < 1 {
+					chunkSize = 1
+				}
+
+				imgChunkHeight := float32(imgHeight) / float32(chunkSize)
+
+				for i := float32(0); i < float32(chunkSize); i++ {
+					waitGroup.Add(1)
+					go decode(waitGroup, int(i*imgChunkHeight), int(imgChunkHeight*(i+1)))
+				}
+
+				waitGroup.Wait()
+
+				browserTab.Pause.Unlock()
+
+				browserTab.ImageChanged.Store(true)
+
+				if time.Now().After(browserTab.urlTime) {
+					// log.Println("get url time")
+
+					// If this errors out, it's nbd; just try again later
+					currentLocation, err := browserTab.Backend.Location(deadlinedTabContext)
+
+					if err == context.Canceled {
+						break
+					} else if err != nil && err != context.DeadlineExceeded {
+						globals.EventLog.Log("error: %s", false, err.Error())
+					}
+
+					if currentLocation != "" {
+						browserTab.CurrentURL = currentLocation
+						browserTab.urlTime = time.Now().Add(time.Second / 4)
+					}
+
+					if scroll, err := browserTab.Backend.Scroll(deadlinedTabContext); err == nil {
+						browserTab.sessionLock.Lock()
+						browserTab.scroll = scroll
+						browserTab.sessionLock.Unlock()
+					}
+
+					// The history is kept here so that saving the project can read it without waiting on the browser
+					if history, index, err := browserTab.Backend.History(deadlinedTabContext); err == nil {
+						browserTab.sessionLock.Lock()
+						browserTab.history = history
+						browserTab.historyIndex = index
+						browserTab.sessionLock.Unlock()
+					}
+				}
+
+			}
+
+		}
+
+		if quit {
+			return
+		}
+
+		// Sleep for one frame max, but subtract the time since the frame start
+		time.Sleep((time.Second / time.Duration(targetFPS)) - time.Since(frameStart))
+
+	}
+
+}
+
 func (b *BrowserTab) Destroy() {
 
 	globals.BrowserLock.Lock()
@@ -609,10 +515,7 @@ func (b *BrowserTab) Destroy() {
 	}
 	globals.BrowserLock.Unlock()
 
-	err := chromedp.Cancel(b.Context)
-	if err != nil {
-		log.Println(err)
-	}
+	b.Backend.Close()
 	b.ImageTexture.Destroy()
 	b.ToRemove = true
 
@@ -666,7 +569,9 @@ func (b *BrowserTab) UpdateBufferSize(w, h int) {
 
 	b.ImageTexture.SetScaleMode(sdl.SCALEMODE_LINEAR)
 
-	b.Do(chromedp.Emulate(b.DeviceInfo))
+	b.Do(BackendAction(func(ctx context.Context) error {
+		return b.Backend.Resize(ctx, w, h)
+	}))
 
 }
 
@@ -701,6 +606,10 @@ func (b *BrowserTab) UpdateTexture() {
 
 func (b *BrowserTab) NavigateBack() {
 
+	if b.travelSession(-1) {
+		return
+	}
+
 	// Some sites hang indefinitely with chromedp.NavigateBack() - see issue: https://github.com/chromedp/chromedp/issues/1346
 	// entries := []*page.NavigationEntry{}
 	// currentEntry := int64(0)
@@ -709,18 +618,27 @@ func (b *BrowserTab) NavigateBack() {
 	// 	b.Do(chromedp.NavigateBack())
 	// }
 
-	b.Do(chromedp.EvaluateAsDevTools("history.back()", nil))
+	b.Do(BackendAction(func(ctx context.Context) error {
+		return b.Backend.Travel(ctx, -1)
+	}))
 
 }
 
 func (b *BrowserTab) NavigateForward() {
+
+	if b.travelSession(1) {
+		return
+	}
+
 	// entries := []*page.NavigationEntry{}
 	// currentEntry := int64(0)
 	// chromedp.Run(b.Context, chromedp.NavigationEntries(&currentEntry, &entries))
 	// if int(currentEntry) >= 1 {
 	// 	b.Do(chromedp.NavigateBack())
 	// }
-	b.Do(chromedp.EvaluateAsDevTools("history.forward()", nil))
+	b.Do(BackendAction(func(ctx context.Context) error {
+		return b.Backend.Travel(ctx, 1)
+	}))
 
 }
 
@@ -732,7 +650,9 @@ func (b *BrowserTab) Navigate(url string) {
 	log.Println("URL Parsed:", url)
 
 	if err == nil {
-		b.Do(chromedp.Navigate(parsed.String()))
+		b.Do(BackendAction(func(ctx context.Context) error {
+			return b.Backend.Navigate(ctx, parsed.String())
+		}))
 	} else {
 		globals.EventLog.Log("Error navigating to website: [ %s ];\nAre you sure the website URL is correct?\nError: [ %s ]", true, url, err.Error())
 		b.LoadingWebpage.Store(false)
@@ -748,6 +668,334 @@ func (b *BrowserTab) Valid() bool {
 }
 
 func (b *BrowserTab) UpdateZoom() {
-	zoomLevel := strconv.FormatFloat(globals.Settings.Get(SettingsWebCardZoomLevel).AsFloat(), 'f', 2, 64)
-	b.Do(chromedp.Evaluate("document.body.style.zoom = "+zoomLevel+";", nil))
+	zoomLevel := b.Contents.ZoomLevel()
+	b.Do(BackendAction(func(ctx context.Context) error {
+		return b.Backend.Zoom(ctx, zoomLevel)
+	}))
+}
+
+func (b *BrowserTab) Reload() {
+	b.Do(BackendAction(b.Backend.Reload))
+}
+
+// Scroll returns the page's scroll position as of the last time the rendering loop checked it.
+func (b *BrowserTab) Scroll() BrowserScroll {
+	b.sessionLock.Lock()
+	defer b.sessionLock.Unlock()
+	return b.scroll
+}
+
+// ScrollToFraction scrolls the page to the given fraction of the way along it; a negative fraction leaves that axis
+// where it is.
+func (b *BrowserTab) ScrollToFraction(fractionX, fractionY float64) {
+
+	b.Do(BackendAction(func(ctx context.Context) error {
+
+		scroll, err := b.Backend.Scroll(ctx)
+		if err != nil {
+			return err
+		}
+
+		x, y := scroll.Position(fractionX, fractionY)
+
+		if fractionX < 0 {
+			x = scroll.X
+		}
+
+		if fractionY < 0 {
+			y = scroll.Y
+		}
+
+		return b.Backend.ScrollTo(ctx, x, y)
+
+	}))
+
+}
+
+// SessionState returns the tab's navigation history, the index of the current entry in it, and the current page's
+// scroll position, as of the last time the rendering loop checked them.
+func (b *BrowserTab) SessionState() (history []string, index int, scrollX, scrollY float64) {
+
+	b.sessionLock.Lock()
+	defer b.sessionLock.Unlock()
+
+	history = append([]string{}, b.history...)
+	index = b.historyIndex
+
+	// While the tab's still within a restored history, that's its history rather than the browser's own
+	if len(b.sessionHistory) > 0 && b.CurrentURL == b.sessionHistory[b.sessionIndex] {
+		history = append([]string{}, b.sessionHistory...)
+		index = b.sessionIndex
+	}
+
+	if len(history) > BrowserSessionHistoryLimit {
+		start := max(index-(BrowserSessionHistoryLimit-1), 0)
+		history = history[start:min(start+BrowserSessionHistoryLimit, len(history))]
+		index -= start
+	}
+
+	// A scroll position that's still waiting to be restored hasn't been lost yet
+	if b.restoreURL != "" {
+		return history, index, b.restoreScroll[0], b.restoreScroll[1]
+	}
+
+	return history, index, b.scroll.X, b.scroll.Y
+
+}
+
+// RestoreSession goes to the page at the given index in the given history, and then scrolls it back to the given
+// position once it's loaded. Only that page is loaded; the rest of the history is kept so that navigating back and
+// forward from it moves through the history as it was before.
+func (b *BrowserTab) RestoreSession(history []string, index int, scrollX, scrollY float64) {
+
+	if len(history) == 0 {
+		return
+	}
+
+	index = min(max(index, 0), len(history)-1)
+
+	b.Navigate(history[index])
+
+	b.sessionLock.Lock()
+	b.sessionHistory = history
+	b.sessionIndex = index
+	b.restoreURL = history[index]
+	b.restoreScroll = []float64{scrollX, scrollY}
+	b.sessionLock.Unlock()
+
+}
+
+// travelSession moves through the restored history by the given offset. It returns false if there's no restored
+// history or the tab has navigated away from it, in which case the browser's own history should be used instead.
+func (b *BrowserTab) travelSession(offset int) bool {
+
+	b.sessionLock.Lock()
+
+	if len(b.sessionHistory) == 0 {
+		b.sessionLock.Unlock()
+		return false
+	}
+
+	if b.CurrentURL != b.sessionHistory[b.sessionIndex] {
+		b.sessionHistory = nil
+		b.sessionLock.Unlock()
+		return false
+	}
+
+	index := b.sessionIndex + offset
+
+	// The browser's own history doesn't go past the ends of the restored history either
+	if index < 0 || index >= len(b.sessionHistory) {
+		b.sessionLock.Unlock()
+		return true
+	}
+
+	b.sessionIndex = index
+	url := b.sessionHistory[index]
+
+	b.sessionLock.Unlock()
+
+	b.Navigate(url)
+
+	return true
+
+}
+
+func (b *BrowserTab) restorePendingScroll() {
+
+	b.sessionLock.Lock()
+	url := b.restoreURL
+	scroll := b.restoreScroll
+	b.sessionLock.Unlock()
+
+	if url == "" {
+		return
+	}
+
+	b.Do(BackendAction(func(ctx context.Context) error {
+
+		// Only scroll if the page that just finished loading is the one that was being restored, rather than the
+		// blank page the tab starts on.
+		location, err := b.Backend.Location(ctx)
+		if err != nil || location != url {
+			return err
+		}
+
+		if err := b.Backend.ScrollTo(ctx, scroll[0], scroll[1]); err != nil {
+			return err
+		}
+
+		b.sessionLock.Lock()
+		b.restoreURL = ""
+		b.restoreScroll = nil
+		b.sessionLock.Unlock()
+
+		return nil
+
+	}))
+
+}
+
+// Favicon downloads the icon for a website in the background and turns it into a texture once it's available.
+type Favicon struct {
+	Origin  string
+	data    chan []byte
+	texture *sdl.Texture
+}
+
+// NewFavicon downloads the icon for the website that the given page is on. The icon linked to by the page itself is
+// used if there is one, and otherwise the site's /favicon.ico.
+func NewFavicon(pageURL string) *Favicon {
+
+	favicon := &Favicon{
+		Origin: URLOrigin(pageURL),
+		data:   make(chan []byte, 1),
+	}
+
+	if favicon.Origin != "" {
+
+		if !strings.Contains(pageURL, "://") {
+			pageURL = "https://" + pageURL
+		}
+
+		go func() {
+
+			iconURL := favicon.Origin + "/favicon.ico"
+
+			if linked := findIconLink(pageURL); linked != "" {
+				iconURL = linked
+			}
+
+			resp, err := globals.HTTPClient.Get(iconURL)
+			if err != nil {
+				log.Println("error downloading favicon:", err)
+				return
+			}
+
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			if data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024)); err == nil {
+				favicon.data <- data
+			}
+
+		}()
+
+	}
+
+	return favicon
+
+}
+
+// findIconLink returns the address of the first icon linked to (with <link rel="icon">) by the given page, or an empty
+// string if it doesn't link to one. Icons served over http aren't used for pages served over https.
+func findIconLink(pageURL string) string {
+
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+
+	resp, err := globals.HTTPClient.Get(parsed.String())
+	if err != nil {
+		return ""
+	}
+
+	defer resp.Body.Close()
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return ""
+	}
+
+	var find func(n *html.Node) string
+
+	find = func(n *html.Node) string {
+
+		if n.Type == html.ElementNode && n.Data == "link" {
+
+			rel, href := "", ""
+
+			for _, attr := range n.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "rel":
+					rel = attr.Val
+				case "href":
+					href = attr.Val
+				}
+			}
+
+			for _, token := range strings.Fields(strings.ToLower(rel)) {
+
+				if token != "icon" || href == "" {
+					continue
+				}
+
+				// Resolve against the final page, in case the request was redirected
+				icon, err := resp.Request.URL.Parse(href)
+				if err == nil && (icon.Scheme == "https" || (icon.Scheme == "http" && resp.Request.URL.Scheme == "http")) {
+					return icon.String()
+				}
+
+			}
+
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if found := find(child); found != "" {
+				return found
+			}
+		}
+
+		return ""
+
+	}
+
+	return find(doc)
+
+}
+
+// Texture returns the Favicon's texture, or nil if it hasn't been downloaded (or couldn't be).
+func (favicon *Favicon) Texture() *sdl.Texture {
+
+	select {
+	case data := <-favicon.data:
+		if stream, err := sdl.IOFromBytes(data); err == nil {
+			if tex, err := img.LoadTextureIO(globals.Renderer, stream, true); err == nil {
+				tex.SetScaleMode(sdl.SCALEMODE_LINEAR)
+				favicon.texture = tex
+			}
+		}
+	default:
+	}
+
+	return favicon.texture
+
+}
+
+func (favicon *Favicon) Destroy() {
+	if favicon.texture != nil {
+		favicon.texture.Destroy()
+		favicon.texture = nil
+	}
+}
+
+// URLOrigin returns the scheme and host of the given web address (which is assumed to be https if it doesn't have a
+// scheme), or an empty string if it isn't one.
+func URLOrigin(pageURL string) string {
+
+	if !strings.Contains(pageURL, "://") {
+		pageURL = "https://" + pageURL
+	}
+
+	parsed, err := url.Parse(pageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+
+	return parsed.Scheme + "://" + parsed.Host
+
 }
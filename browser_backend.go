@@ -0,0 +1,842 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/net/html"
+)
+
+// BrowserBackend is what a BrowserTab uses to load, display, and interact with web pages.
+// Run() is called from the browser rendering loop for each action queued with BrowserTab.Do(); the other functions
+// are called either from the rendering loop directly, or through BackendActions. Backends that can't run an action
+// return ErrUnsupportedByBackend rather than ignoring it.
+type BrowserBackend interface {
+	Run(ctx context.Context, action chromedp.Action) error
+	Navigate(ctx context.Context, url string) error
+	Travel(ctx context.Context, offset int) error // Moves through the navigation history by the given offset
+	Resize(ctx context.Context, w, h int) error
+	RenderFrame(ctx context.Context) ([]byte, error) // Returns the current view as a PNG
+	Location(ctx context.Context) (string, error)
+	Text(ctx context.Context, selector string) (string, error) // Returns the page's visible text, or that of the element matching the CSS selector
+	Reload(ctx context.Context) error
+	Zoom(ctx context.Context, level float64) error
+	Scroll(ctx context.Context) (BrowserScroll, error)
+	ScrollTo(ctx context.Context, x, y float64) error
+	History(ctx context.Context) ([]string, int, error) // Returns the navigation history and the index of the current page in it
+	Interactive() bool                                  // Whether clicks, key presses, editing commands, and selectors reach the page itself
+	Close()
+}
+
+var ErrUnsupportedByBackend = errors.New("not supported by the text-only browser")
+
+// BrowserScroll is the scroll position of a page, along with the size of the page and of the view onto it.
+type BrowserScroll struct {
+	X, Y                  float64
+	PageWidth, PageHeight float64
+	ViewWidth, ViewHeight float64
+}
+
+// Fraction returns how far along the page the view is scrolled on each axis, from 0 to 1.
+func (scroll BrowserScroll) Fraction() (float64, float64) {
+
+	x, y := 0.0, 0.0
+
+	if scroll.PageWidth > scroll.ViewWidth {
+		x = scroll.X / (scroll.PageWidth - scroll.ViewWidth)
+	}
+
+	if scroll.PageHeight > scroll.ViewHeight {
+		y = scroll.Y / (scroll.PageHeight - scroll.ViewHeight)
+	}
+
+	return x, y
+
+}
+
+// Position returns the scroll position that's the given fraction of the way along the page on each axis.
+func (scroll BrowserScroll) Position(fractionX, fractionY float64) (float64, float64) {
+	return max(fractionX*(scroll.PageWidth-scroll.ViewWidth), 0), max(fractionY*(scroll.PageHeight-scroll.ViewHeight), 0)
+}
+
+// BackendAction is an action that can be queued with BrowserTab.Do() and that any backend can run.
+type BackendAction func(ctx context.Context) error
+
+func (a BackendAction) Do(ctx context.Context) error {
+	return a(ctx)
+}
+
+func NewBrowserBackend(tab *BrowserTab) (BrowserBackend, error) {
+
+	switch globals.Settings.Get(SettingsBrowserBackend).AsString() {
+
+	case BrowserBackendText:
+		return newTextBackend(tab), nil
+
+	case BrowserBackendRemote:
+
+		remoteURL := strings.TrimSpace(globals.Settings.Get(SettingsBrowserRemoteURL).AsString())
+
+		if remoteURL == "" {
+			return nil, errors.New("no remote debugging URL has been set in the settings")
+		}
+
+		return newChromeBackend(tab, "remote:"+remoteURL, false, func() context.Context {
+			alloc, _ := chromedp.NewRemoteAllocator(context.Background(), remoteURL)
+			return alloc
+		})
+
+	}
+
+	userDataPath := tab.Contents.BrowserProfileDirectory()
+	browserPath := globals.Settings.Get(SettingsBrowserPath).AsString()
+
+	backend, err := newChromeBackend(tab, userDataPath, true, func() context.Context {
+
+		opts := append(
+			[]func(*chromedp.ExecAllocator){},
+			chromedp.Flag("hide-scrollbars", true), // Not sure if we want this or not
+			chromedp.Flag("headless", true),
+			chromedp.Flag("no-first-run", true),
+			chromedp.Flag("no-default-browser-check", true),
+			chromedp.Flag("mute-audio", false),
+			// chromedp.Flag("disable-background-networking", true),
+			// chromedp.Flag("enable-features", "NetworkService,NetworkServiceInProcess"),
+			chromedp.Flag("disable-background-timer-throttling", true),
+			chromedp.Flag("disable-backgrounding-occluded-windows", true),
+			chromedp.Flag("disable-renderer-backgrounding", true),
+			chromedp.Flag("disable-accelerated-2d-canvas", false),
+			chromedp.Flag("disable-extensions", false),
+		)
+
+		if browserPath != "" {
+			opts = append(opts, chromedp.ExecPath(browserPath))
+		}
+
+		if userDataPath != "" {
+			opts = append(opts, chromedp.UserDataDir(userDataPath))
+		}
+
+		alloc, _ := chromedp.NewExecAllocator(context.Background(), opts...)
+		return alloc
+
+	})
+
+	if err != nil {
+
+		errText := err.Error()
+
+		if errText == `exec: "google-chrome": executable file not found in $PATH` {
+			errText = "Google Chrome executable not found;\nare you sure you have a recent version of Chrome (or a Chrome-based browser) installed?"
+		}
+
+		// Only report the failure the first time, rather than for every web card that falls back because of it
+		if !errors.Is(err, errBrowserLaunchFailed) {
+			globals.EventLog.Log("Error creating web context: %s\nFalling back to displaying web pages as text only.", true, errText)
+		}
+
+		return newTextBackend(tab), nil
+
+	}
+
+	return backend, nil
+
+}
+
+// chromeBackend drives a tab in a Chrome-based browser, either one started locally or one connected to through a
+// remote debugging URL.
+type chromeBackend struct {
+	Tab        *BrowserTab
+	ContextKey string
+}
+
+// errBrowserLaunchFailed wraps the error from a browser that couldn't be started or connected to, for the cards that
+// try to use it afterward.
+var errBrowserLaunchFailed = errors.New("the browser couldn't be started")
+
+// browserLaunchErrors holds why starting (or connecting to) a browser failed, keyed like globals.BrowserContexts, so
+// that every web card doesn't wait on trying it again.
+var browserLaunchErrors = map[string]error{}
+
+// activeBrowserTargets is the tab that was last brought to the front in each browser context; it's only used from the
+// browser rendering loop.
+var activeBrowserTargets = map[string]target.ID{}
+
+// newChromeBackend creates a new tab in the browser context stored under the given key, creating the browser context
+// using the allocator returned by newAllocator if it doesn't exist yet. local is whether the browser is started by
+// MasterPlan, in which case failing to start it again with the same browser executable isn't retried.
+func newChromeBackend(tab *BrowserTab, contextKey string, local bool, newAllocator func() context.Context) (*chromeBackend, error) {
+
+	if globals.BrowserContexts == nil {
+		globals.BrowserContexts = map[string]context.Context{}
+	}
+
+	launchKey := contextKey
+	if local {
+		launchKey += "|" + globals.Settings.Get(SettingsBrowserPath).AsString()
+	}
+
+	if err, failed := browserLaunchErrors[launchKey]; failed {
+		return nil, fmt.Errorf("%w: %w", errBrowserLaunchFailed, err)
+	}
+
+	browserContext, exists := globals.BrowserContexts[contextKey]
+
+	if !exists {
+
+		var cancel context.CancelFunc
+		browserContext, cancel = chromedp.NewContext(newAllocator())
+
+		// Try context to confirm it exists and is good
+		if err := chromedp.Run(browserContext, chromedp.Reload()); err != nil {
+			cancel() // Cancel the broken browser context (unsure if this is strictly necessary)
+			browserLaunchErrors[launchKey] = err
+			return nil, err
+		}
+
+		globals.EventLog.Log("Created web context.", false)
+
+		globals.BrowserContexts[contextKey] = browserContext
+
+		chromedp.WaitNewTarget(browserContext, func(i *target.Info) bool {
+
+			ctx, _ := chromedp.NewContext(browserContext, chromedp.WithTargetID(i.TargetID))
+			if err := chromedp.Run(ctx, page.Close()); err != nil {
+				log.Println(err)
+			}
+
+			if i.URL != "" {
+
+				for _, card := range globals.Project.CurrentPage.Cards {
+					if card.selected && card.ContentType == ContentTypeInternet && card.Contents.(*InternetContents).BrowserTab != nil {
+						browserTab := card.Contents.(*InternetContents).BrowserTab
+						browserTab.CreateNewTab.Store(true)
+						browserTab.NewTabURL = i.URL
+						break
+					}
+				}
+
+			}
+
+			return false
+		})
+
+	}
+
+	newTab, _ := chromedp.NewContext(browserContext)
+
+	// Attempt to run something; this should create a new tab
+	if err := chromedp.Run(newTab, chromedp.Reload()); err != nil {
+		return nil, err
+	}
+
+	tab.Target = chromedp.FromContext(newTab).Target
+
+	chromedp.ListenTarget(newTab, func(ev interface{}) {
+		switch e := ev.(type) {
+
+		case *page.EventLifecycleEvent:
+			if e.Name == "DOMContentLoaded" {
+				tab.UpdateZoom()
+				tab.LoadingWebpage.Store(true)
+			}
+			if e.Name == "networkIdle" {
+				tab.LoadingWebpage.Store(false)
+				tab.restorePendingScroll()
+			}
+		}
+	})
+
+	tab.Context = newTab
+
+	return &chromeBackend{Tab: tab, ContextKey: contextKey}, nil
+
+}
+
+func (c *chromeBackend) Run(ctx context.Context, action chromedp.Action) error {
+	return chromedp.Run(ctx, action)
+}
+
+func (c *chromeBackend) Navigate(ctx context.Context, url string) error {
+	return chromedp.Run(ctx, chromedp.Navigate(url))
+}
+
+func (c *chromeBackend) Travel(ctx context.Context, offset int) error {
+	// Some sites hang indefinitely with chromedp.NavigateBack() - see issue: https://github.com/chromedp/chromedp/issues/1346
+	return chromedp.Run(ctx, chromedp.EvaluateAsDevTools("history.go("+strconv.Itoa(offset)+")", nil))
+}
+
+func (c *chromeBackend) Resize(ctx context.Context, w, h int) error {
+	return chromedp.Run(ctx, chromedp.Emulate(c.Tab.DeviceInfo))
+}
+
+func (c *chromeBackend) RenderFrame(ctx context.Context) ([]byte, error) {
+
+	// Tabs in the background might not render, so bring this one to the front, but only when it isn't already (as a
+	// remote browser would otherwise keep switching between its tabs)
+	if c.Tab.Target != nil && activeBrowserTargets[c.ContextKey] != c.Tab.Target.TargetID {
+		if err := chromedp.Run(ctx, target.ActivateTarget(c.Tab.Target.TargetID)); err != nil {
+			return nil, err
+		}
+		activeBrowserTargets[c.ContextKey] = c.Tab.Target.TargetID
+	}
+
+	buffer := []byte{}
+	err := chromedp.Run(ctx, chromedp.CaptureScreenshot(&buffer))
+	return buffer, err
+
+}
+
+func (c *chromeBackend) Location(ctx context.Context) (string, error) {
+	location := ""
+	err := chromedp.Run(ctx, chromedp.Location(&location))
+	return location, err
+}
+
+func (c *chromeBackend) Text(ctx context.Context, selector string) (string, error) {
+
+	text := ""
+
+	js := `(function() {
+		var el = ` + strconv.Quote(selector) + ` ? document.querySelector(` + strconv.Quote(selector) + `) : document.body;
+		return el ? el.innerText : "";
+	})()`
+
+	err := chromedp.Run(ctx, chromedp.Evaluate(js, &text))
+	return text, err
+
+}
+
+func (c *chromeBackend) Reload(ctx context.Context) error {
+	return chromedp.Run(ctx, chromedp.Reload())
+}
+
+func (c *chromeBackend) Zoom(ctx context.Context, level float64) error {
+	return chromedp.Run(ctx, chromedp.Evaluate("document.body.style.zoom = "+strconv.FormatFloat(level, 'f', 2, 64)+";", nil))
+}
+
+func (c *chromeBackend) Scroll(ctx context.Context) (BrowserScroll, error) {
+
+	scroll := []float64{}
+
+	err := chromedp.Run(ctx, chromedp.Evaluate(`(function() {
+		var body = document.body || {};
+		var html = document.documentElement;
+		var pageWidth = Math.max(body.scrollWidth || 0, body.offsetWidth || 0, html.clientWidth, html.scrollWidth, html.offsetWidth);
+		var pageHeight = Math.max(body.scrollHeight || 0, body.offsetHeight || 0, html.clientHeight, html.scrollHeight, html.offsetHeight);
+		return [window.scrollX, window.scrollY, pageWidth, pageHeight, window.innerWidth, window.innerHeight];
+	})()`, &scroll))
+
+	if err != nil {
+		return BrowserScroll{}, err
+	}
+
+	if len(scroll) != 6 {
+		return BrowserScroll{}, errors.New("couldn't read the page's scroll position")
+	}
+
+	return BrowserScroll{scroll[0], scroll[1], scroll[2], scroll[3], scroll[4], scroll[5]}, nil
+
+}
+
+func (c *chromeBackend) ScrollTo(ctx context.Context, x, y float64) error {
+	return chromedp.Run(ctx, chromedp.Evaluate("window.scrollTo("+strconv.FormatFloat(x, 'f', -1, 64)+", "+strconv.FormatFloat(y, 'f', -1, 64)+");", nil))
+}
+
+func (c *chromeBackend) History(ctx context.Context) ([]string, int, error) {
+
+	currentEntry := int64(0)
+	entries := []*page.NavigationEntry{}
+
+	if err := chromedp.Run(ctx, chromedp.NavigationEntries(&currentEntry, &entries)); err != nil {
+		return nil, 0, err
+	}
+
+	history := []string{}
+
+	for i, entry := range entries {
+
+		// Every tab starts out on a blank page, which isn't worth restoring
+		if entry.URL == "about:blank" {
+			if i < int(currentEntry) {
+				currentEntry--
+			}
+			continue
+		}
+
+		history = append(history, entry.URL)
+
+	}
+
+	return history, min(max(int(currentEntry), 0), len(history)-1), nil
+
+}
+
+func (c *chromeBackend) Interactive() bool {
+	return true
+}
+
+func (c *chromeBackend) Close() {
+	if err := chromedp.Cancel(c.Tab.Context); err != nil {
+		log.Println(err)
+	}
+}
+
+// textBackend fetches pages itself and displays just their text, for when there's no Chrome-based browser to use.
+// Only BackendActions and mouse wheel scrolling can be run; other actions return ErrUnsupportedByBackend.
+type textBackend struct {
+	Tab    *BrowserTab
+	Cancel context.CancelFunc
+
+	lock         sync.Mutex
+	url          string
+	text         string
+	history      []string
+	historyIndex int
+	scroll       int
+	width        int
+	height       int
+	pageHeight   int
+	fetchID      int
+}
+
+func newTextBackend(tab *BrowserTab) *textBackend {
+
+	backend := &textBackend{
+		Tab:          tab,
+		historyIndex: -1,
+	}
+
+	tab.Context, backend.Cancel = context.WithCancel(context.Background())
+	tab.Target = nil
+
+	return backend
+
+}
+
+func (t *textBackend) Run(ctx context.Context, action chromedp.Action) error {
+
+	switch a := action.(type) {
+
+	case BackendAction:
+		return a(ctx)
+
+	case *input.DispatchMouseEventParams:
+		if a.Type == input.MouseWheel {
+			t.lock.Lock()
+			t.scroll = max(t.scroll+int(a.DeltaY), 0)
+			t.lock.Unlock()
+			return nil
+		}
+
+	}
+
+	return ErrUnsupportedByBackend
+
+}
+
+func (t *textBackend) Navigate(ctx context.Context, url string) error {
+
+	t.lock.Lock()
+	t.history = append(t.history[:t.historyIndex+1], url)
+	t.historyIndex = len(t.history) - 1
+	t.lock.Unlock()
+
+	t.load(url)
+
+	return nil
+
+}
+
+func (t *textBackend) Travel(ctx context.Context, offset int) error {
+
+	t.lock.Lock()
+
+	index := t.historyIndex + offset
+
+	if index < 0 || index >= len(t.history) {
+		t.lock.Unlock()
+		return nil
+	}
+
+	t.historyIndex = index
+	url := t.history[index]
+
+	t.lock.Unlock()
+
+	t.load(url)
+
+	return nil
+
+}
+
+// load fetches the given page in the background, displaying its text once it's done.
+func (t *textBackend) load(pageURL string) {
+
+	t.lock.Lock()
+	t.fetchID++
+	fetchID := t.fetchID
+	t.url = pageURL
+	t.text = "Loading..."
+	t.scroll = 0
+	t.lock.Unlock()
+
+	t.Tab.LoadingWebpage.Store(true)
+
+	go func() {
+
+		text, err := fetchPageText(t.Tab.Context, pageURL)
+		if err != nil {
+			text = "Couldn't load page:\n" + err.Error()
+		}
+
+		t.lock.Lock()
+		// Don't show the page if another one started loading in the meantime
+		if t.fetchID == fetchID {
+			t.text = text
+		}
+		t.lock.Unlock()
+
+		t.Tab.LoadingWebpage.Store(false)
+		t.Tab.ForceRefresh.Store(true)
+		t.Tab.restorePendingScroll()
+
+	}()
+
+}
+
+func (t *textBackend) Resize(ctx context.Context, w, h int) error {
+	t.lock.Lock()
+	t.width = w
+	t.height = h
+	t.lock.Unlock()
+	return nil
+}
+
+func (t *textBackend) RenderFrame(ctx context.Context) ([]byte, error) {
+
+	t.lock.Lock()
+	w, h := t.width, t.height
+	text := t.text
+	t.lock.Unlock()
+
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("text browser has no size")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	margin := 8
+	lineHeight := face.Metrics().Height.Ceil() + 2
+	charWidth := face.Advance
+
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.Black),
+		Face: face,
+	}
+
+	lines := wrapText(text, max((w-margin*2)/charWidth, 1))
+
+	t.lock.Lock()
+	// Don't scroll past the end of the page
+	t.pageHeight = len(lines)*lineHeight + margin*2
+	t.scroll = min(t.scroll, max(t.pageHeight-h, 0))
+	scroll := t.scroll
+	t.lock.Unlock()
+
+	for i, line := range lines {
+
+		y := margin + i*lineHeight - scroll
+
+		if y+lineHeight < 0 {
+			continue
+		}
+		if y > h {
+			break
+		}
+
+		drawer.Dot = fixed.P(margin, y+face.Ascent)
+		drawer.DrawString(line)
+
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := png.Encode(buffer, img); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+
+}
+
+func (t *textBackend) Location(ctx context.Context) (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.url, nil
+}
+
+// Text returns the current page's text, and fetches the page again in the background so that watching it for changes
+// picks up any changes on the next check. Only the whole page's text is available, so selectors aren't supported.
+func (t *textBackend) Text(ctx context.Context, selector string) (string, error) {
+
+	if selector != "" {
+		return "", ErrUnsupportedByBackend
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.url == "" || t.Tab.LoadingWebpage.Load() {
+		return t.text, nil
+	}
+
+	fetchID := t.fetchID
+	pageURL := t.url
+
+	go func() {
+
+		text, err := fetchPageText(t.Tab.Context, pageURL)
+		if err != nil {
+			return
+		}
+
+		t.lock.Lock()
+		if t.fetchID == fetchID {
+			t.text = text
+		}
+		t.lock.Unlock()
+
+		t.Tab.ForceRefresh.Store(true)
+
+	}()
+
+	return t.text, nil
+
+}
+
+func (t *textBackend) Reload(ctx context.Context) error {
+
+	t.lock.Lock()
+	url := t.url
+	t.lock.Unlock()
+
+	if url != "" {
+		t.load(url)
+	}
+
+	return nil
+
+}
+
+// Zoom does nothing, as the text is always drawn at the same size.
+func (t *textBackend) Zoom(ctx context.Context, level float64) error {
+	return nil
+}
+
+func (t *textBackend) Scroll(ctx context.Context) (BrowserScroll, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return BrowserScroll{
+		Y:          float64(t.scroll),
+		PageWidth:  float64(t.width),
+		PageHeight: float64(max(t.pageHeight, t.height)),
+		ViewWidth:  float64(t.width),
+		ViewHeight: float64(t.height),
+	}, nil
+}
+
+func (t *textBackend) ScrollTo(ctx context.Context, x, y float64) error {
+	t.lock.Lock()
+	t.scroll = max(int(y), 0)
+	t.lock.Unlock()
+	return nil
+}
+
+func (t *textBackend) History(ctx context.Context) ([]string, int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string{}, t.history...), t.historyIndex, nil
+}
+
+func (t *textBackend) Interactive() bool {
+	return false
+}
+
+func (t *textBackend) Close() {
+	t.Cancel()
+}
+
+// fetchPageText downloads the page at the given URL (either over HTTP(S) or from disk) and returns its text.
+func fetchPageText(ctx context.Context, pageURL string) (string, error) {
+
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	var data []byte
+
+	switch parsed.Scheme {
+
+	case "file":
+
+		data, err = os.ReadFile(parsed.Path)
+		if err != nil {
+			return "", err
+		}
+
+	case "http", "https":
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second*15)
+		defer cancel()
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+		if err != nil {
+			return "", err
+		}
+
+		response, err := globals.HTTPClient.Do(request)
+		if err != nil {
+			return "", err
+		}
+
+		defer response.Body.Close()
+
+		data, err = io.ReadAll(io.LimitReader(response.Body, 1024*1024*8))
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.Contains(response.Header.Get("Content-Type"), "html") {
+			return string(data), nil
+		}
+
+	default:
+		return "", errors.New("unsupported URL scheme: " + parsed.Scheme)
+
+	}
+
+	return htmlToText(data), nil
+
+}
+
+// htmlToText returns the visible text of an HTML document, with block-level elements on their own lines.
+func htmlToText(data []byte) string {
+
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return string(data)
+	}
+
+	builder := strings.Builder{}
+
+	newline := func() {
+		if builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") {
+			builder.WriteString("\n")
+		}
+	}
+
+	var walk func(n *html.Node)
+
+	walk = func(n *html.Node) {
+
+		if n.Type == html.ElementNode {
+
+			switch n.Data {
+			case "script", "style", "head", "noscript", "template", "svg":
+				return
+			case "p", "div", "br", "li", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "section", "article", "header", "footer", "ul", "ol", "table", "pre", "blockquote", "hr":
+				newline()
+				defer newline()
+			}
+
+		}
+
+		if n.Type == html.TextNode {
+			if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+				if builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") && !strings.HasSuffix(builder.String(), " ") {
+					builder.WriteString(" ")
+				}
+				builder.WriteString(text)
+			}
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+
+	}
+
+	walk(doc)
+
+	return builder.String()
+
+}
+
+// wrapText splits the given text into lines of at most maxChars characters, breaking at spaces where possible.
+func wrapText(text string, maxChars int) []string {
+
+	lines := []string{}
+
+	for _, paragraph := range strings.Split(text, "\n") {
+
+		line := []rune{}
+
+		for _, word := range strings.Fields(paragraph) {
+
+			w := []rune(word)
+
+			for len(w) > maxChars {
+				if len(line) > 0 {
+					lines = append(lines, string(line))
+					line = line[:0]
+				}
+				lines = append(lines, string(w[:maxChars]))
+				w = w[maxChars:]
+			}
+
+			if len(line) > 0 && len(line)+1+len(w) > maxChars {
+				lines = append(lines, string(line))
+				line = line[:0]
+			}
+
+			if len(line) > 0 {
+				line = append(line, ' ')
+			}
+
+			line = append(line, w...)
+
+		}
+
+		lines = append(lines, string(line))
+
+	}
+
+	return lines
+
+}
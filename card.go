@@ -339,6 +339,8 @@ type Card struct {
 
 	onScreen bool
 
+	lockedRect sdl.FRect // The rectangle as of the last call to LockPosition, to tell when the Card has moved
+
 	debugUpdateTime time.Duration
 	debugDrawTime   time.Duration
 
@@ -1692,6 +1694,51 @@ func (card *Card) Completable() bool {
 	return card.ContentType == ContentTypeCheckbox || card.ContentType == ContentTypeNumbered
 }
 
+// ShowsProgressRollup returns if the Card should display an aggregated progress bar of its stack children (or of its
+// Sub-Page's contents, for Sub-Page cards).
+func (card *Card) ShowsProgressRollup() bool {
+	if !card.Completable() && card.ContentType != ContentTypeSubpage {
+		return false
+	}
+	prop := card.Properties.GetIfExists("rollup")
+	return prop != nil && prop.AsBool()
+}
+
+// RollupCompletion returns the completion and maximum completion levels of the Card's stack children, weighted
+// by their maximum completion levels (so a Numbered card out of 10 counts for ten times as much as a Checkbox).
+func (card *Card) RollupCompletion() (float32, float32) {
+	if sub, ok := card.Contents.(*SubPageContents); ok {
+		return sub.SubPage.CompletionTotals(true)
+	}
+	return completionTotals(card.Stack.Children())
+}
+
+// completionTotals sums the completion levels of the given cards. Checkboxes that are completed by their dependent
+// cards are skipped, as their dependents are counted on their own.
+func completionTotals(cards []*Card) (float32, float32) {
+
+	completed := float32(0)
+	maximum := float32(0)
+
+	for _, c := range cards {
+
+		if !c.Numberable() {
+			continue
+		}
+
+		if c.ContentType == ContentTypeCheckbox && c.Contents.(*CheckboxContents).MultiCheckbox() {
+			continue
+		}
+
+		completed += c.CompletionLevel()
+		maximum += c.MaximumCompletionLevel()
+
+	}
+
+	return completed, maximum
+
+}
+
 func (card *Card) Serialize(toSave bool) string {
 
 	data := "{}"
@@ -1904,6 +1951,8 @@ func (card *Card) StopDragging() {
 
 	card.HandlePinning()
 
+	card.Page.DropIntoKanban(card)
+
 }
 
 func (card *Card) HandlePinning() {
@@ -2034,6 +2083,11 @@ func (card *Card) LockPosition() {
 	// before the stacks can be accurate. This step is done in the Page later.
 	card.Page.UpdateStacks = true
 
+	if *card.Rect != card.lockedRect {
+		card.lockedRect = *card.Rect
+		card.ReceiveMessage(NewMessage(MessageCardMoved, card, nil))
+	}
+
 }
 
 func (card *Card) Move(dx, dy float32) {
@@ -2385,7 +2439,13 @@ func (card *Card) SetContents(contentType string) {
 
 func (card *Card) Collapse() {
 
-	if card.Contents.Container().IdealSize().Y <= globals.GridSize {
+	expandedHeight := card.Contents.Container().IdealSize().Y
+
+	if sizer, ok := card.Contents.(ExpandedHeighter); ok {
+		expandedHeight = sizer.ExpandedHeight()
+	}
+
+	if expandedHeight <= globals.GridSize {
 		return
 	}
 
@@ -2403,7 +2463,7 @@ func (card *Card) Collapse() {
 	}
 
 	if card.Collapsed == CollapsedNone {
-		card.Recreate(card.Rect.W, card.Contents.Container().IdealSize().Y)
+		card.Recreate(card.Rect.W, expandedHeight)
 		PlayUISound(UISoundTypeTransitionUp)
 	} else {
 		card.Recreate(card.Rect.W, globals.GridSize)
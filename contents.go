@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"os/exec"
 	"path"
-	"sort"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
+	"github.com/Zyko0/go-sdl3/img"
 	"github.com/Zyko0/go-sdl3/sdl"
 	"github.com/chromedp/cdproto/input"
 	"github.com/chromedp/chromedp"
@@ -23,6 +28,7 @@ import (
 	"github.com/skratchdot/open-golang/open"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"golang.design/x/clipboard"
 )
 
 const (
@@ -91,6 +97,49 @@ type CollapseableContents interface {
 	Collapseable() bool
 }
 
+// ExpandedHeighter is for collapseable Contents whose expanded height isn't the ideal size of their Container.
+type ExpandedHeighter interface {
+	ExpandedHeight() float32
+}
+
+// ResourceLoader is for Contents that display a Resource loaded from their Card's "filepath" property.
+type ResourceLoader interface {
+	LoadFile()
+}
+
+// handleResourceDownloadMessage cancels downloading a Card's Resource once no cards are left that use it, and
+// starts it again if one of them is restored. Cards created for pasted links are switched to the right type once
+// it's known what the link points to.
+func handleResourceDownloadMessage(card *Card, resource *Resource, msg *Message) {
+
+	switch msg.Type {
+	case MessageCardDeleted, MessageCardDestroyed:
+		resource.CancelUnusedDownload(card.Page.Project)
+	case MessageCardRestored:
+		if errors.Is(resource.DownloadError(), context.Canceled) {
+			globals.Resources.Redownload(resource)
+		}
+	case MessageResourceSniffed:
+
+		// A failed download keeps its card so that it can be retried
+		if resource.DownloadError() != nil {
+			return
+		}
+
+		contentType := pastedLinkContentType(resource)
+
+		if contentType == "" {
+			globals.EventLog.Log("WARNING: Unsure of type of file at pasted link:\n%s\nThe card created for this link was removed.", true, resource.Name)
+			card.Page.DeleteCards(card)
+		} else if contentType != card.ContentType {
+			card.SetContents(contentType)
+			loadPastedLink(card, resource.Name)
+		}
+
+	}
+
+}
+
 // type CollapseableContents interface {
 // 	CollapseSize() float32
 // }
@@ -125,6 +174,43 @@ func (dc *DefaultContents) Update() {
 
 func (dc *DefaultContents) Draw() {
 	dc.container.Draw()
+	if dc.Card.ShowsProgressRollup() {
+		drawProgressRollup(dc.Card)
+	}
+}
+
+// drawProgressRollup draws a thin progress bar along the bottom of the card, showing the aggregated completion of
+// its children.
+func drawProgressRollup(card *Card) {
+
+	completed, maximum := card.RollupCompletion()
+
+	if maximum <= 0 {
+		return
+	}
+
+	p := completed / maximum
+	if p > 1 {
+		p = 1
+	}
+
+	barHeight := float32(4)
+	dst := &sdl.FRect{card.DisplayRect.X, card.DisplayRect.Y + card.DisplayRect.H - barHeight, card.DisplayRect.W, barHeight}
+	dst = card.Page.Project.Camera.TranslateRect(dst)
+
+	FillRect(dst.X, dst.Y, dst.W, dst.H, getThemeColor(GUIMenuColor))
+
+	completionColor := getThemeColor(GUICompletedColor)
+	if card.CustomColor != nil {
+		h, s, v := card.CustomColor.HSV()
+		completionColor = NewColorFromHSV(h+0.04, s-0.2, v+0.2)
+	}
+
+	FillRect(dst.X, dst.Y, dst.W*p, dst.H, completionColor)
+
+	dstPoint := Vector{card.DisplayRect.X + card.DisplayRect.W - 32, card.DisplayRect.Y + card.DisplayRect.H - 8}
+	DrawLabel(card.Page.Project.Camera.TranslatePoint(dstPoint), 1, strconv.FormatFloat(float64(p*100), 'f', 0, 32)+"%", getThemeColor(GUIMenuColor))
+
 }
 
 func (dc *DefaultContents) Trigger(triggerType int) {}
@@ -900,6 +986,13 @@ type SoundContents struct {
 	Resource *Resource
 	Sound    *Sound
 	SeekBar  *Scrollbar
+
+	RetryRow *ContainerRow
+
+	changingTrack bool // Set when moving onto another track in the playlist, so the Sound doesn't trigger the next card
+	shuffleOrder  []int
+	trimmedSound  *Sound
+	appliedTrim   SoundRange
 }
 
 func NewSoundContents(card *Card) *SoundContents {
@@ -935,6 +1028,10 @@ func NewSoundContents(card *Card) *SoundContents {
 
 	})
 
+	settingsButton := NewIconButtonTintless(0, 0, &sdl.FRect{400, 160, 32, 32}, globals.GUITexture, true, func() {
+		globals.MenuSystem.Get("sound card settings").Open()
+	})
+
 	soundContents.PlaybackLabel = NewLabel("", &sdl.FRect{0, 0, -1, -1}, true, AlignLeft)
 
 	firstRow := soundContents.container.AddRow(AlignLeft)
@@ -952,6 +1049,10 @@ func NewSoundContents(card *Card) *SoundContents {
 		soundContents.LoadFileFrom(soundContents.FilepathLabel.TextAsString())
 	}
 
+	if playlist := card.Properties.GetIfExists("playlist"); playlist != nil && playlist.IsString() && playlist.AsString() != "" {
+		playlist.SetRaw(card.Page.Project.PlaylistPaths(playlist.AsString(), false))
+	}
+
 	row := soundContents.container.AddRow(AlignCenter)
 
 	row.Add(
@@ -987,10 +1088,21 @@ func NewSoundContents(card *Card) *SoundContents {
 	row.Add("playback label", soundContents.PlaybackLabel)
 	row.Add("play button", soundContents.PlayButton)
 	row.Add("repeat button", repeatButton)
+	row.Add("settings button", settingsButton)
 
 	row = soundContents.container.AddRow(AlignCenter)
 	row.Add("seek bar", soundContents.SeekBar)
 
+	soundContents.RetryRow = soundContents.container.AddRow(AlignCenter)
+	soundContents.RetryRow.Add("retry button", NewButton("Retry Download", nil, nil, true, func() {
+		if soundContents.Resource != nil {
+			if err := globals.Resources.Redownload(soundContents.Resource); err != nil {
+				globals.EventLog.Log("Couldn't retry download: %s", true, err.Error())
+			}
+		}
+	}))
+	soundContents.RetryRow.Visible = VisibleCollapsed
+
 	if card.Properties.Get("filepath").AsString() != "" {
 		soundContents.LoadFile()
 	}
@@ -1011,7 +1123,7 @@ func (sc *SoundContents) Update() {
 
 	if sc.Resource != nil {
 
-		if sc.Card.IsSelected() && globals.State == StateNeutral {
+		if sc.Card.IsSelected() && globals.State == StateNeutral && sc.Sound != nil {
 
 			if globals.Keybindings.Pressed(KBSoundPlay) {
 				sc.TogglePlayback()
@@ -1031,6 +1143,21 @@ func (sc *SoundContents) Update() {
 			sc.StopPlayback()
 		}
 
+		sc.applyTrim()
+		sc.updateLoop()
+
+		if err := sc.Resource.DownloadError(); err != nil {
+
+			sc.RetryRow.Visible = VisibleNormal
+			sc.SoundNameLabel.SetText([]rune("Download failed"))
+			sc.PlaybackLabel.SetText([]rune(err.Error()))
+			sc.SeekBar.Value = 0
+			return
+
+		}
+
+		sc.RetryRow.Visible = VisibleCollapsed
+
 		if sc.Resource.FinishedDownloading() {
 
 			if !sc.Resource.IsSound() {
@@ -1044,6 +1171,11 @@ func (sc *SoundContents) Update() {
 					if sc.Sound.Empty {
 						// Playback finished
 
+						if sc.advancePlaylist() {
+							sc.Sound = nil
+							return
+						}
+
 						if len(sc.Card.Links) > 0 {
 
 							for _, link := range sc.Card.Links {
@@ -1082,11 +1214,15 @@ func (sc *SoundContents) Update() {
 					nextInLoop = top
 				}
 
-				if nextInLoop != nil {
+				if nextInLoop != nil && !sc.changingTrack {
 					nextInLoop.Contents.Trigger(TriggerTypeSet)
 					sc.Playing = false
 				}
 
+				sc.changingTrack = false
+
+				sc.applyTrim()
+
 				if sc.Playing {
 					sc.Sound.Play()
 				}
@@ -1099,17 +1235,12 @@ func (sc *SoundContents) Update() {
 					sc.SeekBar.Value = float32(sc.Sound.Position().Seconds() / sc.Sound.Length().Seconds())
 				}
 
-				formatTime := func(t time.Duration) string {
-
-					minutes := int(t.Seconds()) / 60
-					seconds := int(t.Seconds()) - (minutes * 60)
-					return fmt.Sprintf("%02d:%02d", minutes, seconds)
-
-				}
-
 				_, filename := path.Split(sc.Resource.LocalFilepath)
+				if index := sc.TrackIndex(); index >= 0 {
+					filename += fmt.Sprintf(" (%d / %d)", index+1, len(sc.Playlist()))
+				}
 				sc.SoundNameLabel.SetText([]rune(filename))
-				sc.PlaybackLabel.SetText([]rune(formatTime(sc.Sound.Position()) + " / " + formatTime(sc.Sound.Length())))
+				sc.PlaybackLabel.SetText([]rune(formatPlaybackTime(sc.Sound.Position()) + " / " + formatPlaybackTime(sc.Sound.Length())))
 
 				if sc.Playing {
 					sc.PlayButton.IconSrc.X = 144
@@ -1124,6 +1255,7 @@ func (sc *SoundContents) Update() {
 		}
 
 	} else {
+		sc.RetryRow.Visible = VisibleCollapsed
 		sc.PlaybackLabel.SetText([]rune("--:-- / --:--"))
 		sc.SoundNameLabel.SetText([]rune("No sound loaded"))
 		sc.SeekBar.Value = 0
@@ -1208,6 +1340,12 @@ func (sc *SoundContents) Trigger(triggerType int) {
 
 }
 
+func (sc *SoundContents) Draw() {
+	sc.drawWaveform()
+	sc.DefaultContents.Draw()
+	sc.drawSeekBarMarks()
+}
+
 // We don't want to delete the sound on switch from SoundContents to another content type or on Card destruction because you could undo / switch back, which would require recreating the Sound, which seems unnecessary...?
 // func (sc *SoundContents) ReceiveMessage(msg *Message) {}
 
@@ -1224,8 +1362,16 @@ func (sc *SoundContents) DefaultSize() Vector {
 
 func (sc *SoundContents) ReceiveMessage(msg *Message) {
 
+	if sc.Resource != nil {
+		handleResourceDownloadMessage(sc.Card, sc.Resource, msg)
+	}
+
 	if msg.Type == MessageUndoRedo {
 		sc.LoadFile()
+	} else if msg.Type == MessageCardDeselected {
+		if menu := globals.MenuSystem.Get("sound card settings"); menu.Opened {
+			menu.Close()
+		}
 	}
 
 	if sc.Sound != nil {
@@ -1256,7 +1402,20 @@ type ImageContents struct {
 	Resource      *Resource
 	DefaultImage  *Resource
 	BrokenImage   *Resource
+	RetryButton   *Button
 	// RotatedTexture *sdl.Texture
+
+	Editing        bool
+	Tool           int
+	UsingToolState bool
+	AnnotateButton *IconButton
+	ToolButtons    []*Button
+	ActionButtons  []*Button
+	ColorButton    *Button
+	Dragging       bool
+	DragPoints     []float32
+	annotationData string
+	annotations    []ImageAnnotation
 }
 
 func NewImageContents(card *Card) *ImageContents {
@@ -1281,6 +1440,14 @@ func NewImageContents(card *Card) *ImageContents {
 
 	imageContents.LoadFile()
 
+	imageContents.RetryButton = NewButton("Retry Download", nil, nil, true, func() {
+		if imageContents.Resource != nil {
+			if err := globals.Resources.Redownload(imageContents.Resource); err != nil {
+				globals.EventLog.Log("Couldn't retry download: %s", true, err.Error())
+			}
+		}
+	})
+
 	// rotateRight := NewIconButton(0, 0, &sdl.FRect{368, 192, 32, 32}, true, func() {
 
 	// 	globals.Mouse.Button(sdl.BUTTON_LEFT).Consume()
@@ -1367,15 +1534,8 @@ func NewImageContents(card *Card) *ImageContents {
 
 			if imageContents.ValidResource() {
 
-				if imageContents.Resource.IsTexture() {
-
-					img := imageContents.Resource.AsImage()
-					imageContents.Card.Recreate(img.Size.X, img.Size.Y)
-
-				} else {
-					gif := imageContents.Resource.AsGIF()
-					imageContents.Card.Recreate(gif.Width, gif.Height)
-				}
+				crop := imageContents.Crop()
+				imageContents.Card.Recreate(crop.W, crop.H)
 				imageContents.Card.CreateUndoState = true
 
 			}
@@ -1386,6 +1546,8 @@ func NewImageContents(card *Card) *ImageContents {
 		// rotateRight,
 	}
 
+	imageContents.initAnnotationTools()
+
 	return imageContents
 }
 
@@ -1405,6 +1567,14 @@ func (ic *ImageContents) Update() {
 		resource = ic.DefaultImage
 	}
 
+	if resource.DownloadError() != nil {
+		rect := ic.RetryButton.Rectangle()
+		rect.X = ic.Card.DisplayRect.X + (ic.Card.DisplayRect.W-rect.W)/2
+		rect.Y = ic.Card.DisplayRect.Y + ic.Card.DisplayRect.H - rect.H - 8
+		ic.RetryButton.SetRectangle(rect)
+		ic.RetryButton.Update()
+	}
+
 	if ic.ValidResource() {
 
 		if !ic.LoadedImage {
@@ -1413,15 +1583,17 @@ func (ic *ImageContents) Update() {
 
 			sizeMultiplier := globals.ScreenSize.X / 8.0 / zoom
 
+			crop := ic.Crop()
+
 			if resource.IsTexture() {
 
-				asr := resource.AsImage().Size.Y / resource.AsImage().Size.X
+				asr := crop.H / crop.W
 				ic.Card.Recreate(sizeMultiplier, sizeMultiplier*asr)
 				ic.LoadedImage = true
 
 			} else if resource.IsGIF() && resource.AsGIF().IsReady() {
 
-				asr := resource.AsGIF().Height / resource.AsGIF().Width
+				asr := crop.H / crop.W
 				ic.Card.Recreate(sizeMultiplier, sizeMultiplier*asr)
 				ic.GifPlayer = NewGifPlayer(resource.AsGIF())
 				ic.LoadedImage = true
@@ -1442,15 +1614,14 @@ func (ic *ImageContents) Update() {
 		}
 
 		if !globals.Keybindings.Pressed(KBUnlockImageASR) {
-			if resource.IsTexture() {
-				ic.Card.LockResizingAspectRatio = resource.AsImage().Size.Y / resource.AsImage().Size.X
-			} else if resource.IsGIF() {
-				ic.Card.LockResizingAspectRatio = resource.AsGIF().Height / resource.AsGIF().Width
-			}
+			crop := ic.Crop()
+			ic.Card.LockResizingAspectRatio = crop.H / crop.W
 		}
 
 	}
 
+	ic.updateAnnotationTools()
+
 }
 
 func (ic *ImageContents) Draw() {
@@ -1463,6 +1634,10 @@ func (ic *ImageContents) Draw() {
 			button.Rect.Y = ic.Card.DisplayRect.Y - 32
 			button.Draw()
 		}
+		ic.AnnotateButton.IconSrc.X = 368
+		if ic.Editing {
+			ic.AnnotateButton.IconSrc.X = 400
+		}
 	}
 
 	resource := ic.Resource
@@ -1476,6 +1651,11 @@ func (ic *ImageContents) Draw() {
 
 	if resource != nil {
 
+		if err := resource.DownloadError(); err != nil {
+			ic.drawDownloadError(err)
+			return
+		}
+
 		ready := resource.FinishedDownloading() && (!resource.IsGIF() || resource.AsGIF().LoadingProgress() >= 1)
 
 		if ready {
@@ -1520,10 +1700,15 @@ func (ic *ImageContents) Draw() {
 					H: ic.Card.DisplayRect.H,
 				}
 
-				globals.Renderer.RenderTexture(shadowTexture, nil, dstRect)
+				globals.Renderer.RenderTexture(shadowTexture, ic.cropSrc(resource), dstRect)
 			}
 			// globals.Renderer.RenderTexture(ic.ShadowTexture.Texture, nil, dstRect)
-			globals.Renderer.RenderTexture(texture, nil, ic.Card.Page.Project.Camera.TranslateRect(ic.Card.DisplayRect))
+			globals.Renderer.RenderTexture(texture, ic.cropSrc(resource), ic.Card.Page.Project.Camera.TranslateRect(ic.Card.DisplayRect))
+
+			if resource == ic.Resource {
+				ic.drawAnnotations()
+				ic.drawAnnotationTools()
+			}
 
 		} else {
 
@@ -1554,6 +1739,26 @@ func (ic *ImageContents) Draw() {
 
 }
 
+// drawDownloadError shows that the card's image couldn't be downloaded, along with why and a button to try again.
+func (ic *ImageContents) drawDownloadError(err error) {
+
+	camera := ic.Card.Page.Project.Camera
+
+	globals.Renderer.RenderTexture(ic.BrokenImage.AsImage().Texture, nil, camera.TranslateRect(ic.Card.DisplayRect))
+
+	message := "Download failed"
+	if errors.Is(err, context.Canceled) {
+		message = "Download canceled"
+	} else if errText := err.Error(); len(errText) < 48 {
+		message += ": " + errText
+	}
+
+	DrawLabel(camera.TranslatePoint(Vector{ic.Card.DisplayRect.X + 4, ic.Card.DisplayRect.Y + 4}), 1, message, getThemeColor(GUIMenuColor))
+
+	ic.RetryButton.Draw()
+
+}
+
 // func (ic *ImageContents) handleRotation() {
 
 // 	if ic.Resource != nil && ic.Card.Properties.Has("rotate") {
@@ -1639,6 +1844,10 @@ func (ic *ImageContents) ReceiveMessage(msg *Message) {
 	if msg.Type == MessageUndoRedo {
 		ic.LoadFile()
 	}
+
+	if ic.Resource != nil {
+		handleResourceDownloadMessage(ic.Card, ic.Resource, msg)
+	}
 }
 
 const (
@@ -2228,51 +2437,266 @@ func (tc *TimerContents) DefaultSize() Vector {
 	return Vector{globals.GridSize * 9, globals.GridSize * 5}
 }
 
+// MapLayer is a single grid of map values. A level's layers are drawn on top of each other, from first to last.
+type MapLayer struct {
+	Name    string
+	Visible bool
+	Opacity float32
+	Data    [][]int
+}
+
+func NewMapLayer(name string) *MapLayer {
+	return &MapLayer{
+		Name:    name,
+		Visible: true,
+		Opacity: 1,
+		Data:    [][]int{},
+	}
+}
+
+// MapLevel is a set of layers making up one level (or floor) of a map.
+type MapLevel struct {
+	Name        string
+	Layers      []*MapLayer
+	ActiveLayer int
+}
+
+func NewMapLevel(name string) *MapLevel {
+	return &MapLevel{
+		Name:   name,
+		Layers: []*MapLayer{NewMapLayer("Layer 1")},
+	}
+}
+
 type MapData struct {
 	Contents      *MapContents
-	Data          [][]int
+	Data          [][]int // The grid of the active layer on the current level; this is what the drawing tools edit
 	Width, Height int
+	Levels        []*MapLevel
+	CurrentLevel  int
 }
 
 func NewMapData(contents *MapContents) *MapData {
-	return &MapData{
+	mapData := &MapData{
 		Contents: contents,
-		Data:     [][]int{}}
+		Levels:   []*MapLevel{NewMapLevel("Level 1")},
+	}
+	mapData.syncActiveLayer()
+	return mapData
 }
 
-func (mapData *MapData) Resize(w, h int) {
+// Level returns the level currently being viewed and edited.
+func (mapData *MapData) Level() *MapLevel {
+	return mapData.Levels[mapData.CurrentLevel]
+}
+
+// Layer returns the layer currently being edited.
+func (mapData *MapData) Layer() *MapLayer {
+	level := mapData.Level()
+	return level.Layers[level.ActiveLayer]
+}
+
+// AllLayers returns every layer on every level of the map.
+func (mapData *MapData) AllLayers() []*MapLayer {
+	layers := []*MapLayer{}
+	for _, level := range mapData.Levels {
+		layers = append(layers, level.Layers...)
+	}
+	return layers
+}
+
+// syncActiveLayer points Data to the active layer's grid; it should be called whenever the active layer or its grid changes.
+func (mapData *MapData) syncActiveLayer() {
+
+	mapData.CurrentLevel = max(0, min(mapData.CurrentLevel, len(mapData.Levels)-1))
+
+	level := mapData.Level()
+	level.ActiveLayer = max(0, min(level.ActiveLayer, len(level.Layers)-1))
+
+	mapData.Data = mapData.Layer().Data
+
+}
+
+// Commit stores the map data in the card's contents property and redraws the map, creating an undo state.
+func (mapData *MapData) Commit() {
+
+	contents := mapData.Contents.Card.Properties.Get("contents")
+	contents.SetRaw(mapData.Serialize())
+
+	mapData.Contents.Card.CreateUndoState = true
+
+	mapData.Contents.UpdateTexture()
+	mapData.Contents.InvalidateNeighbors()
+
+}
+
+// SetLevel switches which level of the map is shown and edited. This isn't an edit of the map itself, so it doesn't
+// create an undo state; only the stored level index is updated.
+func (mapData *MapData) SetLevel(index int) {
+
+	mapData.CurrentLevel = index
+	mapData.syncActiveLayer()
+
+	contents := mapData.Contents.Card.Properties.Get("contents")
+	data, _ := sjson.Set(contents.AsString(), "level", index)
+	contents.SetRaw(data)
+
+	mapData.Contents.UpdateTexture()
+	mapData.Contents.InvalidateNeighbors() // Neighbors connect to the level that's shown
+
+}
+
+// SetActiveLayer switches which layer of the current level is edited, without creating an undo state.
+func (mapData *MapData) SetActiveLayer(index int) {
+
+	mapData.Level().ActiveLayer = index
+	mapData.syncActiveLayer()
+
+	contents := mapData.Contents.Card.Properties.Get("contents")
+	data, _ := sjson.Set(contents.AsString(), "levels."+strconv.Itoa(mapData.CurrentLevel)+".active layer", index)
+	contents.SetRaw(data)
+
+	mapData.Contents.UpdateTexture()
+
+}
+
+// AddLevel adds a new, empty level after the current one and switches to it.
+func (mapData *MapData) AddLevel() {
+
+	level := NewMapLevel("Level " + strconv.Itoa(len(mapData.Levels)+1))
+
+	// New levels start with the same layers (though empty) as the current one
+	level.Layers = []*MapLayer{}
+	for _, layer := range mapData.Level().Layers {
+		newLayer := NewMapLayer(layer.Name)
+		newLayer.Opacity = layer.Opacity
+		level.Layers = append(level.Layers, newLayer)
+	}
+
+	level.ActiveLayer = mapData.Level().ActiveLayer
+
+	mapData.Levels = append(mapData.Levels[:mapData.CurrentLevel+1], append([]*MapLevel{level}, mapData.Levels[mapData.CurrentLevel+1:]...)...)
+	mapData.CurrentLevel++
+	mapData.Resize(mapData.Width, mapData.Height)
+	mapData.Commit()
+
+}
+
+// RemoveLevel removes the current level, as long as it isn't the only one.
+func (mapData *MapData) RemoveLevel() bool {
+
+	if len(mapData.Levels) <= 1 {
+		return false
+	}
+
+	mapData.Levels = append(mapData.Levels[:mapData.CurrentLevel], mapData.Levels[mapData.CurrentLevel+1:]...)
+	mapData.syncActiveLayer()
+	mapData.Commit()
+	return true
+
+}
+
+// AddLayer adds a new, empty layer above the active one and makes it active.
+func (mapData *MapData) AddLayer() {
+
+	level := mapData.Level()
+	layer := NewMapLayer("Layer " + strconv.Itoa(len(level.Layers)+1))
+
+	level.Layers = append(level.Layers[:level.ActiveLayer+1], append([]*MapLayer{layer}, level.Layers[level.ActiveLayer+1:]...)...)
+	level.ActiveLayer++
+	mapData.Resize(mapData.Width, mapData.Height)
+	mapData.Commit()
+
+}
+
+// RemoveLayer removes the active layer, as long as it isn't the level's only one.
+func (mapData *MapData) RemoveLayer() bool {
+
+	level := mapData.Level()
+
+	if len(level.Layers) <= 1 {
+		return false
+	}
+
+	level.Layers = append(level.Layers[:level.ActiveLayer], level.Layers[level.ActiveLayer+1:]...)
+	mapData.syncActiveLayer()
+	mapData.Commit()
+	return true
+
+}
+
+// MoveLayer moves the layer at the given index up (toward the top of the drawing order) or down a step.
+func (mapData *MapData) MoveLayer(index int, up bool) {
+
+	level := mapData.Level()
+
+	target := index - 1
+	if up {
+		target = index + 1
+	}
+
+	if target < 0 || target >= len(level.Layers) {
+		return
+	}
+
+	level.Layers[index], level.Layers[target] = level.Layers[target], level.Layers[index]
+
+	if level.ActiveLayer == index {
+		level.ActiveLayer = target
+	} else if level.ActiveLayer == target {
+		level.ActiveLayer = index
+	}
+
+	mapData.syncActiveLayer()
+	mapData.Commit()
+
+}
+
+func resizeMapGrid(data [][]int, w, h int) [][]int {
 
 	// NOTE: I think it's possible for rows to have uneven lengths.
 	// There's nothing I really want to do about this at the moment, lol
 
 	for y := 0; y < h; y++ {
 
-		if len(mapData.Data) < h {
-			mapData.Data = append(mapData.Data, []int{})
+		if len(data) < h {
+			data = append(data, []int{})
 		}
 
 		for x := 0; x < w; x++ {
 
-			if len(mapData.Data[y]) < w {
-				mapData.Data[y] = append(mapData.Data[y], 0)
+			if len(data[y]) < w {
+				data[y] = append(data[y], 0)
 			}
 
 		}
 
 	}
 
+	return data
+
+}
+
+func (mapData *MapData) Resize(w, h int) {
+
+	for _, layer := range mapData.AllLayers() {
+		layer.Data = resizeMapGrid(layer.Data, w, h)
+	}
+
 	mapData.Width = w
 	mapData.Height = h
 
+	mapData.syncActiveLayer()
+
 }
 
-func (mapData *MapData) Push(dx, dy int, loop bool) {
+func pushMapGrid(data [][]int, dx, dy int, loop bool) [][]int {
 
 	newData := [][]int{}
 
-	for y := 0; y < len(mapData.Data); y++ {
+	for y := 0; y < len(data); y++ {
 		newData = append(newData, []int{})
-		for x := 0; x < len(mapData.Data[0]); x++ {
+		for x := 0; x < len(data[0]); x++ {
 
 			var value int
 
@@ -2280,29 +2704,29 @@ func (mapData *MapData) Push(dx, dy int, loop bool) {
 				// Loop
 
 				cy := y - dy
-				for cy >= len(mapData.Data) {
-					cy -= len(mapData.Data)
+				for cy >= len(data) {
+					cy -= len(data)
 				}
 				for cy < 0 {
-					cy += len(mapData.Data)
+					cy += len(data)
 				}
 
 				cx := x - dx
-				for cx >= len(mapData.Data[0]) {
-					cx -= len(mapData.Data[0])
+				for cx >= len(data[0]) {
+					cx -= len(data[0])
 				}
 				for cx < 0 {
-					cx += len(mapData.Data[0])
+					cx += len(data[0])
 				}
 
-				value = mapData.Data[cy][cx]
+				value = data[cy][cx]
 
 			} else {
 
 				cy := y - dy
 				cx := x - dx
-				if cy >= 0 && cy < len(mapData.Data) && cx >= 0 && cx < len(mapData.Data[cy]) {
-					value = mapData.Data[cy][cx]
+				if cy >= 0 && cy < len(data) && cx >= 0 && cx < len(data[cy]) {
+					value = data[cy][cx]
 				}
 
 			}
@@ -2311,122 +2735,166 @@ func (mapData *MapData) Push(dx, dy int, loop bool) {
 		}
 	}
 
-	mapData.Data = newData
+	return newData
 
-	contents := mapData.Contents.Card.Properties.Get("contents")
-	contents.SetRaw(mapData.Serialize())
+}
 
-	mapData.Contents.Card.CreateUndoState = true
+func (mapData *MapData) Push(dx, dy int, loop bool) {
 
-	mapData.Contents.UpdateTexture()
+	for _, layer := range mapData.AllLayers() {
+		layer.Data = pushMapGrid(layer.Data, dx, dy, loop)
+	}
+
+	mapData.syncActiveLayer()
+
+	mapData.Commit()
 
 }
 
 func (mapData *MapData) Clear() {
-	for y := 0; y < mapData.Height; y++ {
-		for x := 0; x < mapData.Width; x++ {
-			mapData.Data[y][x] = 0
+	for _, layer := range mapData.AllLayers() {
+		for y := 0; y < mapData.Height && y < len(layer.Data); y++ {
+			for x := 0; x < mapData.Width && x < len(layer.Data[y]); x++ {
+				layer.Data[y][x] = 0
+			}
 		}
 	}
 }
 
 func (mapData *MapData) Clip() {
-	for y := 0; y < len(mapData.Data); y++ {
-		for x := 0; x < len(mapData.Data[y]); x++ {
-			if x >= mapData.Width || y >= mapData.Height {
-				mapData.Data[y][x] = 0
+	for _, layer := range mapData.AllLayers() {
+		for y := 0; y < len(layer.Data); y++ {
+			for x := 0; x < len(layer.Data[y]); x++ {
+				if x >= mapData.Width || y >= mapData.Height {
+					layer.Data[y][x] = 0
+				}
 			}
 		}
 	}
 }
 
-func (mapData *MapData) Rotate(counterClockwise bool) {
+// visibleGrid returns a copy of the part of the grid that lies within the map's bounds.
+func (mapData *MapData) visibleGrid(data [][]int) [][]int {
 
-	oldData := [][]int{}
+	grid := [][]int{}
 
-	for y := range mapData.Data {
+	for y := range data {
 		if y >= mapData.Height {
 			break
 		}
-		oldData = append(oldData, []int{})
-		for x := range mapData.Data[y] {
+		grid = append(grid, []int{})
+		for x := range data[y] {
 			if x >= mapData.Width {
 				break
 			}
-			oldData[y] = append(oldData[y], mapData.Data[y][x])
+			grid[y] = append(grid[y], data[y][x])
 		}
 	}
 
-	newWidth := float32(mapData.Height) * globals.GridSize
-	newHeight := float32(mapData.Width) * globals.GridSize
+	return grid
 
-	mapData.Contents.Card.Recreate(newWidth, newHeight)
+}
 
-	mapData.Data = [][]int{}
-	mapData.Resize(int(newWidth/globals.GridSize), int(newHeight/globals.GridSize))
+// rotateMapGrid returns a copy of the grid rotated by 90 degrees.
+func rotateMapGrid(data [][]int, counterClockwise bool) [][]int {
+
+	if len(data) == 0 {
+		return [][]int{}
+	}
+
+	rotated := make([][]int, len(data[0]))
+	for x := range rotated {
+		rotated[x] = make([]int, len(data))
+	}
 
-	for y := range oldData {
-		for x := range oldData[y] {
+	for y := range data {
+		for x := range data[y] {
+			if x >= len(rotated) {
+				continue
+			}
 			if counterClockwise {
-				invY := len(oldData) - 1 - y
-				mapData.Data[x][invY] = oldData[y][x]
+				invY := len(data) - 1 - y
+				rotated[x][invY] = data[y][x]
 			} else {
-				invX := len(oldData[y]) - 1 - x
-				mapData.Data[invX][y] = oldData[y][x]
+				invX := len(data[y]) - 1 - x
+				rotated[invX][y] = data[y][x]
 			}
 		}
 	}
 
-	mapData.Contents.UpdateTexture()
-
-	contents := mapData.Contents.Card.Properties.Get("contents")
-	contents.SetRaw(mapData.Serialize())
-
-	mapData.Contents.Card.CreateUndoState = true
-
-	if counterClockwise {
-		globals.EventLog.Log("Map rotated 90 degrees counter-clockwise.", false)
-	} else {
-		globals.EventLog.Log("Map rotated 90 degrees clockwise.", false)
-	}
+	return rotated
 
 }
 
-func (mapData *MapData) Flip(vertical bool) {
-
-	oldData := [][]int{}
+// flipMapGrid returns a copy of the grid flipped horizontally or vertically.
+func flipMapGrid(data [][]int, vertical bool) [][]int {
 
-	for y := range mapData.Data {
-		if y >= mapData.Height {
-			break
-		}
-		oldData = append(oldData, []int{})
-		for x := range mapData.Data[y] {
-			if x >= mapData.Width {
-				break
-			}
-			oldData[y] = append(oldData[y], mapData.Data[y][x])
-		}
+	flipped := make([][]int, len(data))
+	for y := range data {
+		flipped[y] = make([]int, len(data[y]))
 	}
 
-	for y := range mapData.Data {
-		for x := range mapData.Data[y] {
+	for y := range data {
+		for x := range data[y] {
 			if vertical {
-				invY := len(oldData) - 1 - y
-				mapData.Data[invY][x] = oldData[y][x]
+				invY := len(data) - 1 - y
+				if x < len(flipped[invY]) {
+					flipped[invY][x] = data[y][x]
+				}
 			} else {
-				invX := len(oldData[y]) - 1 - x
-				mapData.Data[y][invX] = oldData[y][x]
+				invX := len(data[y]) - 1 - x
+				flipped[y][invX] = data[y][x]
 			}
 		}
 	}
 
-	mapData.Contents.UpdateTexture()
+	return flipped
 
-	contents := mapData.Contents.Card.Properties.Get("contents")
-	contents.SetRaw(mapData.Serialize())
+}
 
-	mapData.Contents.Card.CreateUndoState = true
+func (mapData *MapData) Rotate(counterClockwise bool) {
+
+	layers := mapData.AllLayers()
+
+	rotatedData := [][][]int{}
+	for _, layer := range layers {
+		rotatedData = append(rotatedData, rotateMapGrid(mapData.visibleGrid(layer.Data), counterClockwise))
+	}
+
+	newWidth := float32(mapData.Height) * globals.GridSize
+	newHeight := float32(mapData.Width) * globals.GridSize
+
+	mapData.Contents.Card.Recreate(newWidth, newHeight)
+
+	for i, layer := range layers {
+		layer.Data = rotatedData[i]
+	}
+
+	mapData.Resize(int(newWidth/globals.GridSize), int(newHeight/globals.GridSize))
+
+	mapData.Commit()
+
+	if counterClockwise {
+		globals.EventLog.Log("Map rotated 90 degrees counter-clockwise.", false)
+	} else {
+		globals.EventLog.Log("Map rotated 90 degrees clockwise.", false)
+	}
+
+}
+
+func (mapData *MapData) Flip(vertical bool) {
+
+	for _, layer := range mapData.AllLayers() {
+
+		flipped := flipMapGrid(mapData.visibleGrid(layer.Data), vertical)
+
+		for y := range flipped {
+			copy(layer.Data[y], flipped[y])
+		}
+
+	}
+
+	mapData.Commit()
 
 	if vertical {
 		globals.EventLog.Log("Map flipped vertically.", false)
@@ -2453,10 +2921,15 @@ func (mapData *MapData) Set(point Vector, value int) bool {
 }
 
 func (mapData *MapData) GetI(x, y int) int {
-	if y < 0 || x < 0 || y >= mapData.Height || x >= mapData.Width {
+	return mapData.LayerGetI(mapData.Layer(), x, y)
+}
+
+// LayerGetI returns the value at the given position in the given layer, or -1 if it's outside of the map.
+func (mapData *MapData) LayerGetI(layer *MapLayer, x, y int) int {
+	if y < 0 || x < 0 || y >= mapData.Height || x >= mapData.Width || y >= len(layer.Data) || x >= len(layer.Data[y]) {
 		return -1
 	}
-	return mapData.Data[y][x]
+	return layer.Data[y][x]
 }
 
 func (mapData *MapData) Get(point Vector) int {
@@ -2464,26 +2937,93 @@ func (mapData *MapData) Get(point Vector) int {
 }
 
 func (mapData *MapData) Serialize() string {
-	dataStr, _ := sjson.Set("{}", "contents", mapData.Data)
+
+	dataStr := "{}"
+
+	for i, level := range mapData.Levels {
+
+		levelPath := "levels." + strconv.Itoa(i)
+		dataStr, _ = sjson.Set(dataStr, levelPath+".name", level.Name)
+		dataStr, _ = sjson.Set(dataStr, levelPath+".active layer", level.ActiveLayer)
+
+		for j, layer := range level.Layers {
+			layerPath := levelPath + ".layers." + strconv.Itoa(j)
+			dataStr, _ = sjson.Set(dataStr, layerPath+".name", layer.Name)
+			dataStr, _ = sjson.Set(dataStr, layerPath+".visible", layer.Visible)
+			dataStr, _ = sjson.Set(dataStr, layerPath+".opacity", layer.Opacity)
+			dataStr, _ = sjson.Set(dataStr, layerPath+".contents", layer.Data)
+		}
+
+	}
+
+	dataStr, _ = sjson.Set(dataStr, "level", mapData.CurrentLevel)
+
 	return dataStr
+
 }
 
 func (mapData *MapData) Deserialize(data string) {
 
 	if data != "" {
 
-		contents := gjson.Get(data, "contents")
+		parsed := gjson.Parse(data)
 
-		if len(contents.Array()) == 0 {
-			mapData.Clear()
+		readGrid := func(contents gjson.Result) [][]int {
+			grid := resizeMapGrid([][]int{}, mapData.Width, mapData.Height)
+			for y, r := range contents.Array() {
+				for x, v := range r.Array() {
+					if y < mapData.Height && x < mapData.Width {
+						grid[y][x] = int(v.Int())
+					}
+				}
+			}
+			return grid
 		}
 
-		for y, r := range contents.Array() {
-			for x, v := range r.Array() {
-				mapData.SetI(x, y, int(v.Int()))
+		if levels := parsed.Get("levels"); levels.Exists() && len(levels.Array()) > 0 {
+
+			mapData.Levels = []*MapLevel{}
+
+			for _, levelData := range levels.Array() {
+
+				level := &MapLevel{
+					Name:        levelData.Get("name").String(),
+					ActiveLayer: int(levelData.Get("active layer").Int()),
+				}
+
+				for _, layerData := range levelData.Get("layers").Array() {
+					layer := NewMapLayer(layerData.Get("name").String())
+					layer.Visible = !layerData.Get("visible").Exists() || layerData.Get("visible").Bool()
+					if opacity := layerData.Get("opacity"); opacity.Exists() {
+						layer.Opacity = float32(opacity.Float())
+					}
+					layer.Data = readGrid(layerData.Get("contents"))
+					level.Layers = append(level.Layers, layer)
+				}
+
+				if len(level.Layers) == 0 {
+					level.Layers = append(level.Layers, NewMapLayer("Layer 1"))
+					level.Layers[0].Data = resizeMapGrid(level.Layers[0].Data, mapData.Width, mapData.Height)
+				}
+
+				mapData.Levels = append(mapData.Levels, level)
+
 			}
+
+			mapData.CurrentLevel = int(parsed.Get("level").Int())
+
+		} else {
+
+			// Maps from before levels and layers were added have a single grid of contents
+			level := NewMapLevel("Level 1")
+			level.Layers[0].Data = readGrid(parsed.Get("contents"))
+			mapData.Levels = []*MapLevel{level}
+			mapData.CurrentLevel = 0
+
 		}
 
+		mapData.syncActiveLayer()
+
 	}
 
 }
@@ -2495,6 +3035,9 @@ const (
 	MapEditToolEraser
 	MapEditToolFill
 	MapEditToolLine
+	MapEditToolRectangle
+	MapEditToolEllipse
+	MapEditToolSelect
 
 	MapPatternSolid   = 0
 	MapPatternCrossed = 16
@@ -2506,17 +3049,27 @@ const (
 type MapContents struct {
 	DefaultContents
 	Tool           int
+	UsingToolState bool
 	RenderTexture  *RenderTexture
 	Buttons        []*IconButton
 	LineStart      Vector
+	ShapeStart     Vector
+	Selection      *MapSelection
 	MapData        *MapData
 	PatternButtons map[int]*Button
 	PrevPos        Vector
+
+	neighbors           []*MapContents
+	connectionsOutdated bool
 }
 
 var MapDrawingColor = 1
 var MapPattern = MapPatternSolid
-var MapPaletteColors = []Color{
+
+// MapPaletteColors is the palette maps are currently drawn with; projects can replace the default with their own.
+var MapPaletteColors = append([]Color{}, DefaultMapPaletteColors...)
+
+var DefaultMapPaletteColors = []Color{
 	NewColor(250, 240, 240, 255),
 	NewColor(150, 150, 150, 255),
 	NewColor(110, 110, 110, 255),
@@ -2540,9 +3093,11 @@ var MapPaletteColors = []Color{
 func NewMapContents(card *Card) *MapContents {
 
 	mc := &MapContents{
-		DefaultContents: newDefaultContents(card),
-		Buttons:         []*IconButton{},
-		PatternButtons:  map[int]*Button{},
+		DefaultContents:     newDefaultContents(card),
+		Buttons:             []*IconButton{},
+		PatternButtons:      map[int]*Button{},
+		ShapeStart:          Vector{-1, -1},
+		connectionsOutdated: true,
 	}
 
 	mc.MapData = NewMapData(mc)
@@ -2555,6 +3110,9 @@ func NewMapContents(card *Card) *MapContents {
 		{368, 64, 32, 32},  // MapEditToolEraser
 		{368, 96, 32, 32},  // MapEditToolBucket
 		{368, 128, 32, 32}, // MapEditToolLine
+		{736, 0, 32, 32},   // MapEditToolRectangle
+		{736, 32, 32, 32},  // MapEditToolEllipse
+		{736, 64, 32, 32},  // MapEditToolSelect
 	}
 
 	for index, iconSrc := range toolButtons {
@@ -2606,6 +3164,8 @@ func NewMapContents(card *Card) *MapContents {
 
 func (mc *MapContents) Update() {
 
+	mc.updateConnections()
+
 	if mc.Tool == MapEditToolNone {
 		mc.Card.Draggable = true
 		// mc.Card.Depth = -10000000 // Depth is lower when not editing the map so it's always behind lines
@@ -2713,6 +3273,18 @@ func (mc *MapContents) Update() {
 			mc.Tool = MapEditToolLine
 			mc.Card.Page.Selection.Clear()
 			mc.Card.Page.Selection.Add(mc.Card)
+		} else if globals.Keybindings.Pressed(KBMapRectangleTool) {
+			mc.Tool = MapEditToolRectangle
+			mc.Card.Page.Selection.Clear()
+			mc.Card.Page.Selection.Add(mc.Card)
+		} else if globals.Keybindings.Pressed(KBMapEllipseTool) {
+			mc.Tool = MapEditToolEllipse
+			mc.Card.Page.Selection.Clear()
+			mc.Card.Page.Selection.Add(mc.Card)
+		} else if globals.Keybindings.Pressed(KBMapSelectTool) {
+			mc.Tool = MapEditToolSelect
+			mc.Card.Page.Selection.Clear()
+			mc.Card.Page.Selection.Add(mc.Card)
 		} else if globals.Keybindings.Pressed(KBMapPalette) && mc.Card.IsSelected() && len(mc.Card.Page.Selection.Cards) == 1 {
 			paletteMenu := globals.MenuSystem.Get("map palette menu")
 			if paletteMenu.Opened {
@@ -2722,15 +3294,29 @@ func (mc *MapContents) Update() {
 			}
 		}
 
+		// The selection only lasts while the selection tool is in use on the layer it was made on
+		if mc.Selection != nil && (mc.Tool != MapEditToolSelect || mc.Selection.Layer != mc.MapData.Layer()) {
+			mc.Selection = nil
+		}
+
+		if mc.Tool != MapEditToolRectangle && mc.Tool != MapEditToolEllipse && mc.Tool != MapEditToolSelect {
+			mc.ShapeStart.X = -1
+			mc.ShapeStart.Y = -1
+		}
+
 		mp := globals.Mouse.WorldPosition()
 		gp := mc.GridCursorPosition()
 		leftMB := globals.Mouse.Button(sdl.BUTTON_LEFT)
 		rightMB := globals.Mouse.Button(sdl.BUTTON_RIGHT)
 
 		if mc.Tool != MapEditToolNone && mp.Inside(mc.Card.Rect) {
-			globals.State = StateMapEditing
-		} else if globals.State == StateMapEditing && (mc.Tool == MapEditToolNone || !mp.Inside(mc.Card.Rect)) {
-			globals.State = StateNeutral
+			globals.State = StateCardToolEditing
+			mc.UsingToolState = true
+		} else if mc.UsingToolState {
+			if globals.State == StateCardToolEditing {
+				globals.State = StateNeutral
+			}
+			mc.UsingToolState = false
 		}
 
 		if mc.Card.Resizing == "" && !globals.Mouse.OverGUI {
@@ -2767,43 +3353,21 @@ func (mc *MapContents) Update() {
 							fill = 0
 						}
 
-						end := gp
-						start := mc.LineStart
-
-						dir := end.Sub(start).Normalized()
-
-						mc.MapData.Set(start, fill)
-
-						horizontal := true
-
-						if start != end {
-
-							for i := 0; i < 100000; i++ {
+						// Lines can carry on into neighboring maps
+						changed = mc.ConnectedLine(mc.LineStart, mc.UnclampedGridCursorPosition(), fill)
 
-								if horizontal {
-									start.X += dir.X / 2
-								} else {
-									start.Y += dir.Y / 2
-								}
-
-								horizontal = !horizontal
-
-								setReturn := mc.MapData.Set(start.Rounded(), fill)
-
-								if start.Rounded().Equals(end.Rounded()) || !setReturn {
-									break
-								}
+						mc.LineStart.X = -1
+						mc.LineStart.Y = -1
 
-							}
+					}
 
-						}
+				} else if mc.Tool == MapEditToolRectangle || mc.Tool == MapEditToolEllipse {
 
-						changed = true
+					changed = mc.updateShapeTool(mp, gp)
 
-						mc.LineStart.X = -1
-						mc.LineStart.Y = -1
+				} else if mc.Tool == MapEditToolSelect {
 
-					}
+					changed = mc.updateSelectTool(mp, gp)
 
 				} else if mc.Tool == MapEditToolPencil && mp.Inside(mc.Card.Rect) {
 
@@ -2830,50 +3394,14 @@ func (mc *MapContents) Update() {
 
 					globals.Mouse.SetCursor(CursorBucket)
 
-					neighbors := map[Vector]bool{gp: true}
-					checked := map[Vector]bool{}
-
 					if leftMB.Pressed() || rightMB.Pressed() {
 
-						empty := mc.MapData.Get(gp)
-
 						fill := mc.ColorIndex()
 						if rightMB.Pressed() {
 							fill = 0
 						}
 
-						if empty != fill {
-
-							addIfNotAdded := func(point Vector, value int) {
-
-								if _, exist := checked[point]; !exist && mc.MapData.Get(point) == value {
-									neighbors[point] = true
-								}
-
-							}
-
-							for len(neighbors) > 0 {
-
-								for n := range neighbors {
-
-									mc.MapData.Set(n, fill)
-
-									addIfNotAdded(n.AddF(-1, 0), empty)
-									addIfNotAdded(n.AddF(1, 0), empty)
-									addIfNotAdded(n.AddF(0, -1), empty)
-									addIfNotAdded(n.AddF(0, 1), empty)
-
-									delete(neighbors, n)
-
-									break
-
-								}
-
-							}
-
-							changed = true
-
-						}
+						changed = mc.ConnectedFill(gp, fill)
 
 					}
 
@@ -2889,6 +3417,7 @@ func (mc *MapContents) Update() {
 			contents.SetRaw(mc.MapData.Serialize())
 			mc.Card.SyncProperty(contents, false)
 			mc.Card.CreateUndoState = true // Since we're setting the property raw, we have to manually create an undo state, though
+			mc.InvalidateNeighbors()
 		}
 
 		for index, button := range mc.Buttons {
@@ -2915,12 +3444,10 @@ func (mc *MapContents) Update() {
 			globals.EventLog.Log("Map shifted by 1 downwards.", false)
 		} else if globals.Keybindings.Pressed(KBMapRotateRight) {
 			globals.Keybindings.Shortcuts[KBMapRotateRight].ConsumeKeys()
-			mc.MapData.Rotate(true)
-			globals.EventLog.Log("Map rotated clockwise by 90 degrees.", false)
+			mc.Rotate(true)
 		} else if globals.Keybindings.Pressed(KBMapRotateLeft) {
 			globals.Keybindings.Shortcuts[KBMapRotateLeft].ConsumeKeys()
-			mc.MapData.Rotate(false)
-			globals.EventLog.Log("Map rotated counter-clockwise by 90 degrees.", false)
+			mc.Rotate(false)
 		} else if globals.Keybindings.Pressed(KBMapWrapAroundCards) {
 			globals.Keybindings.Shortcuts[KBMapWrapAroundCards].ConsumeKeys()
 
@@ -3033,12 +3560,16 @@ func (mc *MapContents) Update() {
 
 	} else {
 
-		if mc.Tool != MapEditToolNone {
+		if mc.UsingToolState && globals.State == StateCardToolEditing {
 			globals.State = StateNeutral
-			mc.Tool = MapEditToolNone
 		}
+		mc.UsingToolState = false
+		mc.Tool = MapEditToolNone
 		mc.LineStart.X = -1
 		mc.LineStart.Y = -1
+		mc.ShapeStart.X = -1
+		mc.ShapeStart.Y = -1
+		mc.Selection = nil
 	}
 
 }
@@ -3048,7 +3579,11 @@ func (mc *MapContents) Draw() {
 	if mc.Card.IsSelected() {
 
 		for index, button := range mc.Buttons {
+			// The active version of each tool's icon is just to the right of the inactive one
 			srcX := float32(368)
+			if index >= MapEditToolRectangle {
+				srcX = 736
+			}
 			if mc.Tool == index {
 				srcX += 32
 			}
@@ -3057,6 +3592,12 @@ func (mc *MapContents) Draw() {
 			button.Draw()
 		}
 
+		// Show which level and layer are being edited when there's more than one
+		if len(mc.MapData.Levels) > 1 || len(mc.MapData.Level().Layers) > 1 {
+			pos := globals.Project.Camera.TranslatePoint(Vector{mc.Card.DisplayRect.X, mc.Card.DisplayRect.Y + mc.Card.DisplayRect.H + 4})
+			DrawLabel(pos, 1, mc.MapData.Level().Name+" / "+mc.MapData.Layer().Name, getThemeColor(GUIMenuColor))
+		}
+
 	}
 
 	if mc.RenderTexture != nil {
@@ -3070,43 +3611,16 @@ func (mc *MapContents) Draw() {
 
 		if mc.UsingLineTool() && (mc.LineStart.X >= 0 || mc.LineStart.Y >= 0) {
 
-			gp := mc.GridCursorPosition()
-			start := mc.LineStart
-			dir := gp.Sub(start).Normalized()
-
-			horizontal := true
-
-			if start != gp {
-
-				for i := 0; i < 100000; i++ {
-
-					s := start
-					s.X = float32(math.Round(float64(s.X)))*globals.GridSize + mc.Card.Rect.X
-					s.Y = float32(math.Round(float64(s.Y)))*globals.GridSize + mc.Card.Rect.Y
-
-					mp := globals.Project.Camera.UntranslatePoint(s)
-					ThickRect(int32(mp.X), int32(mp.Y), 32, 32, 2, NewColor(200, 220, 240, 255))
-
-					if start.Rounded().Equals(gp.Rounded()) {
-						break
-					}
-
-					if horizontal {
-						start.X += dir.X / 2
-					} else {
-						start.Y += dir.Y / 2
-					}
-
-					horizontal = !horizontal
-
-					// Draw square
-
-				}
-
+			for _, cell := range lineCells(mc.LineStart, mc.UnclampedGridCursorPosition()) {
+				mc.drawGridRect(int(cell.X), int(cell.Y), 1, 1, NewColor(200, 220, 240, 255))
 			}
 
 		}
 
+		if mc.Card.IsSelected() {
+			mc.drawToolPreviews()
+		}
+
 		if mp := globals.Mouse.WorldPosition(); mc.Tool != MapEditToolNone && mp.Inside(mc.Card.Rect) {
 
 			mp.X = float32(math.Floor(float64((mp.X)/globals.GridSize))) * globals.GridSize
@@ -3216,11 +3730,9 @@ func (mc *MapContents) UpdateTexture() {
 
 		guiTex := globals.GUITexture.Texture
 
-		for y := 0; y < len(mc.MapData.Data); y++ {
-
-			for x := 0; x < len(mc.MapData.Data[y]); x++ {
+		for y := 0; y < mc.MapData.Height; y++ {
 
-				value := mc.MapData.GetI(x, y)
+			for x := 0; x < mc.MapData.Width; x++ {
 
 				src := &sdl.FRect{208, 64, 32, 32}
 				dst := &sdl.FRect{float32(x) * globals.GridSize, float32(y) * globals.GridSize, globals.GridSize, globals.GridSize}
@@ -3233,124 +3745,161 @@ func (mc *MapContents) UpdateTexture() {
 				guiTex.SetAlphaMod(mapColor[3])
 				globals.Renderer.RenderTextureRotated(guiTex, src, dst, 0, &sdl.FPoint{16, 16}, sdl.FLIP_NONE)
 
-				rot := float64(0)
-				color := NewColor(255, 255, 255, 255)
+			}
 
-				if value > 0 {
+		}
 
-					// Color value is the value contained in the grid without the pattern bits
-					colorValue := mc.ColorIndexToColor(value)
+		for _, layer := range mc.MapData.Level().Layers {
 
-					color = MapPaletteColors[colorValue-1]
+			if !layer.Visible {
+				continue
+			}
 
-					src.X = 544
-					src.Y = 0
+			for y := 0; y < mc.MapData.Height; y++ {
 
-					if value&MapPatternCrossed > 0 {
-						src.Y = 32
-					} else if value&MapPatternDotted > 0 {
-						src.Y = 64
-					} else if value&MapPatternChecked > 0 {
-						src.Y = 96
-					} else if value&MapPatternTerrain > 0 {
-						src.Y = 128
-					}
+				for x := 0; x < mc.MapData.Width; x++ {
 
-					rv := mc.MapData.GetI(x+1, y)
-					lv := mc.MapData.GetI(x-1, y)
-					tv := mc.MapData.GetI(x, y-1)
-					bv := mc.MapData.GetI(x, y+1)
-
-					// patternValue := mc.ColorIndexToPattern(value)
-					// right := rv > 0 && mc.ColorIndexToPattern(rv) == patternValue
-					// left := lv > 0 && mc.ColorIndexToPattern(lv) == patternValue
-					// top := tv > 0 && mc.ColorIndexToPattern(tv) == patternValue
-					// bottom := bv > 0 && mc.ColorIndexToPattern(bv) == patternValue
-
-					right := rv == value
-					left := lv == value
-					top := tv == value
-					bottom := bv == value
-
-					count := 0
-					if right {
-						count++
-					}
-					if left {
-						count++
-					}
-					if top {
-						count++
-					}
-					if bottom {
-						count++
-					}
+					mc.drawMapValue(layer, x, y)
 
-					if count == 4 {
-						src.X = 704
-					} else if count == 3 {
-						src.X = 672
-						if !bottom {
-							rot = 90
-						} else if !left {
-							rot = 180
-						} else if !top {
-							rot = 270
-						}
-					} else if count == 2 {
+				}
 
-						if (right && left) || (top && bottom) { /// Hallways
-							src.X = 608
-							if top && bottom {
-								rot = 90
-							}
-						} else {
-							src.X = 640
-
-							if left && bottom {
-								rot = 90
-							} else if left && top { // Corners
-								rot = 180
-							} else if top && right {
-								rot = 270
-							}
+			}
 
-						}
+		}
 
-					} else if count == 1 {
+		SetRenderTarget(nil)
 
-						src.X = 576
+	}
 
-						if left {
-							rot = 180
-						} else if top {
-							rot = -90
-						} else if bottom {
-							rot = 90
-						}
+}
 
-					}
+// drawMapValue draws the value at the given position in the given layer, auto-tiling it to match its neighbors.
+func (mc *MapContents) drawMapValue(layer *MapLayer, x, y int) {
 
-					// Island
+	guiTex := globals.GUITexture.Texture
 
-					guiTex.SetColorMod(color.RGB())
-					guiTex.SetAlphaMod(color[3])
+	value := mc.MapData.LayerGetI(layer, x, y)
 
-					globals.Renderer.RenderTextureRotated(guiTex, src, dst, rot, &sdl.FPoint{16, 16}, sdl.FLIP_NONE)
+	src := &sdl.FRect{208, 64, 32, 32}
+	dst := &sdl.FRect{float32(x) * globals.GridSize, float32(y) * globals.GridSize, globals.GridSize, globals.GridSize}
 
-				}
+	rot := float64(0)
+
+	if value <= 0 {
+		return
+	}
+
+	// Color value is the value contained in the grid without the pattern bits
+	colorValue := mc.ColorIndexToColor(value)
+
+	// Palettes can be shorter than the default, so fall back to the last color if the value's color doesn't exist
+	color := MapPaletteColors[max(0, min(colorValue, len(MapPaletteColors))-1)]
+
+	src.X = 544
+	src.Y = 0
+
+	if value&MapPatternCrossed > 0 {
+		src.Y = 32
+	} else if value&MapPatternDotted > 0 {
+		src.Y = 64
+	} else if value&MapPatternChecked > 0 {
+		src.Y = 96
+	} else if value&MapPatternTerrain > 0 {
+		src.Y = 128
+	}
+
+	// Neighboring Map cards connect up with this one
+	rv := mc.ConnectedGet(layer, x+1, y)
+	lv := mc.ConnectedGet(layer, x-1, y)
+	tv := mc.ConnectedGet(layer, x, y-1)
+	bv := mc.ConnectedGet(layer, x, y+1)
+
+	// patternValue := mc.ColorIndexToPattern(value)
+	// right := rv > 0 && mc.ColorIndexToPattern(rv) == patternValue
+	// left := lv > 0 && mc.ColorIndexToPattern(lv) == patternValue
+	// top := tv > 0 && mc.ColorIndexToPattern(tv) == patternValue
+	// bottom := bv > 0 && mc.ColorIndexToPattern(bv) == patternValue
 
+	right := rv == value
+	left := lv == value
+	top := tv == value
+	bottom := bv == value
+
+	count := 0
+	if right {
+		count++
+	}
+	if left {
+		count++
+	}
+	if top {
+		count++
+	}
+	if bottom {
+		count++
+	}
+
+	if count == 4 {
+		src.X = 704
+	} else if count == 3 {
+		src.X = 672
+		if !bottom {
+			rot = 90
+		} else if !left {
+			rot = 180
+		} else if !top {
+			rot = 270
+		}
+	} else if count == 2 {
+
+		if (right && left) || (top && bottom) { /// Hallways
+			src.X = 608
+			if top && bottom {
+				rot = 90
+			}
+		} else {
+			src.X = 640
+
+			if left && bottom {
+				rot = 90
+			} else if left && top { // Corners
+				rot = 180
+			} else if top && right {
+				rot = 270
 			}
 
 		}
-		SetRenderTarget(nil)
+
+	} else if count == 1 {
+
+		src.X = 576
+
+		if left {
+			rot = 180
+		} else if top {
+			rot = -90
+		} else if bottom {
+			rot = 90
+		}
 
 	}
 
+	// Island
+
+	guiTex.SetColorMod(color.RGB())
+	guiTex.SetAlphaMod(uint8(float32(color[3]) * layer.Opacity))
+
+	globals.Renderer.RenderTextureRotated(guiTex, src, dst, rot, &sdl.FPoint{16, 16}, sdl.FLIP_NONE)
+
 }
 
 func (mc *MapContents) ReceiveMessage(msg *Message) {
 
+	switch msg.Type {
+	case MessageCardMoved, MessageCardResizeCompleted, MessageUndoRedo, MessageCardDeleted, MessageCardDestroyed, MessageCardRestored, MessageContentSwitched:
+		mc.InvalidateConnections()
+	}
+
 	if msg.Type == MessageThemeChange || msg.Type == MessageRenderTextureRefresh {
 		mc.UpdateTexture()
 	} else if msg.Type == MessageUndoRedo {
@@ -3935,14 +4484,30 @@ func (lc *LinkContents) ReceiveMessage(msg *Message) {
 
 }
 
+const (
+	TableCellTypeCheckbox = iota
+	TableCellTypeText
+	TableCellTypeNumber
+	TableCellTypeDate
+)
+
 type TableDataContents struct {
 	TableData *TableData
 	Value     int
+	Type      int
+	Text      string // The contents of text, number and date cells; a formula if it starts with "="
 	Button    *IconButton
 }
 
 func (tdc *TableDataContents) OnClick(rightClick bool) {
 
+	tdc.TableData.ActiveCell = tdc
+
+	if tdc.Type != TableCellTypeCheckbox {
+		tdc.TableData.EditCell(tdc)
+		return
+	}
+
 	if rightClick {
 		tdc.Value--
 	} else {
@@ -3985,6 +4550,12 @@ type TableData struct {
 	DraggingLabel     *DraggableLabel
 	EditingLabel      *DraggableLabel
 	ValueDisplayMode  int
+	ActiveCell        *TableDataContents // The cell last clicked on, which the table settings menu edits
+	FocusFormulaBar   bool
+	hiddenRows        []bool // Rows and columns hidden by the table's filters
+	hiddenColumns     []bool
+	filterSettings    string // The filter settings that the hidden rows and columns were worked out for
+	filtersOutdated   bool   // Whether the table has changed since the hidden rows and columns were worked out
 	previouslyShowing bool
 	Changed           bool
 }
@@ -4017,6 +4588,8 @@ func (td *TableData) Resize(w, h int) {
 		return
 	}
 
+	td.filtersOutdated = true
+
 	for len(td.RowHeadings) < h {
 		hori := NewDraggableLabel("Row "+strconv.Itoa(len(td.RowHeadings)+1), td)
 		hori.Label.OnChange = func() {
@@ -4082,8 +4655,17 @@ func (td *TableData) SetValue(x, y, value int) {
 
 func (td *TableData) Update() {
 
+	if td.Changed {
+		td.filtersOutdated = true
+	}
+
 	td.Changed = false
 
+	td.updateFilters()
+
+	// Cells' results are shared by every heading's completion
+	ev := newTableEvaluator(td)
+
 	x := td.Table.Card.DisplayRect.X
 	y := td.Table.Card.DisplayRect.Y
 
@@ -4104,12 +4686,20 @@ func (td *TableData) Update() {
 				break
 			}
 
+			if td.RowHidden(yi) {
+				continue
+			}
+
 			for xi, content := range td.Data[yi] {
 
 				if xi >= td.Width {
 					break
 				}
 
+				if td.ColumnHidden(xi) {
+					continue
+				}
+
 				content.Button.Active = td.Table.Card.selected
 				content.Button.Rect.X = x + 4
 				content.Button.Rect.Y = y + 4
@@ -4118,6 +4708,12 @@ func (td *TableData) Update() {
 				x += 32
 				content.Button.IconSrc.Y = 488 - (float32(td.ValueDisplayMode) * 24)
 
+				// Other cell types draw their contents as text over an empty cell
+				if content.Type != TableCellTypeCheckbox {
+					content.Button.IconSrc.X = 24
+					content.Button.IconSrc.Y = 488
+				}
+
 				content.Button.BGIconTint = ColorWhite
 
 				tint := ColorWhite
@@ -4137,8 +4733,12 @@ func (td *TableData) Update() {
 
 		hoveringAlpha := float32(1)
 
-		if hoveringX >= 0 && hoveringX < td.Width && hoveringY >= 0 && hoveringY < td.Height {
+		columns, rows := td.VisibleColumns(), td.VisibleRows()
+
+		if hoveringX >= 0 && hoveringX < len(columns) && hoveringY >= 0 && hoveringY < len(rows) {
 			hoveringAlpha = 0.5
+			hoveringX = columns[hoveringX]
+			hoveringY = rows[hoveringY]
 		}
 
 		for yi := 0; yi < td.Height; yi++ {
@@ -4225,7 +4825,7 @@ func (td *TableData) Update() {
 
 	for i, heading := range td.RowHeadings {
 
-		if i < td.Height {
+		if i < td.Height && !td.RowHidden(i) {
 
 			if td.DraggingLabel == heading || (td.DraggingLabel == nil && mousePos.Inside(heading.TargetRect)) {
 
@@ -4252,7 +4852,7 @@ func (td *TableData) Update() {
 			}
 			y += 32
 
-			heading.FillAmount = td.RowCompletion(i, false)
+			heading.FillAmount = td.rowCompletion(ev, i, false)
 
 		}
 
@@ -4267,7 +4867,7 @@ func (td *TableData) Update() {
 
 	if td.DraggingLabel != nil && !td.DraggingLabel.Vertical {
 		prevOrder = append([]*DraggableLabel{}, td.RowHeadings...)
-		sort.Slice(td.RowHeadings[:td.Height], func(i, j int) bool { return td.RowHeadings[i].CenterY() < td.RowHeadings[j].CenterY() })
+		td.sortVisibleHeadings(td.RowHeadings[:td.Height], td.hiddenRows, func(a, b *DraggableLabel) bool { return a.CenterY() < b.CenterY() })
 	}
 
 	// Columns
@@ -4277,7 +4877,7 @@ func (td *TableData) Update() {
 
 	for i, heading := range td.ColumnHeadings {
 
-		if i < td.Width {
+		if i < td.Width && !td.ColumnHidden(i) {
 
 			if td.DraggingLabel == heading || (td.DraggingLabel == nil && mousePos.Inside(heading.TargetRect)) {
 
@@ -4304,7 +4904,7 @@ func (td *TableData) Update() {
 			}
 			x += 32
 
-			heading.FillAmount = td.RowCompletion(i, true)
+			heading.FillAmount = td.rowCompletion(ev, i, true)
 
 		}
 
@@ -4317,7 +4917,7 @@ func (td *TableData) Update() {
 	if td.DraggingLabel != nil && td.DraggingLabel.Vertical {
 		prevOrder = append([]*DraggableLabel{}, td.ColumnHeadings...)
 		verticalChange = true
-		sort.Slice(td.ColumnHeadings[:td.Width], func(i, j int) bool { return td.ColumnHeadings[i].CenterX() < td.ColumnHeadings[j].CenterX() })
+		td.sortVisibleHeadings(td.ColumnHeadings[:td.Width], td.hiddenColumns, func(a, b *DraggableLabel) bool { return a.CenterX() < b.CenterX() })
 		// sort.Slice(td.ColumnHeadings[:td.Width], func(i, j int) bool {
 		// 	return td.ColumnHeadings[i].CenterY() > td.ColumnHeadings[j].CenterY() && td.ColumnHeadings[i].Rect.X < td.ColumnHeadings[j].Rect.X
 		// })
@@ -4389,16 +4989,20 @@ func (td *TableData) updateMaxLabelSizes() {
 
 func (td *TableData) Draw() {
 
-	for y := range td.Data {
-		if y < td.Height && y < int(td.Table.Card.DisplayRect.H/32) {
-			for x := range td.Data[y] {
-				if x < td.Width && x < int(td.Table.Card.DisplayRect.W/32) {
+	columns := td.VisibleColumns()
+
+	for vy, y := range td.VisibleRows() {
+		if vy < int(td.Table.Card.DisplayRect.H/32) {
+			for vx, x := range columns {
+				if vx < int(td.Table.Card.DisplayRect.W/32) {
 					td.Data[y][x].Button.Draw()
 				}
 			}
 		}
 	}
 
+	td.drawCellContents()
+
 	if !td.showing() {
 		return
 	}
@@ -4408,7 +5012,7 @@ func (td *TableData) Draw() {
 		if heading.Dragging {
 			continue
 		}
-		if i < td.Height {
+		if i < td.Height && !td.RowHidden(i) {
 			heading.Draw()
 		}
 
@@ -4422,7 +5026,7 @@ func (td *TableData) Draw() {
 		if heading.Dragging || heading.verticalEditing {
 			continue
 		}
-		if i < td.Width {
+		if i < td.Width && !td.ColumnHidden(i) {
 			heading.Draw()
 		}
 	}
@@ -4492,9 +5096,10 @@ func (td *TableData) ReorderData(from, to int, vertical bool) {
 
 func (td *TableData) SwapData(x1, y1, x2, y2 int) {
 
-	v := td.Value(x1, y1)
-	td.SetValue(x1, y1, td.Value(x2, y2))
-	td.SetValue(x2, y2, v)
+	a, b := td.Data[y1][x1], td.Data[y2][x2]
+	a.Value, b.Value = b.Value, a.Value
+	a.Type, b.Type = b.Type, a.Type
+	a.Text, b.Text = b.Text, a.Text
 
 }
 
@@ -4503,62 +5108,64 @@ func (td *TableData) TableHeaderDropped(label *DraggableLabel) {
 }
 
 func (td *TableData) RowCompletion(index int, column bool) float32 {
+	return td.rowCompletion(newTableEvaluator(td), index, column)
+}
+
+func (td *TableData) rowCompletion(ev *tableEvaluator, index int, column bool) float32 {
 
-	if td.ValueDisplayMode != ValueDisplayModeCheck {
+	completion, max, hasCheckboxes := td.rowCompletionCounts(ev, index, column)
+
+	// Fix table cards being stuck at 0 if you cycle through a row or column with all X's
+	if max == 0 {
+		if hasCheckboxes && td.ValueDisplayMode == ValueDisplayModeCheck {
+			return 1
+		}
 		return 0
 	}
 
-	completion := float32(0)
-	max := float32(0)
+	return completion / max
+
+}
+
+// rowCompletionCounts returns how complete a row or column is, how complete it could be, and whether it has any
+// checkbox cells.
+func (td *TableData) rowCompletionCounts(ev *tableEvaluator, index int, column bool) (completion, max float32, hasCheckboxes bool) {
 
+	count := td.Width
 	if column {
+		count = td.Height
+	}
 
-		for i := 0; i < td.Height; i++ {
-			v := td.Data[i][index].Value
-			if v == 1 {
-				completion++
-			}
-			if v != 2 {
-				max++
-			}
+	for i := 0; i < count; i++ {
+
+		x, y := i, index
+		if column {
+			x, y = index, i
 		}
 
-	} else {
+		c, m := td.cellCompletion(ev, x, y)
+		completion += c
+		max += m
 
-		for i := 0; i < td.Width; i++ {
-			v := td.Data[index][i].Value
-			if v == 1 {
-				completion++
-			}
-			if v != 2 {
-				max++
-			}
+		if td.Data[y][x].Type == TableCellTypeCheckbox {
+			hasCheckboxes = true
 		}
 
 	}
 
-	// Fix table cards being stuck at 0 if you cycle through a row or column with all X's
-	if max == 0 {
-		return 1
-	}
-
-	return completion / max
+	return completion, max, hasCheckboxes
 
 }
 
 func (td *TableData) CompletionLevel() float32 {
 
-	if td.ValueDisplayMode != ValueDisplayModeCheck {
-		return 0
-	}
-
 	completion := float32(0)
+	ev := newTableEvaluator(td)
 
 	for y := 0; y < td.Height; y++ {
 		for x := 0; x < td.Width; x++ {
-			if td.Data[y][x].Value == 1 {
-				completion++
-			}
+			c, _ := td.cellCompletion(ev, x, y)
+			completion += c
 		}
 
 	}
@@ -4569,17 +5176,13 @@ func (td *TableData) CompletionLevel() float32 {
 
 func (td *TableData) MaximumCompletionLevel() float32 {
 
-	if td.ValueDisplayMode != ValueDisplayModeCheck {
-		return 0
-	}
-
 	max := float32(0)
+	ev := newTableEvaluator(td)
 
 	for y := 0; y < td.Height; y++ {
 		for x := 0; x < td.Width; x++ {
-			if td.Data[y][x].Value != 2 {
-				max++
-			}
+			_, m := td.cellCompletion(ev, x, y)
+			max += m
 		}
 
 	}
@@ -4616,12 +5219,38 @@ func (td *TableData) Serialize() string {
 	dataStr, _ = sjson.Set(dataStr, "width", td.Width)
 	dataStr, _ = sjson.Set(dataStr, "height", td.Height)
 	dataStr, _ = sjson.Set(dataStr, "mode", td.ValueDisplayMode)
+
+	// Cell types and text are only stored for tables that use them
+	types := [][]int{}
+	text := [][]string{}
+	typed := false
+
+	for y := 0; y < td.Height; y++ {
+		types = append(types, []int{})
+		text = append(text, []string{})
+		for x := 0; x < td.Width; x++ {
+			cell := td.Data[y][x]
+			types[y] = append(types[y], cell.Type)
+			text[y] = append(text[y], cell.Text)
+			if cell.Type != TableCellTypeCheckbox || cell.Text != "" {
+				typed = true
+			}
+		}
+	}
+
+	if typed {
+		dataStr, _ = sjson.Set(dataStr, "types", types)
+		dataStr, _ = sjson.Set(dataStr, "text", text)
+	}
+
 	return dataStr
 
 }
 
 func (td *TableData) Deserialize(data string) {
 
+	td.filtersOutdated = true
+
 	if data != "" {
 
 		contents := gjson.Get(data, "contents")
@@ -4642,6 +5271,29 @@ func (td *TableData) Deserialize(data string) {
 			}
 		}
 
+		for _, row := range td.Data {
+			for _, cell := range row {
+				cell.Type = TableCellTypeCheckbox
+				cell.Text = ""
+			}
+		}
+
+		for y, row := range gjson.Get(data, "types").Array() {
+			for x, cellType := range row.Array() {
+				if y < len(td.Data) && x < len(td.Data[y]) {
+					td.Data[y][x].Type = int(cellType.Int())
+				}
+			}
+		}
+
+		for y, row := range gjson.Get(data, "text").Array() {
+			for x, text := range row.Array() {
+				if y < len(td.Data) && x < len(td.Data[y]) {
+					td.Data[y][x].Text = text.String()
+				}
+			}
+		}
+
 		for i, rn := range gjson.Get(data, "rows").Array() {
 			if i >= len(td.RowHeadings) {
 				break
@@ -4747,6 +5399,7 @@ func (td *TableData) Clear() {
 	for y := 0; y < len(td.Data); y++ {
 		for x := 0; x < len(td.Data[y]); x++ {
 			td.Data[y][x].Value = 0
+			td.Data[y][x].Text = ""
 		}
 	}
 
@@ -4758,6 +5411,8 @@ func (td *TableData) Clear() {
 
 func (td *TableData) ForceUndoStateCreation() {
 
+	td.filtersOutdated = true
+
 	// We have to manually do this because this is called from a menu before the changed state is set to false, in td.Update().
 	contents := td.Table.Card.Properties.Get("contents")
 	contents.SetRaw(td.Table.TableData.Serialize())
@@ -4902,7 +5557,7 @@ func (tc *TableContents) ReceiveMessage(msg *Message) {
 	if msg.Type == MessageCardResizeCompleted {
 		w := int(tc.Card.Rect.W / 32)
 		h := int(tc.Card.Rect.H / 32)
-		tc.TableData.Resize(w, h)
+		tc.TableData.ResizeVisible(w, h)
 		tc.Card.Properties.Get("contents").SetRaw(tc.TableData.Serialize())
 	} else if msg.Type == MessageUndoRedo {
 		tc.TableData.Deserialize(tc.Card.Properties.Get("contents").AsString())
@@ -4956,6 +5611,16 @@ const (
 	InternetCardUpdateOptionAlways              = "Always"
 	InternetCardUpdateOptionWhenRecordingInputs = "Only When Recording Input"
 	InternetCardUpdateOptionWhenSelected        = "Only When Selected"
+
+	InternetCardWatch30Seconds = "30 Seconds"
+	InternetCardWatch1Minute   = "1 Minute"
+	InternetCardWatch5Minutes  = "5 Minutes"
+	InternetCardWatch15Minutes = "15 Minutes"
+	InternetCardWatch1Hour     = "1 Hour"
+
+	InternetCardProfileGlobal  = "Global Profile"
+	InternetCardProfileProject = "Project Profile"
+	InternetCardProfileCard    = "Isolated Card Profile"
 )
 
 type InternetContents struct {
@@ -4973,6 +5638,16 @@ type InternetContents struct {
 	ScrollbarUpdateTimer time.Time
 	URLCheckTimer        time.Time
 	SetURL               string
+
+	FrozenTexture *sdl.Texture // A static snapshot of the page, displayed instead of a live BrowserTab while the card is frozen
+	Favicon       *Favicon
+
+	WatchTimer   time.Time
+	WatchFlash   time.Time // When the watched page last changed, for flashing the card
+	watchChanged atomic.Bool
+	watchLock    sync.Mutex
+	watchKey     string // The URL and selector the last watched text was read from
+	watchText    string
 }
 
 func NewInternetContents(card *Card) *InternetContents {
@@ -4987,57 +5662,15 @@ func NewInternetContents(card *Card) *InternetContents {
 	web.HorizontalScrollbar.DrawOnlyWhenMouseIsClose = true
 
 	web.VerticalScrollbar.OnValueSet = func() {
-
 		if web.BrowserTab != nil {
-
-			tv := strconv.FormatFloat(float64(web.VerticalScrollbar.TargetValue), 'f', -1, 32)
-
-			web.BrowserTab.Do(
-
-				chromedp.Evaluate(`
-			
-				var body = document.body;
-				var html = document.documentElement;
-				
-				// Get the max height of the webpage
-				var pageHeight = Math.max(body.scrollHeight, body.offsetHeight, html.clientHeight, html.scrollHeight, html.offsetHeight);
-		
-				var targetY = `+tv+` * pageHeight - window.innerHeight;
-				if (targetY < 0) {
-					targetY = 0;
-				}
-		
-				window.scroll(window.scrollX, targetY)`, nil),
-			)
-
+			web.BrowserTab.ScrollToFraction(-1, float64(web.VerticalScrollbar.TargetValue))
 		}
-
 	}
 
 	web.HorizontalScrollbar.OnValueSet = func() {
-
 		if web.BrowserTab != nil {
-
-			tv := strconv.FormatFloat(float64(web.HorizontalScrollbar.TargetValue), 'f', -1, 32)
-
-			web.BrowserTab.Do(chromedp.Evaluate(`
-
-		var body = document.body;
-		var html = document.documentElement;
-
-		// Get the max height of the webpage
-		var pageWidth = Math.max(body.scrollWidth, body.offsetWidth, html.clientWidth, html.scrollWidth, html.offsetWidth);
-		
-		var targetX = `+tv+` * pageWidth - window.innerWidth;
-		if (targetX < 0) {
-			targetX = 0;
-			}
-			
-			window.scroll(targetX, window.scrollY)
-			`, nil))
-
+			web.BrowserTab.ScrollToFraction(float64(web.HorizontalScrollbar.TargetValue), -1)
 		}
-
 	}
 
 	web.Card.Properties.SetDefault("size", InternetCardSize256)
@@ -5046,6 +5679,23 @@ func NewInternetContents(card *Card) *InternetContents {
 	web.Card.Properties.SetDefault("update only when", InternetCardUpdateOptionWhenSelected)
 	web.Card.Properties.SetDefault("url", globals.Settings.Get(SettingsBrowserDefaultURL).AsString())
 	web.Card.Properties.Get("url").OnlySerializeInSaves = true
+	web.Card.Properties.SetDefault("frozen", false)
+	web.Card.Properties.SetDefault("zoom", 0.0) // 0 means the global web card zoom level is used
+	web.Card.Properties.SetDefault("browser profile", InternetCardProfileGlobal)
+	web.Card.Properties.SetDefault("watch", false)
+	web.Card.Properties.SetDefault("watch interval", InternetCardWatch1Minute)
+	web.Card.Properties.SetDefault("watch selector", "")
+	web.Card.Properties.SetDefault("watch notify", false)
+
+	if web.Card.Properties.Has("session") {
+		web.Card.Properties.Get("session").OnlySerializeInSaves = true
+	}
+
+	// Frozen cards never ask for their profile directory, so the profile ID has to be kept in use explicitly for the
+	// card to keep its profile when saved
+	if profileID := web.Card.Properties.GetIfExists("browser profile id"); profileID != nil {
+		profileID.InUse = true
+	}
 	// web.Card.Properties.SetDefault("aspect ratio width", 1)
 	// web.Card.Properties.SetDefault("aspect ratio height", 1)
 
@@ -5104,24 +5754,24 @@ func NewInternetContents(card *Card) *InternetContents {
 		case "x1":
 			button = NewIconButtonTintless(
 				0, 0, &sdl.FRect{X: 304, Y: 192, W: 32, H: 32}, globals.GUITexture, true, func() {
-					web.Card.Rect.W = float32(web.BrowserTab.BufferWidth)
-					web.Card.Rect.H = float32(web.BrowserTab.BufferHeight)
+					web.Card.Rect.W = float32(web.Width())
+					web.Card.Rect.H = float32(web.Height())
 					web.Card.LockPosition()
 				},
 			)
 		case "x2":
 			button = NewIconButtonTintless(
 				0, 0, &sdl.FRect{X: 304, Y: 224, W: 32, H: 32}, globals.GUITexture, true, func() {
-					web.Card.Rect.W = float32(web.BrowserTab.BufferWidth) * 2
-					web.Card.Rect.H = float32(web.BrowserTab.BufferHeight) * 2
+					web.Card.Rect.W = float32(web.Width()) * 2
+					web.Card.Rect.H = float32(web.Height()) * 2
 					web.Card.LockPosition()
 				},
 			)
 		case "x3":
 			button = NewIconButtonTintless(
 				0, 0, &sdl.FRect{X: 304, Y: 256, W: 32, H: 32}, globals.GUITexture, true, func() {
-					web.Card.Rect.W = float32(web.BrowserTab.BufferWidth) * 3
-					web.Card.Rect.H = float32(web.BrowserTab.BufferHeight) * 3
+					web.Card.Rect.W = float32(web.Width()) * 3
+					web.Card.Rect.H = float32(web.Height()) * 3
 					web.Card.LockPosition()
 				},
 			)
@@ -5137,7 +5787,9 @@ func NewInternetContents(card *Card) *InternetContents {
 		case "home":
 			button = NewIconButtonTintless(
 				0, 0, &sdl.FRect{X: 336, Y: 192, W: 32, H: 32}, globals.GUITexture, true, func() {
-					web.BrowserTab.NavigateHome()
+					if web.BrowserTab != nil {
+						web.BrowserTab.NavigateHome()
+					}
 				},
 			)
 
@@ -5159,9 +5811,19 @@ func NewInternetContents(card *Card) *InternetContents {
 
 	// web.Buttons = []*IconButton{}
 
-	if err := web.ReinitContext(); err != nil {
-		globals.EventLog.Log("error (re-)initializing context:"+err.Error(), true)
-		return nil
+	if web.Frozen() {
+		web.Card.Properties.Get("frozen image").OnlySerializeInSaves = true
+		if err := web.loadFrozenTexture(); err != nil {
+			globals.EventLog.Log("error loading frozen web page snapshot: %s", true, err.Error())
+			web.Card.Properties.Get("frozen").Set(false)
+		}
+	}
+
+	if !web.Frozen() {
+		if err := web.ReinitContext(); err != nil {
+			globals.EventLog.Log("error (re-)initializing context:"+err.Error(), true)
+			return nil
+		}
 	}
 
 	// web.Navigate(web.Card.Properties.Get("url").AsString())
@@ -5191,7 +5853,14 @@ func (w *InternetContents) ReinitContext() error {
 		log.Println("browser tab " + url + " created")
 
 		w.BrowserTab = tab
-		w.BrowserTab.Navigate(url)
+
+		// Restore the saved session if it's still on the same page; otherwise, the URL has changed since then, so just
+		// go to the URL.
+		if history, index, scrollX, scrollY := w.savedSession(); len(history) > 0 && history[index] == url {
+			w.BrowserTab.RestoreSession(history, index, scrollX, scrollY)
+		} else {
+			w.BrowserTab.Navigate(url)
+		}
 
 		log.Println("Navigate done")
 
@@ -5201,9 +5870,110 @@ func (w *InternetContents) ReinitContext() error {
 
 }
 
+// BrowserProfileDirectory returns the user-data directory the card's browser tab should use, depending on whether
+// it uses the global profile, the project's profile, or a profile of its own.
+func (w *InternetContents) BrowserProfileDirectory() string {
+
+	switch w.Card.Properties.Get("browser profile").AsString() {
+
+	case InternetCardProfileProject:
+		return w.Card.Page.Project.BrowserProfileDirectory()
+
+	case InternetCardProfileCard:
+		// Card IDs aren't stable between loads, so each card gets a random ID to identify its profile directory.
+		profileID := w.Card.Properties.Get("browser profile id")
+		if profileID.AsString() == "" {
+			profileID.SetRaw(strconv.FormatInt(time.Now().UnixNano(), 36))
+		}
+		return filepath.Join(w.Card.Page.Project.BrowserProfileDirectory(), "cards", profileID.AsString())
+
+	}
+
+	return globals.Settings.Get(SettingsBrowserUserDataPath).AsString()
+
+}
+
+// RestartBrowserTab recreates the card's browser tab (e.g. after changing its browser profile).
+func (w *InternetContents) RestartBrowserTab() {
+
+	if w.BrowserTab == nil {
+		return
+	}
+
+	w.saveSession()
+
+	w.BrowserTab.Destroy()
+	w.BrowserTab = nil
+
+	if err := w.ReinitContext(); err != nil {
+		globals.EventLog.Log("error (re-)initializing context:"+err.Error(), true)
+	}
+
+}
+
+// saveSession stores the card's navigation history and scroll position in its properties so that they can be
+// restored when the project is reopened.
+func (w *InternetContents) saveSession() {
+
+	if w.BrowserTab == nil || !w.BrowserTab.Valid() {
+		return
+	}
+
+	history, index, scrollX, scrollY := w.BrowserTab.SessionState()
+
+	if len(history) == 0 || index < 0 {
+		return
+	}
+
+	session, _ := sjson.Set("{}", "history", history)
+	session, _ = sjson.Set(session, "index", index)
+	session, _ = sjson.Set(session, "scroll", []float64{scrollX, scrollY})
+
+	prop := w.Card.Properties.Get("session")
+	prop.OnlySerializeInSaves = true
+	prop.SetRaw(session) // No need for an undo state for this
+
+}
+
+func (w *InternetContents) savedSession() (history []string, index int, scrollX, scrollY float64) {
+
+	if !w.Card.Properties.Has("session") {
+		return
+	}
+
+	session := gjson.Parse(w.Card.Properties.Get("session").AsString())
+
+	for _, entry := range session.Get("history").Array() {
+		history = append(history, entry.String())
+	}
+
+	index = int(session.Get("index").Int())
+
+	if index < 0 || index >= len(history) {
+		return nil, 0, 0, 0
+	}
+
+	scroll := session.Get("scroll").Array()
+	if len(scroll) == 2 {
+		scrollX = scroll[0].Float()
+		scrollY = scroll[1].Float()
+	}
+
+	return
+
+}
+
+// ZoomLevel returns the zoom level of the card's page, which is either its own or the global web card zoom level.
+func (w *InternetContents) ZoomLevel() float64 {
+	if zoom := w.Card.Properties.Get("zoom").AsFloat(); zoom > 0 {
+		return zoom
+	}
+	return globals.Settings.Get(SettingsWebCardZoomLevel).AsFloat()
+}
+
 func (w *InternetContents) ReloadPage() {
 	if w.BrowserTab != nil {
-		w.BrowserTab.Do(chromedp.Reload())
+		w.BrowserTab.Reload()
 	}
 }
 
@@ -5325,15 +6095,40 @@ func (w *InternetContents) Update() {
 
 	///////
 
+	if w.RecordInput && w.Card.Collapsed == CollapsedShade {
+		w.DisableRecordInput()
+		globals.State = StateNeutral
+	}
+
+	if w.Frozen() {
+
+		if !globals.Keybindings.Pressed(KBUnlockImageASR) && w.Card.Collapsed == CollapsedNone {
+			w.Card.LockResizingAspectRatio = float32(w.Height()) / float32(w.Width())
+		}
+
+		if w.Card.selected {
+			for i, b := range w.Buttons {
+				b.Rect.X = w.Card.DisplayRect.X + float32(i*32)
+				b.Rect.Y = w.Card.DisplayRect.Y - 32
+				b.Update()
+			}
+		}
+
+		return
+
+	}
+
 	// True for a frame before the card is restored after undoing a deletion
 	if w.BrowserTab == nil {
 		return
 	}
 
-	if !globals.Keybindings.Pressed(KBUnlockImageASR) {
+	if !globals.Keybindings.Pressed(KBUnlockImageASR) && w.Card.Collapsed == CollapsedNone {
 		w.Card.LockResizingAspectRatio = float32(w.BrowserTab.BufferHeight) / float32(w.BrowserTab.BufferWidth)
 	}
 
+	w.updateWatch()
+
 	if w.BrowserTab != nil && w.SetURL != w.BrowserTab.CurrentURL {
 		w.SetURL = w.BrowserTab.CurrentURL
 		w.Card.Properties.Get("url").Set(w.BrowserTab.CurrentURL)
@@ -5357,6 +6152,13 @@ func (w *InternetContents) Update() {
 
 				globals.State = StateTextEditing // So that pressing keys doesn't trigger shortcuts
 
+				// The text-only browser can only be scrolled and navigated through
+				interactive := w.BrowserTab.Backend.Interactive()
+
+				if interactive {
+					w.handleEditingShortcuts()
+				}
+
 				modifiers := map[sdl.Keycode]input.Modifier{
 					SDLK_LSHIFT: input.ModifierShift,
 					SDLK_RSHIFT: input.ModifierShift,
@@ -5404,23 +6206,27 @@ func (w *InternetContents) Update() {
 
 					if !w.HorizontalScrollbar.Dragging && !w.VerticalScrollbar.Dragging {
 
-						for sdlButton, chromeDPButtonString := range map[sdl.MouseButtonFlags]string{
-							sdl.BUTTON_LEFT:   "left",
-							sdl.BUTTON_MIDDLE: "middle",
-							sdl.BUTTON_RIGHT:  "right",
-						} {
-
-							button := globals.Mouse.Button(sdlButton)
-							if button.Pressed() {
-								w.BrowserTab.Do(w.makeMouseAction(bx, by, input.MousePressed, chromedp.Button(chromeDPButtonString), chromedp.ButtonModifiers(activeMod)))
-								w.BrowserTab.ForceRefresh.Store(true)
-								button.Consume()
-							} else if button.HeldRaw() {
-								w.BrowserTab.Do(w.makeMouseAction(bx, by, input.MouseMoved, chromedp.Button(chromeDPButtonString), chromedp.ButtonModifiers(activeMod)))
-								w.BrowserTab.ForceRefresh.Store(true)
-							} else if button.ReleasedRaw() {
-								w.BrowserTab.Do(w.makeMouseAction(bx, by, input.MouseReleased, chromedp.Button(chromeDPButtonString), chromedp.ButtonModifiers(activeMod)))
-								w.BrowserTab.ForceRefresh.Store(true)
+						if interactive {
+
+							for sdlButton, chromeDPButtonString := range map[sdl.MouseButtonFlags]string{
+								sdl.BUTTON_LEFT:   "left",
+								sdl.BUTTON_MIDDLE: "middle",
+								sdl.BUTTON_RIGHT:  "right",
+							} {
+
+								button := globals.Mouse.Button(sdlButton)
+								if button.Pressed() {
+									w.BrowserTab.Do(w.makeMouseAction(bx, by, input.MousePressed, chromedp.Button(chromeDPButtonString), chromedp.ButtonModifiers(activeMod)))
+									w.BrowserTab.ForceRefresh.Store(true)
+									button.Consume()
+								} else if button.HeldRaw() {
+									w.BrowserTab.Do(w.makeMouseAction(bx, by, input.MouseMoved, chromedp.Button(chromeDPButtonString), chromedp.ButtonModifiers(activeMod)))
+									w.BrowserTab.ForceRefresh.Store(true)
+								} else if button.ReleasedRaw() {
+									w.BrowserTab.Do(w.makeMouseAction(bx, by, input.MouseReleased, chromedp.Button(chromeDPButtonString), chromedp.ButtonModifiers(activeMod)))
+									w.BrowserTab.ForceRefresh.Store(true)
+								}
+
 							}
 
 						}
@@ -5439,7 +6245,7 @@ func (w *InternetContents) Update() {
 
 				}
 
-				if len(globals.InputText) > 0 {
+				if len(globals.InputText) > 0 && interactive {
 					w.BrowserTab.Do(chromedp.KeyEvent(string(globals.InputText)))
 					w.BrowserTab.ForceRefresh.Store(true)
 				}
@@ -5545,7 +6351,7 @@ func (w *InternetContents) Update() {
 						}
 					}
 
-					if key := globals.Keyboard.Key(sdlKey); key.Pressed() {
+					if key := globals.Keyboard.Key(sdlKey); key.Pressed() && interactive {
 						w.BrowserTab.Do(chromedp.KeyEvent(chromeDPKey, chromedp.KeyModifiers(modifierSlice...)))
 						key.Consume()
 						w.BrowserTab.ForceRefresh.Store(true)
@@ -5565,7 +6371,7 @@ func (w *InternetContents) Update() {
 						}
 					}
 
-					if key := globals.Keyboard.Key(sdlKey); key.Pressed() && len(modifierSlice) > 0 {
+					if key := globals.Keyboard.Key(sdlKey); key.Pressed() && len(modifierSlice) > 0 && interactive {
 						w.BrowserTab.Do(chromedp.KeyEvent(chromeDPKey, chromedp.KeyModifiers(modifierSlice...)))
 						key.Consume()
 						w.BrowserTab.ForceRefresh.Store(true)
@@ -5578,16 +6384,7 @@ func (w *InternetContents) Update() {
 
 				if time.Since(w.ScrollbarUpdateTimer) > time.Second/4 && w.HorizontalScrollbar.MouseClose {
 
-					scrollXPerc := 0.0
-
-					chromedp.Run(w.BrowserTab.Context, chromedp.Evaluate(`
-
-				var body = document.body;
-				var html = document.documentElement;
-				var pageWidth = Math.max(body.scrollWidth, body.offsetWidth, html.clientWidth, html.scrollWidth, html.offsetWidth);
-	
-				window.scrollX / (pageWidth - window.innerWidth);
-				`, &scrollXPerc))
+					scrollXPerc, _ := w.BrowserTab.Scroll().Fraction()
 
 					w.HorizontalScrollbar.TargetValue = float32(scrollXPerc)
 
@@ -5603,16 +6400,7 @@ func (w *InternetContents) Update() {
 
 				if time.Since(w.ScrollbarUpdateTimer) > time.Second/4 && w.VerticalScrollbar.MouseClose {
 
-					scrollYPerc := 0.0
-
-					chromedp.Run(w.BrowserTab.Context, chromedp.Evaluate(`
-
-				var body = document.body;
-				var html = document.documentElement;
-				var pageHeight = Math.max(body.scrollHeight, body.offsetHeight, html.clientHeight, html.scrollHeight, html.offsetHeight);
-	
-				window.scrollY / (pageHeight - window.innerHeight);
-				`, &scrollYPerc))
+					_, scrollYPerc := w.BrowserTab.Scroll().Fraction()
 
 					w.VerticalScrollbar.TargetValue = float32(scrollYPerc)
 
@@ -5645,6 +6433,181 @@ func (w *InternetContents) Update() {
 
 }
 
+// WatchInterval returns how often the card's page is checked for changes in watch mode.
+func (w *InternetContents) WatchInterval() time.Duration {
+
+	switch w.Card.Properties.Get("watch interval").AsString() {
+	case InternetCardWatch30Seconds:
+		return time.Second * 30
+	case InternetCardWatch5Minutes:
+		return time.Minute * 5
+	case InternetCardWatch15Minutes:
+		return time.Minute * 15
+	case InternetCardWatch1Hour:
+		return time.Hour
+	}
+
+	return time.Minute
+
+}
+
+// updateWatch periodically re-renders the page and reads its visible text (or the text of the element matching the
+// watch selector); when that text changes from the last check, the card flashes and the change is reported.
+func (w *InternetContents) updateWatch() {
+
+	if w.watchChanged.CompareAndSwap(true, false) {
+
+		w.WatchFlash = time.Now()
+
+		url := w.BrowserTab.CurrentURL
+		globals.EventLog.Log("Watched web page [ %s ] has changed.", false, url)
+
+		if w.Card.Properties.Get("watch notify").AsBool() {
+			beeep.Notify("MasterPlan", "Watched web page has changed:\n"+url, "")
+		}
+
+	}
+
+	if !w.Card.Properties.Get("watch").AsBool() || !w.BrowserTab.Valid() || w.BrowserTab.LoadingWebpage.Load() {
+		return
+	}
+
+	if time.Since(w.WatchTimer) < w.WatchInterval() {
+		return
+	}
+
+	w.WatchTimer = time.Now()
+
+	selector := strings.TrimSpace(w.Card.Properties.Get("watch selector").AsString())
+
+	// The text-only browser can only watch the page as a whole
+	if !w.BrowserTab.Backend.Interactive() {
+		selector = ""
+	}
+
+	key := w.BrowserTab.CurrentURL + "\n" + selector
+
+	w.BrowserTab.Do(BackendAction(func(ctx context.Context) error {
+
+		text, err := w.BrowserTab.Backend.Text(ctx, selector)
+		if err != nil {
+			return err
+		}
+
+		w.watchLock.Lock()
+		defer w.watchLock.Unlock()
+
+		// Navigating elsewhere or changing the selector just starts watching the new text
+		if w.watchKey == key && w.watchText != text {
+			w.watchChanged.Store(true)
+		}
+
+		w.watchKey = key
+		w.watchText = text
+
+		return nil
+
+	}))
+
+	w.BrowserTab.ForceRefresh.Store(true)
+
+}
+
+// drawWatchFlash flashes the card's outline for a few seconds after its watched page changes.
+func (w *InternetContents) drawWatchFlash() {
+
+	flashTime := time.Since(w.WatchFlash)
+
+	if w.WatchFlash.IsZero() || flashTime > time.Second*5 {
+		return
+	}
+
+	color := ColorYellow.Clone()
+	color[3] = uint8(127 + math.Sin(flashTime.Seconds()*math.Pi*4)*127)
+
+	dst := w.Card.Page.Project.Camera.TranslateRect(w.Card.DisplayRect)
+	ThickRect(int32(dst.X-4), int32(dst.Y-4), int32(dst.W+8), int32(dst.H+8), 4, color)
+
+}
+
+// webSelectedTextJS returns the selected text on the page, including text selected within a text field.
+const webSelectedTextJS = `(function() {
+	var el = document.activeElement;
+	if (el && (el.tagName == "INPUT" || el.tagName == "TEXTAREA") && typeof el.selectionStart == "number") {
+		return el.value.substring(el.selectionStart, el.selectionEnd);
+	}
+	return window.getSelection().toString();
+})()`
+
+// handleEditingShortcuts intercepts the text editing shortcuts while input is passed through to the page. Headless
+// Chrome can't access the system clipboard, so copying and pasting goes through MasterPlan instead.
+func (w *InternetContents) handleEditingShortcuts() {
+
+	kb := globals.Keybindings
+
+	execCommand := func(command string) chromedp.Action {
+		return chromedp.Evaluate(`document.execCommand("`+command+`")`, nil)
+	}
+
+	copySelection := func(cut bool) chromedp.Action {
+
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+
+			selected := ""
+
+			if err := chromedp.Evaluate(webSelectedTextJS, &selected).Do(ctx); err != nil {
+				return err
+			}
+
+			if selected == "" {
+				return nil
+			}
+
+			clipboard.Write(clipboard.FmtText, []byte(selected))
+
+			if cut {
+				return execCommand("delete").Do(ctx)
+			}
+
+			return nil
+
+		})
+
+	}
+
+	var shortcut string
+
+	if kb.Pressed(KBPasteText) {
+
+		shortcut = KBPasteText
+		if text := clipboard.Read(clipboard.FmtText); len(text) > 0 {
+			w.BrowserTab.Do(input.InsertText(string(text)))
+		}
+
+	} else if kb.Pressed(KBCopyText) {
+		shortcut = KBCopyText
+		w.BrowserTab.Do(copySelection(false))
+	} else if kb.Pressed(KBCutText) {
+		shortcut = KBCutText
+		w.BrowserTab.Do(copySelection(true))
+	} else if kb.Pressed(KBSelectAllText) {
+		shortcut = KBSelectAllText
+		w.BrowserTab.Do(execCommand("selectAll"))
+	} else if kb.Pressed(KBRedo) {
+		shortcut = KBRedo
+		w.BrowserTab.Do(execCommand("redo"))
+	} else if kb.Pressed(KBUndo) {
+		shortcut = KBUndo
+		w.BrowserTab.Do(execCommand("undo"))
+	}
+
+	if shortcut != "" {
+		kb.Shortcuts[shortcut].ConsumeKeys()
+		w.BrowserTab.ForceRefresh.Store(true)
+	}
+
+}
+
 func (w *InternetContents) makeMouseAction(x, y float64, inputType input.MouseType, opts ...chromedp.MouseOption) chromedp.ActionFunc {
 
 	return chromedp.ActionFunc(func(ctx context.Context) error {
@@ -5706,7 +6669,21 @@ func (w *InternetContents) Draw() {
 
 	camera := w.Card.Page.Project.Camera
 
-	if w.BrowserTab != nil {
+	if w.Card.Collapsed == CollapsedShade {
+
+		w.drawShade()
+
+	} else if w.Frozen() {
+
+		if w.FrozenTexture != nil {
+			globals.Renderer.RenderTexture(w.FrozenTexture, nil, camera.TranslateRect(w.Card.DisplayRect))
+		}
+
+		if w.Card.selected {
+			DrawLabel(camera.TranslatePoint(Vector{w.Card.DisplayRect.X + 4, w.Card.DisplayRect.Y + 4}), 1, "Frozen", getThemeColor(GUIMenuColor))
+		}
+
+	} else if w.BrowserTab != nil {
 
 		w.BrowserTab.UpdateTexture()
 
@@ -5750,11 +6727,189 @@ func (w *InternetContents) Draw() {
 
 	}
 
-	if w.Card.selected {
+	if w.Card.selected && w.BrowserTab != nil && w.Card.Collapsed == CollapsedNone {
 		w.HorizontalScrollbar.Draw()
 		w.VerticalScrollbar.Draw()
 	}
 
+	w.drawWatchFlash()
+
+}
+
+// drawShade draws the collapsed form of the card: a single line with the page's favicon and URL.
+func (w *InternetContents) drawShade() {
+
+	camera := w.Card.Page.Project.Camera
+
+	url := w.Card.Properties.Get("url").AsString()
+
+	if w.Favicon == nil || w.Favicon.Origin != URLOrigin(url) {
+		if w.Favicon != nil {
+			w.Favicon.Destroy()
+		}
+		w.Favicon = NewFavicon(url)
+	}
+
+	iconDst := camera.TranslateRect(&sdl.FRect{w.Card.DisplayRect.X + 8, w.Card.DisplayRect.Y + 8, 16, 16})
+
+	if tex := w.Favicon.Texture(); tex != nil {
+		globals.Renderer.RenderTexture(tex, nil, iconDst)
+	} else {
+		src := *icons[ContentTypeInternet]
+		globals.GUITexture.Texture.SetColorMod(getThemeColor(GUIFontColor).RGB())
+		globals.GUITexture.Texture.SetAlphaMod(255)
+		globals.Renderer.RenderTexture(globals.GUITexture.Texture, &src, iconDst)
+	}
+
+	text := []rune(url)
+	maxWidth := w.Card.DisplayRect.W - 40
+
+	if globals.TextRenderer.MeasureText(text, 1).X > maxWidth {
+		for len(text) > 0 && globals.TextRenderer.MeasureText(append(text, []rune("...")...), 1).X > maxWidth {
+			text = text[:len(text)-1]
+		}
+		text = append(text, []rune("...")...)
+	}
+
+	fontColor := getThemeColor(GUIFontColor)
+	if w.Card.FontColor != nil {
+		fontColor = w.Card.FontColor
+	}
+
+	globals.TextRenderer.QuickRenderText(string(text), camera.TranslatePoint(Vector{w.Card.DisplayRect.X + 32, w.Card.DisplayRect.Y}), 1, fontColor, nil, AlignLeft)
+
+}
+
+// Frozen returns if the card is displaying a static snapshot of its page rather than a live browser tab.
+func (w *InternetContents) Frozen() bool {
+	return w.Card.Properties.Get("frozen").AsBool()
+}
+
+// ToggleFreeze freezes or unfreezes the card. Freezing captures the currently rendered page as a static image that's
+// saved with the project and shuts down the card's browser tab; unfreezing spins the tab back up again.
+func (w *InternetContents) ToggleFreeze() {
+
+	if w.Frozen() {
+		if err := w.unfreeze(); err != nil {
+			globals.EventLog.Log("error (re-)initializing context: %s", true, err.Error())
+		} else {
+			globals.EventLog.Log("Web card unfrozen.", false)
+		}
+	} else {
+		if err := w.freeze(); err != nil {
+			globals.EventLog.Log("Couldn't freeze web card: %s", true, err.Error())
+		} else {
+			globals.EventLog.Log("Web card frozen.", false)
+		}
+	}
+
+}
+
+func (w *InternetContents) freeze() error {
+
+	if w.BrowserTab == nil {
+		return errors.New("the card has no active browser tab")
+	}
+
+	w.BrowserTab.Pause.Lock()
+	snapshot := append([]byte{}, w.BrowserTab.ImageBuffer...)
+	w.BrowserTab.Pause.Unlock()
+
+	if len(snapshot) == 0 {
+		return errors.New("the page hasn't been rendered yet")
+	}
+
+	frozenImage := w.Card.Properties.Get("frozen image")
+	frozenImage.OnlySerializeInSaves = true
+	frozenImage.Set(base64.StdEncoding.EncodeToString(snapshot))
+
+	if err := w.loadFrozenTexture(); err != nil {
+		w.Card.Properties.Remove("frozen image")
+		return err
+	}
+
+	w.Card.Properties.Get("frozen").Set(true)
+
+	if w.RecordInput {
+		w.DisableRecordInput()
+		globals.State = StateNeutral
+	}
+
+	w.BrowserTab.Destroy()
+	w.BrowserTab = nil
+
+	return nil
+
+}
+
+func (w *InternetContents) unfreeze() error {
+
+	w.Card.Properties.Get("frozen").Set(false)
+	w.Card.Properties.Remove("frozen image")
+
+	if w.FrozenTexture != nil {
+		w.FrozenTexture.Destroy()
+		w.FrozenTexture = nil
+	}
+
+	if err := w.ReinitContext(); err != nil {
+		return err
+	}
+
+	w.BrowserTab.ForceRefresh.Store(true)
+
+	return nil
+
+}
+
+// syncFrozenState freezes or unfreezes the card to match its "frozen" property, which can change outside of
+// ToggleFreeze() (e.g. by undoing or copying settings from another card).
+func (w *InternetContents) syncFrozenState() {
+
+	if !w.Card.Valid {
+		return
+	}
+
+	if w.Frozen() && w.BrowserTab != nil {
+		if err := w.freeze(); err != nil {
+			globals.EventLog.Log("Couldn't freeze web card: %s", true, err.Error())
+			w.Card.Properties.Get("frozen").Set(false)
+		}
+	} else if !w.Frozen() && w.BrowserTab == nil {
+		if err := w.unfreeze(); err != nil {
+			globals.EventLog.Log("error (re-)initializing context: %s", true, err.Error())
+		}
+	}
+
+}
+
+func (w *InternetContents) loadFrozenTexture() error {
+
+	data, err := base64.StdEncoding.DecodeString(w.Card.Properties.Get("frozen image").AsString())
+	if err != nil {
+		return err
+	}
+
+	stream, err := sdl.IOFromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	tex, err := img.LoadTextureIO(globals.Renderer, stream, true)
+	if err != nil {
+		return err
+	}
+
+	tex.SetScaleMode(sdl.SCALEMODE_LINEAR)
+
+	if w.FrozenTexture != nil {
+		w.FrozenTexture.Destroy()
+	}
+
+	w.FrozenTexture = tex
+
+	return nil
+
 }
 
 // func (w *WebContents) CopyActiveURLToCard() {
@@ -5776,19 +6931,35 @@ func (w *InternetContents) ReceiveMessage(msg *Message) {
 
 	switch msg.Type {
 	case MessageCardDeleted:
-		w.BrowserTab.Destroy()
-		w.BrowserTab = nil
+		if w.BrowserTab != nil {
+			w.BrowserTab.Destroy()
+			w.BrowserTab = nil
+		}
 	case MessageCardRestored:
-		w.ReinitContext()
+		if !w.Frozen() {
+			w.ReinitContext()
+		}
 		if w.BrowserTab != nil {
 			w.BrowserTab.ForceRefresh.Store(true) // Send the input sent to indicate that it should update the texture regardless of FPS, update settings, etc.
 		}
+	case MessageProjectSaveInitiated:
+		w.saveSession()
 	case MessageUndoRedo:
+		w.syncFrozenState()
 		if w.BrowserTab != nil {
 			w.BrowserTab.UpdateBufferSize(w.Width(), w.Height())
+			w.BrowserTab.UpdateZoom()
 		}
 	case MessageCardDestroyed:
-		w.BrowserTab.Destroy()
+		if w.BrowserTab != nil {
+			w.BrowserTab.Destroy()
+		}
+		if w.FrozenTexture != nil {
+			w.FrozenTexture.Destroy()
+		}
+		if w.Favicon != nil {
+			w.Favicon.Destroy()
+		}
 	}
 
 }
@@ -5804,7 +6975,11 @@ func (w *InternetContents) CompletionLevel() float32 { return 0 }
 func (w *InternetContents) MaximumCompletionLevel() float32 { return 0 }
 
 func (w *InternetContents) Collapseable() bool {
-	return false
+	return true
+}
+
+func (w *InternetContents) ExpandedHeight() float32 {
+	return w.Card.Rect.W * float32(w.Height()) / float32(w.Width())
 }
 
 // type WebContents struct {
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	DownloadMaxConcurrent = 4
+	DownloadMaxAttempts   = 4
+	DownloadRetryDelay    = time.Second      // How long to wait before the first retry; this doubles with each attempt
+	DownloadStallTimeout  = time.Second * 20 // How long a download can go without receiving any data before it's retried
+	DownloadSniffSize     = 3072             // How much of a file is read to determine its MIME type
+)
+
+var ErrDownloadStalled = errors.New("download stalled")
+
+// DownloadQueue downloads files in the background, a limited number at a time, retrying downloads that fail.
+type DownloadQueue struct {
+	slots  chan struct{}
+	client *http.Client
+}
+
+func NewDownloadQueue(maxConcurrent int) *DownloadQueue {
+
+	return &DownloadQueue{
+		slots: make(chan struct{}, maxConcurrent),
+		// There's no overall timeout, as large files can take a while; stalled downloads are caught while reading instead.
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				TLSHandshakeTimeout:   time.Second * 10,
+				ResponseHeaderTimeout: time.Second * 15,
+			},
+		},
+	}
+
+}
+
+// Enqueue starts downloading the file at the given URL to the given filename once a download slot is free.
+func (queue *DownloadQueue) Enqueue(url, filename string) *Download {
+
+	download := &Download{
+		URL:      url,
+		Filename: filename,
+		sniffed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	download.size.Store(-1)
+	download.ctx, download.cancel = context.WithCancel(context.Background())
+
+	go queue.run(download)
+
+	return download
+
+}
+
+func (queue *DownloadQueue) run(download *Download) {
+
+	defer close(download.done)
+	defer download.finishSniffing()
+
+	delay := DownloadRetryDelay
+
+	for attempt := 1; ; attempt++ {
+
+		select {
+		case queue.slots <- struct{}{}:
+		case <-download.ctx.Done():
+			download.setErr(download.ctx.Err())
+			return
+		}
+
+		retry, err := download.attempt(queue.client)
+
+		<-queue.slots
+
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt >= DownloadMaxAttempts || download.ctx.Err() != nil {
+			if download.ctx.Err() != nil {
+				err = download.ctx.Err()
+			}
+			download.setErr(err)
+			return
+		}
+
+		log.Printf("download of %s failed (attempt %d / %d): %s", download.URL, attempt, DownloadMaxAttempts, err)
+
+		select {
+		case <-time.After(delay):
+		case <-download.ctx.Done():
+			download.setErr(download.ctx.Err())
+			return
+		}
+
+		delay *= 2
+
+	}
+
+}
+
+// Download is a single file being downloaded by a DownloadQueue.
+type Download struct {
+	URL      string
+	Filename string
+
+	bytesComplete atomic.Int64
+	size          atomic.Int64 // -1 if the size isn't known
+
+	lock      sync.Mutex
+	err       error
+	mimeType  string
+	sniffOnce sync.Once
+	sniffed   chan struct{}
+	done      chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// attempt tries to download the file once, sniffing its MIME type from the start of the same response. It returns
+// whether trying again could help and any error encountered.
+func (download *Download) attempt(client *http.Client) (bool, error) {
+
+	ctx, cancel := context.WithCancel(download.ctx)
+	defer cancel()
+
+	stallTimer := time.AfterFunc(DownloadStallTimeout, cancel)
+	defer stallTimer.Stop()
+
+	stalled := func(err error) error {
+		if ctx.Err() != nil && download.ctx.Err() == nil {
+			return ErrDownloadStalled
+		}
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, download.URL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return true, stalled(err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("server responded with %s", response.Status)
+	}
+
+	download.size.Store(response.ContentLength)
+	download.bytesComplete.Store(0)
+
+	if err := os.MkdirAll(filepath.Dir(download.Filename), 0755); err != nil {
+		return false, err
+	}
+
+	// Download to a temporary file first so a broken download is never mistaken for a finished one
+	file, err := os.CreateTemp(filepath.Dir(download.Filename), filepath.Base(download.Filename)+".*.part")
+	if err != nil {
+		return false, err
+	}
+
+	partPath := file.Name()
+
+	fail := func(retry bool, err error) (bool, error) {
+		file.Close()
+		os.Remove(partPath)
+		return retry, err
+	}
+
+	head := make([]byte, DownloadSniffSize)
+	n, err := io.ReadFull(response.Body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fail(true, stalled(err))
+	}
+
+	download.setMimeType(mimetype.Detect(head[:n]).String())
+
+	if _, err := file.Write(head[:n]); err != nil {
+		return fail(false, err)
+	}
+
+	download.bytesComplete.Add(int64(n))
+
+	buffer := make([]byte, 32*1024)
+
+	for {
+
+		n, readErr := response.Body.Read(buffer)
+
+		if n > 0 {
+
+			stallTimer.Reset(DownloadStallTimeout)
+
+			if _, err := file.Write(buffer[:n]); err != nil {
+				return fail(false, err)
+			}
+
+			download.bytesComplete.Add(int64(n))
+
+		}
+
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return fail(true, stalled(readErr))
+		}
+
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(partPath)
+		return false, err
+	}
+
+	if err := os.Rename(partPath, download.Filename); err != nil {
+		os.Remove(partPath)
+		return false, err
+	}
+
+	return false, nil
+
+}
+
+func (download *Download) setErr(err error) {
+	download.lock.Lock()
+	download.err = err
+	download.lock.Unlock()
+}
+
+func (download *Download) setMimeType(mimeType string) {
+	download.lock.Lock()
+	download.mimeType = mimeType
+	download.lock.Unlock()
+	download.finishSniffing()
+}
+
+func (download *Download) finishSniffing() {
+	download.sniffOnce.Do(func() { close(download.sniffed) })
+}
+
+// MimeType waits up to the given timeout for the start of the file to be downloaded, and returns its MIME type.
+// If the download failed or is taking too long, an empty string is returned.
+func (download *Download) MimeType(timeout time.Duration) string {
+
+	select {
+	case <-download.sniffed:
+	case <-time.After(timeout):
+	}
+
+	download.lock.Lock()
+	defer download.lock.Unlock()
+	return download.mimeType
+
+}
+
+// Sniffed returns if enough of the file has downloaded to tell its MIME type, or if the download failed before then.
+func (download *Download) Sniffed() bool {
+	select {
+	case <-download.sniffed:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsComplete returns if the download has finished, whether it succeeded or not.
+func (download *Download) IsComplete() bool {
+	select {
+	case <-download.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the error that caused the download to fail, or nil if it hasn't (yet).
+func (download *Download) Err() error {
+	download.lock.Lock()
+	defer download.lock.Unlock()
+	return download.err
+}
+
+func (download *Download) Succeeded() bool {
+	return download.IsComplete() && download.Err() == nil
+}
+
+func (download *Download) BytesComplete() int64 {
+	return download.bytesComplete.Load()
+}
+
+// Progress returns how much of the file has been downloaded from 0 to 1, or -1 if the file's size isn't known.
+func (download *Download) Progress() float64 {
+
+	if download.Succeeded() {
+		return 1
+	}
+
+	if size := download.size.Load(); size > 0 {
+		return min(float64(download.bytesComplete.Load())/float64(size), 1)
+	}
+
+	return -1
+
+}
+
+// Cancel stops the download; it fails with context.Canceled.
+func (download *Download) Cancel() {
+	download.cancel()
+}
@@ -8,18 +8,17 @@ import (
 	"github.com/Zyko0/go-sdl3/sdl"
 	"github.com/Zyko0/go-sdl3/ttf"
 	"github.com/blang/semver"
-	"github.com/cavaliergopher/grab/v3"
 	"github.com/gopxl/beep/v2"
 )
 
 const (
-	StateNeutral     = "project state neutral"
-	StateTextEditing = "project state text editing"
-	StateMapEditing  = "project state map editing"
-	StateContextMenu = "project state context menu open"
-	StateCardArrow   = "project state card arrow"
-	StateCardLink    = "project state card linking"
-	StateExport      = "project state export"
+	StateNeutral         = "project state neutral"
+	StateTextEditing     = "project state text editing"
+	StateCardToolEditing = "project state card tool editing" // A map or image card's editing tool is in use
+	StateContextMenu     = "project state context menu open"
+	StateCardArrow       = "project state card arrow"
+	StateCardLink        = "project state card linking"
+	StateExport          = "project state export"
 )
 
 const (
@@ -58,7 +57,7 @@ type Globals struct {
 	Version           semver.Version
 	State             string
 	Resources         ResourceBank
-	GrabClient        *grab.Client
+	Downloads         *DownloadQueue
 	MenuSystem        *MenuSystem
 	EventLog          *EventLog
 	WindowFlags       sdl.WindowFlags
@@ -78,7 +77,8 @@ type Globals struct {
 
 	Dispatcher *Dispatcher
 
-	Hierarchy *Hierarchy
+	Hierarchy  *Hierarchy
+	FoundCards []*Card // Cards matching the current search in the Find menu
 
 	editingLabel    *Label
 	editingCard     *Card
@@ -86,7 +86,7 @@ type Globals struct {
 
 	DrawOnTop DrawOnTop
 
-	BrowserContext context.Context
+	BrowserContexts map[string]context.Context // Browser contexts, keyed by the user-data (profile) directory they were launched with
 
 	BrowserTabs []*BrowserTab
 	BrowserLock sync.Mutex
@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/Zyko0/go-sdl3/sdl"
@@ -229,7 +230,7 @@ func (iconButton *IconButton) Update() {
 		return
 	}
 
-	if iconButton.CanPress && (globals.Mouse.CurrentCursor == CursorNormal || globals.Mouse.CurrentCursor == CursorEyedropper) && ClickedInRect(iconButton.Rect, iconButton.WorldSpace) && iconButton.OnPressed != nil && (globals.State == StateNeutral || globals.State == StateCardLink || globals.State == StateTextEditing || globals.State == StateMapEditing) && iconButton.Active {
+	if iconButton.CanPress && (globals.Mouse.CurrentCursor == CursorNormal || globals.Mouse.CurrentCursor == CursorEyedropper) && ClickedInRect(iconButton.Rect, iconButton.WorldSpace) && iconButton.OnPressed != nil && (globals.State == StateNeutral || globals.State == StateCardLink || globals.State == StateTextEditing || globals.State == StateCardToolEditing) && iconButton.Active {
 		globals.Mouse.Button(sdl.BUTTON_LEFT).Consume()
 		iconButton.OnPressed()
 		iconButton.tween.Reset()
@@ -238,7 +239,7 @@ func (iconButton *IconButton) Update() {
 
 	}
 
-	if iconButton.CanPress && (globals.Mouse.CurrentCursor == CursorNormal || globals.Mouse.CurrentCursor == CursorEyedropper) && iconButton.OnRightClickPressed != nil && (globals.State == StateNeutral || globals.State == StateCardLink || globals.State == StateTextEditing || globals.State == StateMapEditing) && iconButton.Active && globals.Mouse.WorldPosition().Inside(iconButton.Rect) && globals.Mouse.Button(sdl.BUTTON_RIGHT).Pressed() {
+	if iconButton.CanPress && (globals.Mouse.CurrentCursor == CursorNormal || globals.Mouse.CurrentCursor == CursorEyedropper) && iconButton.OnRightClickPressed != nil && (globals.State == StateNeutral || globals.State == StateCardLink || globals.State == StateTextEditing || globals.State == StateCardToolEditing) && iconButton.Active && globals.Mouse.WorldPosition().Inside(iconButton.Rect) && globals.Mouse.Button(sdl.BUTTON_RIGHT).Pressed() {
 		globals.Mouse.Button(sdl.BUTTON_RIGHT).Consume()
 		iconButton.OnRightClickPressed()
 		iconButton.tween.Reset()
@@ -556,6 +557,285 @@ func (pb *ProgressBar) SetRectangle(rect *sdl.FRect) {
 
 func (pb *ProgressBar) Destroy() {}
 
+// ProgressChart plots a Page's progress history as either a burndown (remaining work) or burnup (completed work vs. scope) chart.
+type ProgressChart struct {
+	Rect    *sdl.FRect
+	Samples []ProgressSample
+	Burnup  bool
+}
+
+func NewProgressChart(rect *sdl.FRect) *ProgressChart {
+	return &ProgressChart{
+		Rect:    rect,
+		Samples: []ProgressSample{},
+	}
+}
+
+func (pc *ProgressChart) Update() {}
+
+func (pc *ProgressChart) Draw() {
+
+	r := pc.Rectangle()
+
+	fontColor := getThemeColor(GUIFontColor)
+	menuColor := getThemeColor(GUIMenuColor)
+
+	FillRect(r.X, r.Y, r.W, r.H, menuColor.Accent())
+
+	if len(pc.Samples) == 0 {
+		globals.TextRenderer.QuickRenderText("No progress history yet; save the project to record a sample.", Vector{r.X + r.W/2, r.Y + r.H/2 - 16}, 1, fontColor, menuColor, AlignCenter)
+		return
+	}
+
+	margin := float32(32)
+	plot := &sdl.FRect{r.X + margin*2, r.Y + margin/2, r.W - margin*2.5, r.H - margin*1.5}
+
+	top := float32(0)
+	for _, s := range pc.Samples {
+		if s.Maximum > top {
+			top = s.Maximum
+		}
+	}
+	if top <= 0 {
+		top = 1
+	}
+
+	start := pc.Samples[0].Date
+	days := float32(pc.Samples[len(pc.Samples)-1].Date.Sub(start).Hours() / 24)
+	if days < 1 {
+		days = 1
+	}
+
+	point := func(date time.Time, value float32) Vector {
+		x := plot.X + float32(date.Sub(start).Hours()/24)/days*plot.W
+		y := plot.Y + plot.H - (value / top * plot.H)
+		return Vector{x, y}
+	}
+
+	// Axes
+	ThickLine(Vector{plot.X, plot.Y}, Vector{plot.X, plot.Y + plot.H}, 1, fontColor)
+	ThickLine(Vector{plot.X, plot.Y + plot.H}, Vector{plot.X + plot.W, plot.Y + plot.H}, 1, fontColor)
+
+	globals.TextRenderer.QuickRenderText(strconv.FormatFloat(float64(top), 'f', -1, 32), Vector{plot.X - 8, plot.Y - 8}, 0.5, fontColor, menuColor, AlignRight)
+	globals.TextRenderer.QuickRenderText("0", Vector{plot.X - 8, plot.Y + plot.H - 8}, 0.5, fontColor, menuColor, AlignRight)
+	globals.TextRenderer.QuickRenderText(start.Format("Jan 2"), Vector{plot.X, plot.Y + plot.H + 4}, 0.5, fontColor, menuColor, AlignLeft)
+	globals.TextRenderer.QuickRenderText(pc.Samples[len(pc.Samples)-1].Date.Format("Jan 2"), Vector{plot.X + plot.W, plot.Y + plot.H + 4}, 0.5, fontColor, menuColor, AlignRight)
+
+	completedColor := getThemeColor(GUICompletedColor)
+	scopeColor := getThemeColor(GUINumberColor)
+
+	if pc.Burnup {
+
+		for i := 1; i < len(pc.Samples); i++ {
+			prev, s := pc.Samples[i-1], pc.Samples[i]
+			ThickLine(point(prev.Date, prev.Maximum), point(s.Date, s.Maximum), 2, scopeColor)
+			ThickLine(point(prev.Date, prev.Completed), point(s.Date, s.Completed), 2, completedColor)
+		}
+
+	} else {
+
+		first, last := pc.Samples[0], pc.Samples[len(pc.Samples)-1]
+
+		// Ideal line, from the first day's remaining work to nothing remaining on the last day
+		ThickLine(point(first.Date, first.Maximum-first.Completed), point(last.Date, 0), 1, scopeColor)
+
+		for i := 1; i < len(pc.Samples); i++ {
+			prev, s := pc.Samples[i-1], pc.Samples[i]
+			ThickLine(point(prev.Date, prev.Maximum-prev.Completed), point(s.Date, s.Maximum-s.Completed), 2, completedColor)
+		}
+
+	}
+
+	for _, s := range pc.Samples {
+		value := s.Maximum - s.Completed
+		if pc.Burnup {
+			value = s.Completed
+		}
+		p := point(s.Date, value)
+		FillRect(p.X-3, p.Y-3, 6, 6, completedColor)
+	}
+
+}
+
+func (pc *ProgressChart) Rectangle() *sdl.FRect {
+	r := *pc.Rect
+	return &r
+}
+
+func (pc *ProgressChart) SetRectangle(rect *sdl.FRect) {
+	pc.Rect.X = rect.X
+	pc.Rect.Y = rect.Y
+	pc.Rect.W = rect.W
+	pc.Rect.H = rect.H
+}
+
+func (pc *ProgressChart) Destroy() {}
+
+// Minimap draws an overview of the current Page's cards and the camera's view area; clicking and dragging within it pans the camera.
+type Minimap struct {
+	Rect     *sdl.FRect
+	Dragging bool
+
+	bounds *sdl.FRect
+	scale  float32
+	offset Vector
+}
+
+func NewMinimap(rect *sdl.FRect) *Minimap {
+	return &Minimap{
+		Rect: rect,
+	}
+}
+
+func (mm *Minimap) calculateTransform() {
+
+	page := globals.Project.CurrentPage
+
+	topLeft := Vector{math.MaxFloat32, math.MaxFloat32}
+	bottomRight := Vector{-math.MaxFloat32, -math.MaxFloat32}
+
+	for _, card := range page.Cards {
+		topLeft.X = min(topLeft.X, card.Rect.X)
+		topLeft.Y = min(topLeft.Y, card.Rect.Y)
+		bottomRight.X = max(bottomRight.X, card.Rect.X+card.Rect.W)
+		bottomRight.Y = max(bottomRight.Y, card.Rect.Y+card.Rect.H)
+	}
+
+	if len(page.Cards) == 0 {
+		view := globals.Project.Camera.ViewArea()
+		topLeft = Vector{float32(view.X), float32(view.Y)}
+		bottomRight = Vector{float32(view.X + view.W), float32(view.Y + view.H)}
+	}
+
+	padding := globals.GridSize * 4
+	mm.bounds = &sdl.FRect{topLeft.X - padding, topLeft.Y - padding, bottomRight.X - topLeft.X + (padding * 2), bottomRight.Y - topLeft.Y + (padding * 2)}
+
+	mm.scale = min(mm.Rect.W/mm.bounds.W, mm.Rect.H/mm.bounds.H)
+	mm.offset = Vector{
+		mm.Rect.X + (mm.Rect.W-(mm.bounds.W*mm.scale))/2,
+		mm.Rect.Y + (mm.Rect.H-(mm.bounds.H*mm.scale))/2,
+	}
+
+}
+
+// toMinimap converts a world-space rectangle to a rectangle in the minimap.
+func (mm *Minimap) toMinimap(rect *sdl.FRect) *sdl.FRect {
+	return &sdl.FRect{
+		mm.offset.X + (rect.X-mm.bounds.X)*mm.scale,
+		mm.offset.Y + (rect.Y-mm.bounds.Y)*mm.scale,
+		rect.W * mm.scale,
+		rect.H * mm.scale,
+	}
+}
+
+func (mm *Minimap) Update() {
+
+	mm.calculateTransform()
+
+	lmb := globals.Mouse.Button(sdl.BUTTON_LEFT)
+	mousePos := globals.Mouse.Position()
+
+	if mousePos.Inside(mm.Rect) {
+		globals.Mouse.SetCursor(CursorHand)
+		if lmb.Pressed() {
+			lmb.Consume()
+			mm.Dragging = true
+		}
+	}
+
+	if !lmb.HeldRaw() {
+		mm.Dragging = false
+	}
+
+	if mm.Dragging && mm.scale > 0 {
+
+		globals.Mouse.SetCursor(CursorHandGrab)
+
+		target := Vector{
+			mm.bounds.X + (mousePos.X-mm.offset.X)/mm.scale,
+			mm.bounds.Y + (mousePos.Y-mm.offset.Y)/mm.scale,
+		}
+
+		globals.Project.Camera.TargetPosition = target
+
+	}
+
+}
+
+func (mm *Minimap) Draw() {
+
+	if mm.bounds == nil {
+		mm.calculateTransform()
+	}
+
+	page := globals.Project.CurrentPage
+
+	FillRect(mm.Rect.X, mm.Rect.Y, mm.Rect.W, mm.Rect.H, getThemeColor(GUIBGColor))
+
+	found := map[*Card]bool{}
+	for _, card := range globals.FoundCards {
+		found[card] = true
+	}
+
+	flash := math.Sin(globals.Time*math.Pi*2) > 0
+
+	for _, card := range page.Cards {
+
+		r := mm.toMinimap(card.Rect)
+		FillRect(r.X, r.Y, max(r.W, 1), max(r.H, 1), card.Color())
+
+		var highlight Color
+
+		if card.selected {
+			highlight = getThemeColor(GUIFontColor)
+		} else if found[card] {
+			highlight = ColorBlue
+		} else if card.Properties.Has("deadline") {
+			switch card.DeadlineState() {
+			case DeadlineStateOverdue:
+				if flash {
+					highlight = ColorRed
+				}
+			case DeadlineStateDueToday:
+				highlight = ColorYellow
+			}
+		}
+
+		if highlight != nil {
+			ThickRect(int32(r.X-2), int32(r.Y-2), int32(r.W+4), int32(r.H+4), 1, highlight)
+		}
+
+	}
+
+	view := globals.Project.Camera.ViewArea()
+	vr := mm.toMinimap(&sdl.FRect{float32(view.X), float32(view.Y), float32(view.W), float32(view.H)})
+
+	// Keep the view rectangle within the minimap
+	x1 := max(vr.X, mm.Rect.X)
+	y1 := max(vr.Y, mm.Rect.Y)
+	x2 := min(vr.X+vr.W, mm.Rect.X+mm.Rect.W)
+	y2 := min(vr.Y+vr.H, mm.Rect.Y+mm.Rect.H)
+
+	if x2 > x1 && y2 > y1 {
+		ThickRect(int32(x1), int32(y1), int32(x2-x1), int32(y2-y1), 2, getThemeColor(GUIFontColor))
+	}
+
+}
+
+func (mm *Minimap) Rectangle() *sdl.FRect {
+	r := *mm.Rect
+	return &r
+}
+
+func (mm *Minimap) SetRectangle(rect *sdl.FRect) {
+	mm.Rect.X = rect.X
+	mm.Rect.Y = rect.Y
+	mm.Rect.W = rect.W
+	mm.Rect.H = rect.H
+}
+
+func (mm *Minimap) Destroy() {}
+
 type NumberSpinner struct {
 	Rect     *sdl.FRect
 	Label    *Label
@@ -814,7 +1094,7 @@ func (button *Button) Update() {
 
 	if mousePos.Inside(buttonRect) && !button.Disabled && (globals.Mouse.CurrentCursor == CursorNormal || globals.State == StateCardLink) {
 
-		if globals.Mouse.Button(sdl.BUTTON_LEFT).Pressed() && (globals.State == StateNeutral || globals.State == StateCardLink || globals.State == StateContextMenu || globals.State == StateMapEditing || globals.State == StateTextEditing) {
+		if globals.Mouse.Button(sdl.BUTTON_LEFT).Pressed() && (globals.State == StateNeutral || globals.State == StateCardLink || globals.State == StateContextMenu || globals.State == StateCardToolEditing || globals.State == StateTextEditing) {
 			if button.OnPressed != nil {
 
 				PlayUISound(UISoundTypeTap)
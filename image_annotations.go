@@ -0,0 +1,569 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/ncruces/zenity"
+)
+
+const (
+	ImageEditToolNone = iota
+	ImageEditToolCrop
+	ImageEditToolArrow
+	ImageEditToolRect
+	ImageEditToolFreehand
+	ImageEditToolText
+)
+
+const (
+	ImageAnnotationArrow    = "arrow"
+	ImageAnnotationRect     = "rect"
+	ImageAnnotationFreehand = "freehand"
+	ImageAnnotationText     = "text"
+)
+
+var ImageAnnotationColor = 0
+var ImageAnnotationColors = []Color{
+	NewColor(235, 64, 52, 255),
+	NewColor(250, 200, 40, 255),
+	NewColor(90, 200, 90, 255),
+	NewColor(60, 140, 230, 255),
+	NewColor(250, 250, 250, 255),
+	NewColor(20, 20, 20, 255),
+}
+var ImageAnnotationColorNames = []string{"Red", "Yellow", "Green", "Blue", "White", "Black"}
+
+// ImageAnnotation is a mark drawn over an Image card. Points are stored as X and Y pairs relative to the full,
+// uncropped image (0 - 1), so annotations stay in place when the card is resized or cropped.
+type ImageAnnotation struct {
+	Type   string    `json:"type"`
+	Points []float32 `json:"points"`
+	Color  int       `json:"color"`
+	Text   string    `json:"text,omitempty"`
+	Size   float32   `json:"size,omitempty"` // Text height, relative to the image's height
+}
+
+func (annotation ImageAnnotation) Point(index int) Vector {
+	return Vector{annotation.Points[index*2], annotation.Points[index*2+1]}
+}
+
+func (annotation ImageAnnotation) PointCount() int {
+	return len(annotation.Points) / 2
+}
+
+func (annotation ImageAnnotation) DrawColor() Color {
+	if annotation.Color < 0 || annotation.Color >= len(ImageAnnotationColors) {
+		return ImageAnnotationColors[0]
+	}
+	return ImageAnnotationColors[annotation.Color]
+}
+
+func (ic *ImageContents) initAnnotationTools() {
+
+	// Crop and annotate
+	ic.AnnotateButton = NewIconButtonTintless(0, 0, &sdl.FRect{368, 32, 32, 32}, globals.GUITexture, true, func() {
+		globals.Mouse.Button(sdl.BUTTON_LEFT).Consume()
+		if ic.Editing {
+			ic.stopEditing()
+		} else if ic.ValidResource() {
+			ic.Editing = true
+		}
+	})
+
+	ic.Buttons = append(ic.Buttons, ic.AnnotateButton)
+
+	tools := []struct {
+		Name string
+		Tool int
+	}{
+		{"Crop", ImageEditToolCrop},
+		{"Arrow", ImageEditToolArrow},
+		{"Rect", ImageEditToolRect},
+		{"Freehand", ImageEditToolFreehand},
+		{"Text", ImageEditToolText},
+	}
+
+	for _, t := range tools {
+		tool := t.Tool
+		ic.ToolButtons = append(ic.ToolButtons, NewButton(t.Name, nil, nil, true, func() { ic.Tool = tool }))
+	}
+
+	ic.ColorButton = NewButton("Color: "+ImageAnnotationColorNames[ImageAnnotationColor], &sdl.FRect{0, 0, 160, 32}, nil, true, func() {
+		ImageAnnotationColor = (ImageAnnotationColor + 1) % len(ImageAnnotationColors)
+	})
+
+	ic.ActionButtons = []*Button{
+		ic.ColorButton,
+		NewButton("Remove Last", nil, nil, true, func() {
+			if annotations := ic.Annotations(); len(annotations) > 0 {
+				ic.SetAnnotations(annotations[:len(annotations)-1])
+			}
+		}),
+		NewButton("Clear", nil, nil, true, func() {
+			if len(ic.Annotations()) > 0 {
+				ic.SetAnnotations(nil)
+				globals.EventLog.Log("Image annotations cleared.", false)
+			}
+		}),
+		NewButton("Reset Crop", nil, nil, true, func() {
+			if crop := ic.Card.Properties.GetIfExists("crop"); crop != nil && crop.AsString() != "" {
+				ic.SetCrop(nil)
+				globals.EventLog.Log("Image crop reset.", false)
+			}
+		}),
+		NewButton("Done", nil, nil, true, func() { ic.stopEditing() }),
+	}
+
+}
+
+func (ic *ImageContents) stopEditing() {
+
+	if ic.UsingToolState && globals.State == StateCardToolEditing {
+		globals.State = StateNeutral
+	}
+
+	ic.UsingToolState = false
+
+	ic.Editing = false
+	ic.Tool = ImageEditToolNone
+	ic.Dragging = false
+	ic.Card.Draggable = true
+
+}
+
+// SourceSize returns the size of the full, uncropped image in pixels.
+func (ic *ImageContents) SourceSize() Vector {
+
+	if ic.ValidResource() {
+
+		if ic.Resource.IsTexture() {
+			return ic.Resource.AsImage().Size
+		}
+
+		return Vector{ic.Resource.AsGIF().Width, ic.Resource.AsGIF().Height}
+
+	}
+
+	return Vector{1, 1}
+
+}
+
+// Crop returns the portion of the image that the card displays, in pixels.
+func (ic *ImageContents) Crop() *sdl.FRect {
+
+	size := ic.SourceSize()
+	full := &sdl.FRect{0, 0, size.X, size.Y}
+
+	crop := ic.Card.Properties.GetIfExists("crop")
+	if crop == nil {
+		return full
+	}
+
+	values := crop.AsArrayOfInts()
+	if len(values) != 4 {
+		return full
+	}
+
+	rect := &sdl.FRect{float32(values[0]), float32(values[1]), float32(values[2]), float32(values[3])}
+
+	rect.X = max(0, min(rect.X, size.X-1))
+	rect.Y = max(0, min(rect.Y, size.Y-1))
+	rect.W = max(1, min(rect.W, size.X-rect.X))
+	rect.H = max(1, min(rect.H, size.Y-rect.Y))
+
+	return rect
+
+}
+
+// SetCrop crops the image to the given rectangle (in pixels), resizing the card so the image keeps its scale. A nil
+// rectangle removes the crop.
+func (ic *ImageContents) SetCrop(rect *sdl.FRect) {
+
+	oldCrop := ic.Crop()
+	scale := ic.Card.Rect.W / oldCrop.W
+
+	// Removed properties would linger when undoing, so they're cleared instead
+	if rect == nil {
+		ic.Card.Properties.Get("crop").Set("")
+	} else {
+		ic.Card.Properties.Get("crop").SetInts(int64(rect.X), int64(rect.Y), int64(rect.W), int64(rect.H))
+	}
+
+	newCrop := ic.Crop()
+	ic.Card.Recreate(newCrop.W*scale, newCrop.H*scale)
+
+}
+
+// Annotations returns the annotations drawn over the image.
+func (ic *ImageContents) Annotations() []ImageAnnotation {
+
+	prop := ic.Card.Properties.GetIfExists("annotations")
+
+	if prop == nil || !prop.IsString() {
+		return nil
+	}
+
+	if data := prop.AsString(); data != ic.annotationData {
+		ic.annotationData = data
+		ic.annotations = nil
+		json.Unmarshal([]byte(data), &ic.annotations)
+	}
+
+	return ic.annotations
+
+}
+
+func (ic *ImageContents) SetAnnotations(annotations []ImageAnnotation) {
+
+	if len(annotations) == 0 {
+		ic.Card.Properties.Get("annotations").Set("")
+		return
+	}
+
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		globals.EventLog.Log("Couldn't save image annotations: %s", true, err.Error())
+		return
+	}
+
+	ic.Card.Properties.Get("annotations").Set(string(data))
+
+}
+
+func (ic *ImageContents) AddAnnotation(annotation ImageAnnotation) {
+	ic.SetAnnotations(append(append([]ImageAnnotation{}, ic.Annotations()...), annotation))
+}
+
+// imageToWorld converts a point relative to the full image into world space, taking the crop into account.
+func (ic *ImageContents) imageToWorld(point Vector) Vector {
+
+	size := ic.SourceSize()
+	crop := ic.Crop()
+	rect := ic.Card.DisplayRect
+
+	return Vector{
+		rect.X + (point.X*size.X-crop.X)/crop.W*rect.W,
+		rect.Y + (point.Y*size.Y-crop.Y)/crop.H*rect.H,
+	}
+
+}
+
+// worldToImage converts a point in world space to one relative to the full image.
+func (ic *ImageContents) worldToImage(point Vector) Vector {
+
+	size := ic.SourceSize()
+	crop := ic.Crop()
+	rect := ic.Card.DisplayRect
+
+	return Vector{
+		((point.X-rect.X)/rect.W*crop.W + crop.X) / size.X,
+		((point.Y-rect.Y)/rect.H*crop.H + crop.Y) / size.Y,
+	}
+
+}
+
+func (ic *ImageContents) updateAnnotationTools() {
+
+	if !ic.Editing {
+		return
+	}
+
+	if !ic.Card.IsSelected() || !ic.ValidResource() {
+		ic.stopEditing()
+		return
+	}
+
+	ic.ColorButton.Label.SetText([]rune("Color: " + ImageAnnotationColorNames[ImageAnnotationColor]))
+
+	x := ic.Card.DisplayRect.X
+	y := ic.Card.DisplayRect.Y + ic.Card.DisplayRect.H + 4
+
+	// The selected tool stays highlighted
+	for i, button := range ic.ToolButtons {
+		button.FadeOnInactive = ic.Tool != i+1
+	}
+
+	for i, button := range append(append([]*Button{}, ic.ToolButtons...), ic.ActionButtons...) {
+
+		if i == len(ic.ToolButtons) {
+			x = ic.Card.DisplayRect.X
+			y += 32
+		}
+
+		rect := button.Rectangle()
+		rect.X = x
+		rect.Y = y
+		button.SetRectangle(rect)
+		button.Update()
+
+		x += rect.W + 8
+
+	}
+
+	ic.Card.Draggable = ic.Tool == ImageEditToolNone
+
+	mp := globals.Mouse.WorldPosition()
+	leftMB := globals.Mouse.Button(sdl.BUTTON_LEFT)
+
+	// Only give the state back if this card took it, so other cards' editing tools aren't interrupted
+	if ic.Tool != ImageEditToolNone && (mp.Inside(ic.Card.Rect) || ic.Dragging) {
+		globals.State = StateCardToolEditing
+		ic.UsingToolState = true
+	} else if ic.UsingToolState {
+		if globals.State == StateCardToolEditing {
+			globals.State = StateNeutral
+		}
+		ic.UsingToolState = false
+	}
+
+	if ic.Tool == ImageEditToolNone || ic.Card.Resizing != "" || globals.Mouse.OverGUI {
+		return
+	}
+
+	if mp.Inside(ic.Card.Rect) {
+		if ic.Tool == ImageEditToolText {
+			globals.Mouse.SetCursor(CursorCaret)
+		} else {
+			globals.Mouse.SetCursor(CursorPencil)
+		}
+	}
+
+	point := ic.worldToImage(mp)
+	point.X = max(0, min(point.X, 1))
+	point.Y = max(0, min(point.Y, 1))
+
+	if leftMB.Pressed() && mp.Inside(ic.Card.Rect) {
+
+		leftMB.Consume()
+
+		if ic.Tool == ImageEditToolText {
+			ic.addTextAnnotation(point)
+			return
+		}
+
+		ic.Dragging = true
+		ic.DragPoints = []float32{point.X, point.Y}
+
+	} else if ic.Dragging && leftMB.Held() {
+
+		if ic.Tool == ImageEditToolFreehand {
+			last := Vector{ic.DragPoints[len(ic.DragPoints)-2], ic.DragPoints[len(ic.DragPoints)-1]}
+			if ic.imageToWorld(last).Distance(ic.imageToWorld(point)) > 4 {
+				ic.DragPoints = append(ic.DragPoints, point.X, point.Y)
+			}
+		} else {
+			ic.DragPoints = append(ic.DragPoints[:2], point.X, point.Y)
+		}
+
+	} else if ic.Dragging {
+
+		ic.Dragging = false
+
+		if len(ic.DragPoints) < 4 {
+			return
+		}
+
+		start := Vector{ic.DragPoints[0], ic.DragPoints[1]}
+		end := Vector{ic.DragPoints[len(ic.DragPoints)-2], ic.DragPoints[len(ic.DragPoints)-1]}
+
+		// Ignore clicks that didn't really drag anywhere
+		if ic.imageToWorld(start).Distance(ic.imageToWorld(end)) < 4 && ic.Tool != ImageEditToolFreehand {
+			return
+		}
+
+		switch ic.Tool {
+
+		case ImageEditToolCrop:
+
+			size := ic.SourceSize()
+			ic.SetCrop(&sdl.FRect{
+				float32(math.Round(float64(min(start.X, end.X) * size.X))),
+				float32(math.Round(float64(min(start.Y, end.Y) * size.Y))),
+				float32(math.Round(float64(max(1/size.X, float32(math.Abs(float64(end.X-start.X)))) * size.X))),
+				float32(math.Round(float64(max(1/size.Y, float32(math.Abs(float64(end.Y-start.Y)))) * size.Y))),
+			})
+			ic.Tool = ImageEditToolNone
+			globals.EventLog.Log("Image cropped.", false)
+
+		case ImageEditToolArrow:
+			ic.AddAnnotation(ImageAnnotation{Type: ImageAnnotationArrow, Points: ic.DragPoints, Color: ImageAnnotationColor})
+
+		case ImageEditToolRect:
+			ic.AddAnnotation(ImageAnnotation{Type: ImageAnnotationRect, Points: ic.DragPoints, Color: ImageAnnotationColor})
+
+		case ImageEditToolFreehand:
+			ic.AddAnnotation(ImageAnnotation{Type: ImageAnnotationFreehand, Points: ic.DragPoints, Color: ImageAnnotationColor})
+
+		}
+
+		ic.DragPoints = nil
+
+	}
+
+}
+
+func (ic *ImageContents) addTextAnnotation(point Vector) {
+
+	text, err := zenity.Entry("Annotation text:", zenity.Title("Add Text Annotation"))
+
+	if err == zenity.ErrCanceled || text == "" {
+		return
+	} else if err != nil {
+		globals.EventLog.Log(err.Error(), true)
+		return
+	}
+
+	// Text starts out a grid cell tall at the card's current size
+	top := ic.worldToImage(Vector{0, 0})
+	bottom := ic.worldToImage(Vector{0, globals.GridSize})
+
+	ic.AddAnnotation(ImageAnnotation{
+		Type:   ImageAnnotationText,
+		Points: []float32{point.X, point.Y},
+		Color:  ImageAnnotationColor,
+		Text:   text,
+		Size:   bottom.Y - top.Y,
+	})
+
+}
+
+func (ic *ImageContents) drawAnnotations() {
+
+	annotations := ic.Annotations()
+
+	if len(annotations) == 0 && !ic.Dragging {
+		return
+	}
+
+	camera := ic.Card.Page.Project.Camera
+
+	// Stay within whatever the caller was already clipping to, and go back to that afterward
+	clip := camera.TranslateRect(ic.Card.DisplayRect)
+	clipRect := &sdl.Rect{int32(clip.X), int32(clip.Y), int32(math.Ceil(float64(clip.W))), int32(math.Ceil(float64(clip.H)))}
+
+	var prevClip *sdl.Rect
+	if rect, err := globals.Renderer.ClipRect(); err == nil && rect.W > 0 && rect.H > 0 {
+		prevClip = &rect
+		if clipRect = clipRect.Intersection(prevClip); clipRect == nil {
+			return
+		}
+	}
+
+	globals.Renderer.SetClipRect(clipRect)
+	defer globals.Renderer.SetClipRect(prevClip)
+
+	for _, annotation := range annotations {
+		ic.drawAnnotation(annotation)
+	}
+
+	if ic.Dragging && len(ic.DragPoints) >= 4 {
+
+		switch ic.Tool {
+		case ImageEditToolCrop:
+			start := camera.TranslatePoint(ic.imageToWorld(Vector{ic.DragPoints[0], ic.DragPoints[1]}))
+			end := camera.TranslatePoint(ic.imageToWorld(Vector{ic.DragPoints[len(ic.DragPoints)-2], ic.DragPoints[len(ic.DragPoints)-1]}))
+			FillRect(min(start.X, end.X), min(start.Y, end.Y), float32(math.Abs(float64(end.X-start.X))), float32(math.Abs(float64(end.Y-start.Y))), NewColor(255, 255, 255, 60))
+			ThickRect(int32(min(start.X, end.X)), int32(min(start.Y, end.Y)), int32(math.Abs(float64(end.X-start.X))), int32(math.Abs(float64(end.Y-start.Y))), 1, ColorWhite)
+		case ImageEditToolArrow:
+			ic.drawAnnotation(ImageAnnotation{Type: ImageAnnotationArrow, Points: ic.DragPoints, Color: ImageAnnotationColor})
+		case ImageEditToolRect:
+			ic.drawAnnotation(ImageAnnotation{Type: ImageAnnotationRect, Points: ic.DragPoints, Color: ImageAnnotationColor})
+		case ImageEditToolFreehand:
+			ic.drawAnnotation(ImageAnnotation{Type: ImageAnnotationFreehand, Points: ic.DragPoints, Color: ImageAnnotationColor})
+		}
+
+	}
+
+}
+
+func (ic *ImageContents) drawAnnotation(annotation ImageAnnotation) {
+
+	if annotation.PointCount() == 0 {
+		return
+	}
+
+	camera := ic.Card.Page.Project.Camera
+	color := annotation.DrawColor()
+	thickness := max(1, 1.5*camera.Zoom)
+
+	screenPoint := func(index int) Vector {
+		return camera.TranslatePoint(ic.imageToWorld(annotation.Point(index)))
+	}
+
+	switch annotation.Type {
+
+	case ImageAnnotationArrow, ImageAnnotationFreehand:
+
+		for i := 1; i < annotation.PointCount(); i++ {
+			ThickLine(screenPoint(i-1), screenPoint(i), thickness, color)
+		}
+
+		if annotation.Type == ImageAnnotationArrow && annotation.PointCount() >= 2 {
+
+			start := screenPoint(0)
+			end := screenPoint(annotation.PointCount() - 1)
+
+			headLength := min(16*camera.Zoom, end.Distance(start)/3)
+			back := start.Sub(end).Unit().Scale(headLength)
+
+			ThickLine(end, end.Add(back.Rotate(math.Pi/6)), thickness, color)
+			ThickLine(end, end.Add(back.Rotate(-math.Pi/6)), thickness, color)
+
+		}
+
+	case ImageAnnotationRect:
+
+		if annotation.PointCount() >= 2 {
+			start := screenPoint(0)
+			end := screenPoint(annotation.PointCount() - 1)
+			ThickLine(start, Vector{end.X, start.Y}, thickness, color)
+			ThickLine(Vector{end.X, start.Y}, end, thickness, color)
+			ThickLine(end, Vector{start.X, end.Y}, thickness, color)
+			ThickLine(Vector{start.X, end.Y}, start, thickness, color)
+		}
+
+	case ImageAnnotationText:
+
+		top := ic.imageToWorld(Vector{0, 0})
+		bottom := ic.imageToWorld(Vector{0, annotation.Size})
+		scale := (bottom.Y - top.Y) / globals.GridSize * camera.Zoom
+
+		outline := ColorBlack
+		if annotation.DrawColor().IsDark() {
+			outline = ColorWhite
+		}
+
+		globals.TextRenderer.QuickRenderText(annotation.Text, screenPoint(0), scale, color, outline, AlignLeft)
+
+	}
+
+}
+
+func (ic *ImageContents) drawAnnotationTools() {
+
+	if !ic.Editing {
+		return
+	}
+
+	for _, button := range ic.ToolButtons {
+		button.Draw()
+	}
+
+	for _, button := range ic.ActionButtons {
+		button.Draw()
+	}
+
+}
+
+// cropSrc returns the source rectangle to draw the given resource with, or nil to draw all of it.
+func (ic *ImageContents) cropSrc(resource *Resource) *sdl.FRect {
+
+	if resource != ic.Resource || !ic.ValidResource() {
+		return nil
+	}
+
+	return ic.Crop()
+
+}
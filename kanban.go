@@ -0,0 +1,147 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// KanbanColumnProperty marks a Card as the header of a Kanban column.
+	KanbanColumnProperty = "kanbanColumn"
+	// KanbanDoneColumnName is the name of the column that completes Cards that are moved into it.
+	KanbanDoneColumnName = "done"
+)
+
+// IsKanbanColumn returns if the Card is the header of a Kanban column.
+func (card *Card) IsKanbanColumn() bool {
+	prop := card.Properties.GetIfExists(KanbanColumnProperty)
+	return prop != nil && prop.AsBool()
+}
+
+// KanbanColumns returns the Page's Kanban column header Cards, sorted from left to right.
+func (page *Page) KanbanColumns() []*Card {
+
+	columns := []*Card{}
+
+	for _, card := range page.Cards {
+		if card.IsKanbanColumn() {
+			columns = append(columns, card)
+		}
+	}
+
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].Rect.X < columns[j].Rect.X })
+
+	return columns
+
+}
+
+// ArrangeKanbanColumns moves every Card that overlaps or adjoins a column's stack (the header and the Cards already
+// below it), or that was already in it, into that stack, one after another in their vertical order; Cards further
+// down are left alone. Cards that
+// were just dropped into the "Done" column from elsewhere are completed.
+func (page *Page) ArrangeKanbanColumns() {
+
+	columns := page.KanbanColumns()
+
+	if len(columns) == 0 {
+		return
+	}
+
+	columnCards := map[*Card][]*Card{}
+	stackBottoms := map[*Card]float32{}
+
+	for _, column := range columns {
+		stackBottoms[column] = column.Rect.Y + column.Rect.H
+	}
+
+	candidates := []*Card{}
+
+	for _, card := range page.Cards {
+		if !card.IsKanbanColumn() && card.PinnedTo == nil && !card.Dragging {
+			candidates = append(candidates, card)
+		}
+	}
+
+	// Going from top to bottom lets each captured Card extend its column's stack for the Cards below it
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Rect.Y < candidates[j].Rect.Y })
+
+	for _, card := range candidates {
+
+		centerX := card.Rect.X + (card.Rect.W / 2)
+
+		for _, column := range columns {
+
+			if centerX < column.Rect.X || centerX >= column.Rect.X+column.Rect.W || card.Rect.Y <= column.Rect.Y {
+				continue
+			}
+
+			// Cards within a grid space of the bottom of the stack adjoin it. Cards that were already in the column stay
+			// in it unless they were just moved, so they slide up to fill gaps left by Cards that were removed.
+			adjoining := card.Rect.Y <= stackBottoms[column]+globals.GridSize
+			member := page.kanbanMembership[card] == column && !page.kanbanDropped[card]
+
+			if adjoining || member {
+				columnCards[column] = append(columnCards[column], card)
+				stackBottoms[column] = max(stackBottoms[column], card.Rect.Y+card.Rect.H)
+				break
+			}
+
+		}
+
+	}
+
+	membership := map[*Card]*Card{}
+
+	for _, column := range columns {
+
+		cards := columnCards[column]
+
+		sort.SliceStable(cards, func(i, j int) bool {
+			return cards[i].Rect.Y < cards[j].Rect.Y || (cards[i].Rect.Y == cards[j].Rect.Y && cards[i].Rect.X < cards[j].Rect.X)
+		})
+
+		done := strings.ToLower(strings.TrimSpace(column.Name())) == KanbanDoneColumnName
+
+		y := column.Rect.Y + column.Rect.H
+
+		for _, card := range cards {
+
+			if card.Rect.X != column.Rect.X || card.Rect.Y != y {
+				card.Rect.X = column.Rect.X
+				card.Rect.Y = y
+				card.LockPosition()
+				card.CreateUndoState = true
+			}
+
+			y += card.Rect.H
+
+			if done && card.Completable() && page.kanbanDropped[card] && page.kanbanMembership[card] != column {
+				card.Contents.Trigger(TriggerTypeSet)
+			}
+
+			membership[card] = column
+
+		}
+
+	}
+
+	page.kanbanMembership = membership
+	page.kanbanDropped = map[*Card]bool{}
+
+}
+
+// DropIntoKanban flags the Card as having been dropped so the Page's Kanban columns can be rearranged.
+func (page *Page) DropIntoKanban(card *Card) {
+
+	if !page.Kanban {
+		return
+	}
+
+	if page.kanbanDropped == nil {
+		page.kanbanDropped = map[*Card]bool{}
+	}
+
+	page.kanbanDropped[card] = true
+	page.ArrangeKanban = true
+
+}
@@ -131,6 +131,11 @@ const (
 	KBMapFillTool        = "Map: Fill Tool"
 	KBMapLineTool        = "Map: Line Tool"
 	KBMapQuickLineTool   = "Map: Quick Line"
+	KBMapRectangleTool   = "Map: Rectangle Tool"
+	KBMapEllipseTool     = "Map: Ellipse Tool"
+	KBMapSelectTool      = "Map: Selection Tool"
+	KBMapFilledShape     = "Map: Draw Filled Shape (Hold)"
+	KBMapCopySelection   = "Map: Copy Selection While Moving (Hold)"
 	KBMapPalette         = "Map: Open Palette"
 	KBMapShiftUp         = "Map: Shift Map Up"
 	KBMapShiftDown       = "Map: Shift Map Down"
@@ -568,6 +573,11 @@ func (kb *Keybindings) Default() {
 	kb.DefineKeyShortcut(KBMapFillTool, SDLK_F)
 	kb.DefineKeyShortcut(KBMapLineTool, SDLK_V)
 	kb.DefineKeyShortcut(KBMapQuickLineTool, SDLK_LSHIFT).triggerMode = TriggerModeHold
+	kb.DefineKeyShortcut(KBMapRectangleTool, SDLK_T)
+	kb.DefineKeyShortcut(KBMapEllipseTool, SDLK_Y)
+	kb.DefineKeyShortcut(KBMapSelectTool, SDLK_M)
+	kb.DefineKeyShortcut(KBMapFilledShape, SDLK_LCTRL).triggerMode = TriggerModeHold
+	kb.DefineKeyShortcut(KBMapCopySelection, SDLK_LCTRL).triggerMode = TriggerModeHold
 	kb.DefineKeyShortcut(KBMapPalette, SDLK_G)
 
 	kb.DefineKeyShortcut(KBMapShiftUp, SDLK_UP, SDLK_LCTRL, SDLK_LSHIFT)
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	LayoutAlignLeft   = "left"
+	LayoutAlignRight  = "right"
+	LayoutAlignTop    = "top"
+	LayoutAlignBottom = "bottom"
+)
+
+// moveCardTo moves the Card (and anything pinned to it that isn't also being laid out) to the given position, snapping
+// it to the grid. As all of the moved Cards create their undo states in the same frame, a layout is undone in one step.
+func moveCardTo(card *Card, x, y float32, laidOut map[*Card]bool) {
+
+	dx := x - card.Rect.X
+	dy := y - card.Rect.Y
+
+	if dx == 0 && dy == 0 {
+		return
+	}
+
+	card.Rect.X = x
+	card.Rect.Y = y
+	card.LockPosition()
+	card.CreateUndoState = true
+
+	pinned := NewSortedSet[*Card]()
+	card.appendAllPinnedCards(&pinned)
+
+	for _, c := range pinned {
+		if !laidOut[c] {
+			c.Rect.X += dx
+			c.Rect.Y += dy
+			c.LockPosition()
+			c.CreateUndoState = true
+		}
+	}
+
+}
+
+func layoutSet(cards []*Card) map[*Card]bool {
+	set := map[*Card]bool{}
+	for _, c := range cards {
+		set[c] = true
+	}
+	return set
+}
+
+// AlignCards aligns the given Cards to the outermost edge of the group on the specified side.
+func AlignCards(cards []*Card, side string) {
+
+	if len(cards) < 2 {
+		return
+	}
+
+	set := layoutSet(cards)
+
+	edge := float32(0)
+
+	for i, c := range cards {
+		var v float32
+		switch side {
+		case LayoutAlignLeft:
+			v = c.Rect.X
+		case LayoutAlignRight:
+			v = c.Rect.X + c.Rect.W
+		case LayoutAlignTop:
+			v = c.Rect.Y
+		case LayoutAlignBottom:
+			v = c.Rect.Y + c.Rect.H
+		}
+		if i == 0 ||
+			((side == LayoutAlignLeft || side == LayoutAlignTop) && v < edge) ||
+			((side == LayoutAlignRight || side == LayoutAlignBottom) && v > edge) {
+			edge = v
+		}
+	}
+
+	for _, c := range cards {
+		switch side {
+		case LayoutAlignLeft:
+			moveCardTo(c, edge, c.Rect.Y, set)
+		case LayoutAlignRight:
+			moveCardTo(c, edge-c.Rect.W, c.Rect.Y, set)
+		case LayoutAlignTop:
+			moveCardTo(c, c.Rect.X, edge, set)
+		case LayoutAlignBottom:
+			moveCardTo(c, c.Rect.X, edge-c.Rect.H, set)
+		}
+	}
+
+}
+
+// DistributeCards spaces the given Cards out evenly between the first and last Card, either horizontally or vertically.
+func DistributeCards(cards []*Card, horizontal bool) {
+
+	if len(cards) < 3 {
+		return
+	}
+
+	set := layoutSet(cards)
+
+	sorted := append([]*Card{}, cards...)
+
+	if horizontal {
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Rect.X < sorted[j].Rect.X })
+	} else {
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Rect.Y < sorted[j].Rect.Y })
+	}
+
+	first := sorted[0]
+	last := sorted[len(sorted)-1]
+
+	span := float32(0)
+	total := float32(0)
+
+	if horizontal {
+		span = last.Rect.X + last.Rect.W - first.Rect.X
+		for _, c := range sorted {
+			total += c.Rect.W
+		}
+	} else {
+		span = last.Rect.Y + last.Rect.H - first.Rect.Y
+		for _, c := range sorted {
+			total += c.Rect.H
+		}
+	}
+
+	gap := (span - total) / float32(len(sorted)-1)
+	if gap < 0 {
+		gap = 0
+	}
+
+	if horizontal {
+		x := first.Rect.X
+		for _, c := range sorted {
+			moveCardTo(c, x, c.Rect.Y, set)
+			x += c.Rect.W + gap
+		}
+	} else {
+		y := first.Rect.Y
+		for _, c := range sorted {
+			moveCardTo(c, c.Rect.X, y, set)
+			y += c.Rect.H + gap
+		}
+	}
+
+}
+
+// PackCardsIntoGrid arranges the given Cards into a roughly square grid, starting from the top-left of the group and
+// keeping their reading order (top-to-bottom, left-to-right).
+func PackCardsIntoGrid(cards []*Card) {
+
+	if len(cards) < 2 {
+		return
+	}
+
+	set := layoutSet(cards)
+
+	sorted := append([]*Card{}, cards...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Rect.Y < sorted[j].Rect.Y || (sorted[i].Rect.Y == sorted[j].Rect.Y && sorted[i].Rect.X < sorted[j].Rect.X)
+	})
+
+	startX, startY := float32(math.MaxFloat32), float32(math.MaxFloat32)
+	for _, c := range sorted {
+		startX = min(startX, c.Rect.X)
+		startY = min(startY, c.Rect.Y)
+	}
+
+	columns := int(math.Ceil(math.Sqrt(float64(len(sorted)))))
+
+	x, y := startX, startY
+	rowHeight := float32(0)
+
+	for i, c := range sorted {
+
+		if i > 0 && i%columns == 0 {
+			x = startX
+			y += rowHeight + globals.GridSize
+			rowHeight = 0
+		}
+
+		moveCardTo(c, x, y, set)
+
+		x += c.Rect.W + globals.GridSize
+		rowHeight = max(rowHeight, c.Rect.H)
+
+	}
+
+}
+
+// ArrangeCardsByLinks lays the given Cards out as a layered tree, following the direction of the links between them;
+// Cards that are linked from another Card in the group are placed in a row below it.
+func ArrangeCardsByLinks(cards []*Card) {
+
+	if len(cards) < 2 {
+		return
+	}
+
+	set := layoutSet(cards)
+
+	// Assign layers with a topological pass over the links between the Cards; anything left over is part of a cycle
+	// and just gets placed below everything else.
+	incoming := map[*Card]int{}
+	outgoing := map[*Card][]*Card{}
+
+	for _, c := range cards {
+		for _, link := range c.Links {
+			if link.Start == c && set[link.End] && link.End != c {
+				outgoing[c] = append(outgoing[c], link.End)
+				incoming[link.End]++
+			}
+		}
+	}
+
+	layer := map[*Card]int{}
+	queue := []*Card{}
+
+	for _, c := range cards {
+		if incoming[c] == 0 {
+			queue = append(queue, c)
+		}
+	}
+
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, child := range outgoing[c] {
+			layer[child] = max(layer[child], layer[c]+1)
+			incoming[child]--
+			if incoming[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	maxLayer := 0
+	for _, c := range cards {
+		maxLayer = max(maxLayer, layer[c])
+	}
+
+	for _, c := range cards {
+		if incoming[c] > 0 {
+			layer[c] = maxLayer + 1
+		}
+	}
+
+	layers := [][]*Card{}
+	for _, c := range cards {
+		for len(layers) <= layer[c] {
+			layers = append(layers, []*Card{})
+		}
+		layers[layer[c]] = append(layers[layer[c]], c)
+	}
+
+	startX, startY := float32(math.MaxFloat32), float32(math.MaxFloat32)
+	for _, c := range cards {
+		startX = min(startX, c.Rect.X)
+		startY = min(startY, c.Rect.Y)
+	}
+
+	spacing := globals.GridSize * 2
+
+	widths := make([]float32, len(layers))
+	widest := float32(0)
+
+	for i, l := range layers {
+		// Keep the original left-to-right order within each layer to avoid needlessly crossing links.
+		sort.SliceStable(l, func(a, b int) bool { return l[a].Rect.X < l[b].Rect.X })
+		for _, c := range l {
+			widths[i] += c.Rect.W + spacing
+		}
+		widths[i] -= spacing
+		widest = max(widest, widths[i])
+	}
+
+	y := startY
+
+	for i, l := range layers {
+
+		x := startX + (widest-widths[i])/2
+		rowHeight := float32(0)
+
+		for _, c := range l {
+			moveCardTo(c, x, y, set)
+			x += c.Rect.W + spacing
+			rowHeight = max(rowHeight, c.Rect.H)
+		}
+
+		y += rowHeight + spacing
+
+	}
+
+}
@@ -28,7 +28,6 @@ import (
 	"github.com/Zyko0/go-sdl3/ttf"
 	"github.com/adrg/xdg"
 	"github.com/blang/semver"
-	"github.com/cavaliergopher/grab/v3"
 	"github.com/gopxl/beep/v2"
 	"github.com/gopxl/beep/v2/speaker"
 	"github.com/hako/durafmt"
@@ -67,7 +66,7 @@ func init() {
 	globals.InputText = []rune{}
 	globals.CopyBuffer = NewCopyBuffer()
 	globals.State = StateNeutral
-	globals.GrabClient = grab.NewClient()
+	globals.Downloads = NewDownloadQueue(DownloadMaxConcurrent)
 	globals.MenuSystem = NewMenuSystem()
 	globals.Keybindings = NewKeybindings()
 	globals.RecentFiles = []string{}
@@ -381,6 +380,11 @@ func main() {
 	windowFrameTransparency := 1.0
 	canHandleTransparentWindows := true // Doesn't work on Wayland :(
 
+	// Walking every Page for the project's completion is too slow to do every frame, so it's only recounted every so often
+	progressText := "---"
+	progressProject := globals.Project
+	progressRecountTime := time.Time{}
+
 	for !quit {
 
 		fpsManager.Start()
@@ -424,6 +428,20 @@ func main() {
 
 		globals.MenuSystem.Get("main").Pages["root"].FindElement("time label", false).(*Label).SetText([]rune(time.Now().Format("Mon Jan 2 2006")))
 
+		if globals.Project != progressProject || time.Since(progressRecountTime) > time.Second {
+
+			progressProject = globals.Project
+			progressRecountTime = time.Now()
+
+			progressText = "---"
+			if completed, maximum := globals.Project.Pages[0].CompletionTotals(true); maximum > 0 {
+				progressText = fmt.Sprintf("%d%% Done", int(completed/maximum*100))
+			}
+
+		}
+
+		globals.MenuSystem.Get("main").Pages["root"].FindElement("progress label", false).(*Label).SetText([]rune(progressText))
+
 		screenWidth, screenHeight, err := globals.Renderer.CurrentOutputSize()
 
 		if err != nil {
@@ -598,6 +616,7 @@ func main() {
 			globals.Project.Destroy()
 			globals.Project = globals.NextProject
 			globals.NextProject = nil
+			globals.Project.ApplyMapPalette()
 			globals.Dispatcher.Run() // It's not modified, but we'll run the dispatcher manually
 			if globals.Project.CurrentPage.UpwardPage == nil {
 				globals.MenuSystem.Get("prev sub page").Close()
@@ -939,11 +958,13 @@ func ConstructMenus() {
 	timeLabel := NewLabel(time.Now().Format("Mon Jan 2 2006"), nil, false, AlignCenter)
 	row.Add("time label", timeLabel)
 
+	row.Add("progress label", NewLabel("0%", nil, false, AlignCenter))
+
 	row.ExpandElementSet.Select(timeLabel)
 
 	// File Menu
 
-	fileMenu := globals.MenuSystem.Add(NewMenu("file", &sdl.FRect{0, 48, 300, 350}, MenuCloseClickOut), false)
+	fileMenu := globals.MenuSystem.Add(NewMenu("file", &sdl.FRect{0, 48, 300, 430}, MenuCloseClickOut), false)
 	root = fileMenu.Pages["root"]
 
 	root.AddRow(AlignCenter).Add("New Project", NewButton("New Project", nil, nil, false, func() {
@@ -988,6 +1009,16 @@ func ConstructMenus() {
 
 	}))
 	root.AddRow(AlignCenter).Add("Save Project As...", NewButton("Save Project As...", &sdl.FRect{0, 0, 256, 32}, nil, false, func() { globals.Project.SaveAs() }))
+	root.AddRow(AlignCenter).Add("Package Project...", NewButton("Package Project...", nil, nil, false, func() {
+		packageMenu := globals.MenuSystem.Get("package project")
+		packageMenu.Center()
+		packageMenu.Open()
+		fileMenu.Close()
+	}))
+	root.AddRow(AlignCenter).Add("Import Package...", NewButton("Import Package...", nil, nil, false, func() {
+		globals.Project.OpenPackage()
+		fileMenu.Close()
+	}))
 	root.AddRow(AlignCenter).Add("Settings", NewButton("Settings", nil, nil, false, func() {
 		settings := globals.MenuSystem.Get("settings")
 		settings.Center()
@@ -1108,6 +1139,19 @@ func ConstructMenus() {
 
 	}))
 
+	root.AddRow(AlignCenter).Add("kanban", NewButton("Toggle Kanban Layout", nil, nil, false, func() {
+		page := globals.Project.CurrentPage
+		page.Kanban = !page.Kanban
+		page.ArrangeKanban = true
+		globals.Project.SetModifiedState()
+		if page.Kanban {
+			globals.EventLog.Log("Kanban layout enabled for the current page; mark column header cards from the Edit menu.", false)
+		} else {
+			globals.EventLog.Log("Kanban layout disabled for the current page.", false)
+		}
+		toolsMenu.Close()
+	}))
+
 	root.AddRow(AlignCenter).Add("", NewButton("Flatten Project", nil, nil, false, func() {
 
 		common := globals.MenuSystem.Get("common")
@@ -1235,6 +1279,26 @@ func ConstructMenus() {
 		menusMenu.Close()
 	}))
 
+	root.AddRow(AlignCenter).Add("Progress History", NewButton("Progress History", nil, nil, false, func() {
+		globals.MenuSystem.Get("progress history").Open()
+		menusMenu.Close()
+	}))
+
+	root.AddRow(AlignCenter).Add("Minimap", NewButton("Minimap", nil, nil, false, func() {
+		globals.MenuSystem.Get("minimap").Open()
+		menusMenu.Close()
+	}))
+
+	root.AddRow(AlignCenter).Add("Resources", NewButton("Resources", nil, nil, false, func() {
+		globals.MenuSystem.Get("resources").Open()
+		menusMenu.Close()
+	}))
+
+	root.AddRow(AlignCenter).Add("Missing Files", NewButton("Missing Files", nil, nil, false, func() {
+		globals.MenuSystem.Get("missing files").Open()
+		menusMenu.Close()
+	}))
+
 	loadRecent := globals.MenuSystem.Add(NewMenu("load recent", &sdl.FRect{128, 96, 512, 128}, MenuCloseClickOut), false)
 	loadRecent.OnOpen = func() {
 
@@ -1361,6 +1425,31 @@ func ConstructMenus() {
 	root.AddRow(AlignCenter).Add("add icons", NewButton("Add Icons", nil, nil, false, func() {
 		editMenu.SetPage("add icons")
 	}))
+	root.AddRow(AlignCenter).Add("arrange", NewButton("Arrange", nil, nil, false, func() {
+		editMenu.SetPage("arrange")
+	}))
+	root.AddRow(AlignCenter).Add("toggle rollup", NewButton("Toggle Progress Roll-up", nil, nil, false, func() {
+		count := 0
+		for card := range globals.Project.CurrentPage.Selection.Cards {
+			if card.Completable() || card.ContentType == ContentTypeSubpage {
+				prop := card.Properties.Get("rollup")
+				prop.Set(!prop.AsBool())
+				count++
+			}
+		}
+		globals.EventLog.Log("Progress roll-up toggled for %d card(s).", false, count)
+	}))
+	root.AddRow(AlignCenter).Add("toggle kanban column", NewButton("Toggle Kanban Column", nil, nil, false, func() {
+		page := globals.Project.CurrentPage
+		for card := range page.Selection.Cards {
+			prop := card.Properties.Get(KanbanColumnProperty)
+			prop.Set(!prop.AsBool())
+			card.CreateUndoState = true
+		}
+		page.ArrangeKanban = true
+		globals.Project.SetModifiedState()
+		globals.EventLog.Log("Kanban column header toggled for %d card(s).", false, len(page.Selection.Cards))
+	}))
 
 	setColor := editMenu.AddPage("set color")
 	setColor.AddRow(AlignCenter).Add("label", NewLabel("Set Color", nil, false, AlignCenter))
@@ -1505,6 +1594,47 @@ func ConstructMenus() {
 		globals.EventLog.Log("Color reset to default for %d card(s).", false, len(selectedCards))
 	}))
 
+	arrange := editMenu.AddPage("arrange")
+	arrange.AddRow(AlignCenter).Add("label", NewLabel("Arrange", &sdl.FRect{0, 0, 192, 32}, false, AlignCenter))
+
+	arrangeSelection := func(layoutName string, layout func(cards []*Card)) func() {
+		return func() {
+			cards := globals.Project.CurrentPage.Selection.AsSlice()
+			if len(cards) < 2 {
+				globals.EventLog.Log("Select at least two cards to arrange them.", false)
+				return
+			}
+			layout(cards)
+			globals.EventLog.Log("%s applied to %d card(s).", false, layoutName, len(cards))
+		}
+	}
+
+	row = arrange.AddRow(AlignCenter)
+	row.Add("align left", NewButton("Align Left", nil, nil, false, arrangeSelection("Align Left", func(cards []*Card) { AlignCards(cards, LayoutAlignLeft) })))
+	row.Add("align right", NewButton("Align Right", nil, nil, false, arrangeSelection("Align Right", func(cards []*Card) { AlignCards(cards, LayoutAlignRight) })))
+	row.ExpandElementSet.SelectAll()
+
+	row = arrange.AddRow(AlignCenter)
+	row.Add("align top", NewButton("Align Top", nil, nil, false, arrangeSelection("Align Top", func(cards []*Card) { AlignCards(cards, LayoutAlignTop) })))
+	row.Add("align bottom", NewButton("Align Bottom", nil, nil, false, arrangeSelection("Align Bottom", func(cards []*Card) { AlignCards(cards, LayoutAlignBottom) })))
+	row.ExpandElementSet.SelectAll()
+
+	row = arrange.AddRow(AlignCenter)
+	row.Add("distribute horizontally", NewButton("Distribute Horizontally", nil, nil, false, arrangeSelection("Horizontal distribution", func(cards []*Card) { DistributeCards(cards, true) })))
+	row.ExpandElementSet.SelectAll()
+
+	row = arrange.AddRow(AlignCenter)
+	row.Add("distribute vertically", NewButton("Distribute Vertically", nil, nil, false, arrangeSelection("Vertical distribution", func(cards []*Card) { DistributeCards(cards, false) })))
+	row.ExpandElementSet.SelectAll()
+
+	row = arrange.AddRow(AlignCenter)
+	row.Add("pack into grid", NewButton("Pack into Grid", nil, nil, false, arrangeSelection("Grid packing", PackCardsIntoGrid)))
+	row.ExpandElementSet.SelectAll()
+
+	row = arrange.AddRow(AlignCenter)
+	row.Add("arrange by links", NewButton("Arrange by Links", nil, nil, false, arrangeSelection("Link tree layout", ArrangeCardsByLinks)))
+	row.ExpandElementSet.SelectAll()
+
 	setType := editMenu.AddPage("set type")
 	setType.AddRow(AlignCenter).Add("label", NewLabel("Set Type", &sdl.FRect{0, 0, 192, 32}, false, AlignCenter))
 
@@ -1923,6 +2053,23 @@ func ConstructMenus() {
 	row.Add("no", NewButton("No", &sdl.FRect{0, 0, 128, 32}, nil, false, func() { confirmLoad.Close() }))
 	confirmLoad.Recreate(root.IdealSize().X+48, root.IdealSize().Y+16)
 
+	packageMenu := globals.MenuSystem.Add(NewMenu("package project", &sdl.FRect{0, 0, 32, 32}, MenuCloseButton), true)
+	packageMenu.Draggable = true
+	root = packageMenu.Pages["root"]
+	root.AddRow(AlignCenter).Add("label", NewLabel("Package the project and the files it uses:", nil, false, AlignCenter))
+	root.AddRow(AlignCenter).Add("label2", NewLabel("Web links and pasted images aren't copied.", nil, false, AlignCenter))
+	row = root.AddRow(AlignCenter)
+	row.Add("zip", NewButton("As Zip File", &sdl.FRect{0, 0, 160, 32}, nil, false, func() {
+		packageMenu.Close()
+		globals.Project.PackageAs(true)
+	}))
+	row.Add("folder", NewButton("Into Folder", &sdl.FRect{0, 0, 160, 32}, nil, false, func() {
+		packageMenu.Close()
+		globals.Project.PackageAs(false)
+	}))
+	row.Add("cancel", NewButton("Cancel", &sdl.FRect{0, 0, 128, 32}, nil, false, func() { packageMenu.Close() }))
+	packageMenu.Recreate(root.IdealSize().X+48, root.IdealSize().Y+16)
+
 	// // Confirm Load Menu - do this after Project.Modified works again.
 
 	// confirmQuit := globals.MenuSystem.Add(NewMenu(&sdl.FRect{0, 0, 32, 32}, true), "confirm quit", true)
@@ -2188,26 +2335,87 @@ from this directory.`))
 	cachePath.RegexString = RegexNoNewlines
 	row.Add("", cachePath)
 
+	row = general.AddRow(AlignCenter)
+	row.Add("", NewButton("Browse", nil, nil, false, func() {
+
+		if path, err := zenity.SelectFile(zenity.Title("Select External Download Cache Directory"), zenity.Directory()); err == nil {
+			globals.Project.Properties.Get(ProjectCacheDirectory).Set(path)
+		}
+
+	}))
+
+	row.Add("", NewButton("Clear", nil, nil, false, func() {
+		globals.Project.Properties.Get(ProjectCacheDirectory).Set("")
+	}))
+
+	row = general.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+
+	row = general.AddRow(AlignCenter)
+	row.Add("hint", NewTooltip(`Browser Profile Directory:
+The browser user-data directory used by Web Cards in
+this project that are set to use the project's profile
+(or their own isolated profile, which is stored within
+it). Cookies, logins, and so on are kept separate from
+the global browser profile.
+
+If not specified, it defaults to a folder next to the
+project file.`))
+	row.Add("", NewLabel("Browser Profile Directory For Current Project:", nil, false, AlignLeft))
+	profilePath := NewLabel("", nil, false, AlignLeft)
+	profilePath.Editable = true
+	profilePath.RegexString = RegexNoNewlines
+	row.Add("", profilePath)
+
 	general.OnUpdate = func() {
 		cachePath.Property = globals.Project.Properties.Get(ProjectCacheDirectory)
+		profilePath.Property = globals.Project.Properties.Get(ProjectBrowserProfileDirectory)
 	}
 
 	row = general.AddRow(AlignCenter)
 	row.Add("", NewButton("Browse", nil, nil, false, func() {
 
-		if path, err := zenity.SelectFile(zenity.Title("Select External Download Cache Directory"), zenity.Directory()); err == nil {
-			globals.Project.Properties.Get(ProjectCacheDirectory).Set(path)
+		if path, err := zenity.SelectFile(zenity.Title("Select Browser Profile Directory"), zenity.Directory()); err == nil {
+			globals.Project.Properties.Get(ProjectBrowserProfileDirectory).Set(path)
 		}
 
 	}))
 
 	row.Add("", NewButton("Clear", nil, nil, false, func() {
-		globals.Project.Properties.Get(ProjectCacheDirectory).Set("")
+		globals.Project.Properties.Get(ProjectBrowserProfileDirectory).Set("")
 	}))
 
 	row = general.AddRow(AlignCenter)
 	row.Add("", NewSpacer(nil))
 
+	row = general.AddRow(AlignCenter)
+	row.Add("hint", NewTooltip(`What Web Cards use to load and display pages.
+
+Local Chrome: Starts a headless copy of your local
+Chrome / Chromium installation. If it can't be found,
+Web Cards fall back to Text Only.
+
+Remote Debugging URL: Connects to an already running
+Chrome-based browser that was started with remote
+debugging enabled (e.g. "ws://127.0.0.1:9222/" or
+"http://127.0.0.1:9222/").
+
+Text Only: Displays just the text of pages without
+needing a browser at all. Links can't be clicked.
+
+Existing Web Cards keep their backend until they're
+recreated or the project is reloaded.`))
+	row.Add("", NewLabel("Web Card Backend:", nil, false, AlignLeft))
+	row.Add("", NewDropdown(&sdl.FRect{0, 0, 256, 32}, false, nil, globals.Settings.Get(SettingsBrowserBackend), BrowserBackendChrome, BrowserBackendRemote, BrowserBackendText))
+
+	row = general.AddRow(AlignCenter)
+	row.Add("", NewLabel("Remote Debugging URL:", nil, false, AlignLeft))
+	browserRemoteURL := NewLabel("", nil, false, AlignLeft)
+	browserRemoteURL.Editable = true
+	browserRemoteURL.RegexString = RegexNoNewlines
+	browserRemoteURL.Property = globals.Settings.Get(SettingsBrowserRemoteURL)
+	row.Add("", browserRemoteURL)
+
 	row = general.AddRow(AlignCenter)
 	row.Add("hint", NewTooltip(`Where to find the browser to use for Web Cards.
 Defaults to your local Chrome / Chromium installation.
@@ -2538,7 +2746,7 @@ of images can be.`))
 
 		for _, page := range globals.Project.Pages {
 			for _, card := range page.Cards {
-				if web, ok := card.Contents.(*InternetContents); ok {
+				if web, ok := card.Contents.(*InternetContents); ok && web.BrowserTab != nil {
 					web.BrowserTab.UpdateZoom()
 				}
 			}
@@ -3109,6 +3317,7 @@ where the cursor is over the window.`))
 
 		if len(searchLabel.Text) == 0 {
 			foundLabel.SetText([]rune("0 of 0"))
+			globals.FoundCards = foundCards
 			return
 		}
 
@@ -3146,6 +3355,8 @@ where the cursor is over the window.`))
 
 		}
 
+		globals.FoundCards = foundCards
+
 		if foundIndex >= len(foundCards) {
 			foundIndex = 0
 		} else if foundIndex < 0 {
@@ -3199,6 +3410,10 @@ where the cursor is over the window.`))
 		searchLabel.Selection.SelectAll()
 	}
 
+	find.OnClose = func() {
+		globals.FoundCards = nil
+	}
+
 	var caseSensitiveButton *IconButton
 	caseSensitiveButton = NewIconButton(0, 0, &sdl.FRect{112, 224, 32, 32}, globals.GUITexture, false, func() {
 		caseSensitive = !caseSensitive
@@ -3564,214 +3779,1159 @@ horizontally.`))
 
 	}
 
-	// Map palette menu
+	// Minimap Menu
 
-	paletteMenu := globals.MenuSystem.Add(NewMenu("map palette menu", &sdl.FRect{0, 0, 300, 560}, MenuCloseButton), false)
-	paletteMenu.Center()
-	paletteMenu.Draggable = true
-	paletteMenu.Resizeable = true
+	minimapMenu := globals.MenuSystem.Add(NewMenu("minimap", &sdl.FRect{9999, 9999, 320, 280}, MenuCloseButton), false)
+	minimapMenu.Draggable = true
+	minimapMenu.Resizeable = true
+	minimapMenu.AnchorMode = MenuAnchorBottomRight
 
-	root = paletteMenu.Pages["root"]
+	root = minimapMenu.Pages["root"]
 
-	root.AddRow(AlignCenter).Add("color label", NewLabel("Colors", nil, false, AlignCenter))
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Minimap", nil, false, AlignCenter))
 
 	row = root.AddRow(AlignCenter)
+	row.Add("minimap", NewMinimap(&sdl.FRect{0, 0, 280, 200}))
+	row.ExpandElementSet.SelectAll()
 
-	for i, color := range MapPaletteColors {
+	// Resources Menu
 
-		if i%4 == 0 && i > 0 {
-			row = root.AddRow(AlignCenter)
-		}
-		index := i
-		iconButton := NewIconButton(0, 0, &sdl.FRect{48, 128, 32, 32}, globals.GUITexture, false, func() { MapDrawingColor = index + 1 })
-		iconButton.BGIconSrc = &sdl.FRect{144, 96, 32, 32}
-		iconButton.Tint = color
-		row.Add("paletteColor"+strconv.Itoa(i), iconButton)
-	}
+	resourcesMenu := globals.MenuSystem.Add(NewMenu("resources", &sdl.FRect{globals.ScreenSize.X/2 - (700 / 2), 9999, 700, 400}, MenuCloseButton), false)
+	resourcesMenu.Draggable = true
+	resourcesMenu.Resizeable = true
+	resourcesMenu.AnchorMode = MenuAnchorBottom
 
-	root.AddRow(AlignCenter).Add("pattern label", NewLabel("Patterns", nil, false, AlignCenter))
+	resourcesRoot := resourcesMenu.Pages["root"]
 
-	button = NewButton("Solid", nil, &sdl.FRect{48, 128, 32, 32}, false, func() { MapPattern = MapPatternSolid })
-	row = root.AddRow(AlignCenter)
-	row.Add("pattern solid", button)
+	resourceHeaderRows := []*ContainerRow{
+		NewContainerRow(resourcesRoot, AlignCenter), // Title
+		NewContainerRow(resourcesRoot, AlignCenter), // Summary
+		NewContainerRow(resourcesRoot, AlignCenter), // Actions
+	}
 
-	row = root.AddRow(AlignCenter)
-	button = NewButton("Crossed", nil, &sdl.FRect{80, 128, 32, 32}, false, func() { MapPattern = MapPatternCrossed })
-	row.Add("pattern crossed", button)
+	resourceHeaderRows[0].Add("", NewLabel("Resources", nil, false, AlignCenter))
 
-	button = NewButton("Dotted", nil, &sdl.FRect{112, 128, 32, 32}, false, func() { MapPattern = MapPatternDotted })
-	row = root.AddRow(AlignCenter)
-	row.Add("pattern dotted", button)
+	resourceSummary := NewLabel("0 resources", nil, false, AlignCenter)
+	resourceHeaderRows[1].Add("", resourceSummary)
 
-	button = NewButton("Checked", nil, &sdl.FRect{144, 128, 32, 32}, false, func() { MapPattern = MapPatternChecked })
-	row = root.AddRow(AlignCenter)
-	row.Add("pattern checked", button)
+	type resourceEntry struct {
+		Resource    *Resource
+		Rows        []*ContainerRow
+		Info        *Label
+		Size        int64
+		Users       int
+		Downloading bool
+	}
 
-	button = NewButton("Terrain", nil, &sdl.FRect{144, 128, 32, 32}, false, func() { MapPattern = MapPatternTerrain })
-	row = root.AddRow(AlignCenter)
-	row.Add("pattern terrain", button)
+	resourceEntries := []*resourceEntry{}
+	resourceCount := -1
+	resourceRecountTime := time.Time{}
+	var refreshResources func()
 
-	root.AddRow(AlignCenter).Add("shift label", NewLabel("Shift", nil, false, AlignCenter))
+	// updateResourceInfo updates an entry's info text. Checking the size of a file on disk and counting the cards that
+	// use it are too slow to do for every resource every frame, so they're only done when recounting.
+	updateResourceInfo := func(entry *resourceEntry, recount bool) {
 
-	shiftNumberSpinner := NewNumberSpinner(&sdl.FRect{0, 0, 128, 32}, false, nil)
-	shiftNumberSpinner.SetLimits(1, math.MaxFloat64)
-	root.AddRow(AlignCenter).Add("shift number", shiftNumberSpinner)
+		resource := entry.Resource
+		entry.Downloading = resource.Download != nil && !resource.Download.IsComplete()
 
-	left := NewButton("Left", nil, nil, false, func() {
+		if recount || entry.Downloading {
+			entry.Size = resource.FileSize()
+		}
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Push(-int(shiftNumberSpinner.Value), 0, true)
-			}
+		if recount {
+			entry.Users = len(resource.Users(globals.Project))
 		}
-		globals.EventLog.Log("Map shifted by %d to the left.", false, int(shiftNumberSpinner.Value))
 
-	})
+		status := "Local File"
 
-	right := NewButton("Right", nil, nil, false, func() {
+		if resource.OriginURL != "" {
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Push(int(shiftNumberSpinner.Value), 0, true)
+			if err := resource.DownloadError(); err != nil {
+				status = "Download Failed (" + err.Error() + ")"
+			} else if !resource.FinishedDownloading() {
+				if perc := resource.DownloadPercentage(); perc >= 0 {
+					status = fmt.Sprintf("Downloading (%d%%)", int(perc*100))
+				} else {
+					status = "Downloading"
+				}
+			} else if resource.TempFile {
+				status = "Downloaded (Temporary)"
+			} else {
+				status = "Downloaded (Project Cache)"
 			}
-		}
-		globals.EventLog.Log("Map shifted by %d to the right.", false, int(shiftNumberSpinner.Value))
 
-	})
+		}
 
-	up := NewButton("Up", nil, nil, false, func() {
+		usage := fmt.Sprintf("Used by %d cards", entry.Users)
+		if entry.Users == 1 {
+			usage = "Used by 1 card"
+		} else if entry.Users == 0 {
+			usage = "Unused"
+		}
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Push(0, -int(shiftNumberSpinner.Value), true)
-			}
+		if text := fmt.Sprintf("%s | %s | %s", formatFileSize(entry.Size), status, usage); entry.Info.TextAsString() != text {
+			entry.Info.SetText([]rune(text))
 		}
 
-		globals.EventLog.Log("Map shifted by %d upward.", false, int(shiftNumberSpinner.Value))
+	}
 
-	})
+	resourceHeaderRows[2].Add("", NewButton("Localize All", nil, nil, false, func() {
 
-	down := NewButton("Down", nil, nil, false, func() {
+		count := 0
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Push(0, int(shiftNumberSpinner.Value), true)
+		for _, resource := range globals.Resources {
+			if resource.OriginURL != "" && resource.TempFile {
+				if err := resource.Localize(globals.Project); err != nil {
+					globals.EventLog.Log("Couldn't localize resource %s: %s", true, resource.Name, err.Error())
+					break
+				}
+				count++
 			}
 		}
 
-		globals.EventLog.Log("Map shifted by %d downward.", false, int(shiftNumberSpinner.Value))
-
-	})
+		globals.EventLog.Log("Localized %d resources into the project cache directory.", false, count)
+		refreshResources()
 
-	row = root.AddRow(AlignCenter)
-	row.Add("shift up", up)
+	}))
 
-	row = root.AddRow(AlignCenter)
-	row.Add("shift left", left)
-	row.Add("spacer", NewSpacer(&sdl.FRect{0, 0, 32, 32}))
-	row.Add("shift right", right)
+	resourceHeaderRows[2].Add("", NewButton("Purge Unused", nil, nil, false, func() {
+		count, freed := globals.Resources.PurgeUnused(globals.Project)
+		globals.EventLog.Log("Purged %d unused resources, freeing %s.", false, count, formatFileSize(freed))
+		refreshResources()
+	}))
 
-	row = root.AddRow(AlignCenter)
-	row.Add("shift down", down)
+	refreshResources = func() {
 
-	root.AddRow(AlignCenter).Add("rotate label", NewLabel("Rotate", nil, false, AlignCenter))
+		for _, entry := range resourceEntries {
+			for _, row := range entry.Rows {
+				row.Destroy()
+			}
+		}
 
-	row = root.AddRow(AlignCenter)
-	row.Add("rotate left", NewButton("Left", nil, nil, false, func() {
+		resourceEntries = []*resourceEntry{}
+		resourceCount = len(globals.Resources)
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Rotate(false)
+		names := []string{}
+		for name, resource := range globals.Resources {
+			if !resource.IsAsset() {
+				names = append(names, name)
 			}
 		}
 
-		globals.EventLog.Log("Map rotated 90 degrees counter-clockwise.", false)
+		sort.Strings(names)
 
-	}))
+		resourcesRoot.Rows = append([]*ContainerRow{}, resourceHeaderRows...)
 
-	row.Add("spacer", NewSpacer(&sdl.FRect{0, 0, 32, 32}))
+		for _, name := range names {
 
-	row.Add("rotate right", NewButton("Right", nil, nil, false, func() {
+			resource := globals.Resources[name]
+			entry := &resourceEntry{Resource: resource}
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Rotate(true)
+			row := NewContainerRow(resourcesRoot, AlignLeft)
+			row.AlternateBGColor = true
+			nameLabel := NewLabel(filepath.Base(resource.LocalFilepath), nil, false, AlignLeft)
+			nameLabel.SetMaxSize(640, 32)
+			row.Add("name", nameLabel)
+			entry.Rows = append(entry.Rows, row)
+
+			if resource.OriginURL != "" {
+				row = NewContainerRow(resourcesRoot, AlignLeft)
+				originLabel := NewLabel("From: "+resource.OriginURL, nil, false, AlignLeft)
+				originLabel.SetMaxSize(640, 32)
+				row.Add("origin", originLabel)
+				entry.Rows = append(entry.Rows, row)
 			}
-		}
 
-		globals.EventLog.Log("Map rotated 90 degrees clockwise.", false)
+			row = NewContainerRow(resourcesRoot, AlignLeft)
+			entry.Info = NewLabel("Info", nil, false, AlignLeft)
+			row.Add("info", entry.Info)
+			entry.Rows = append(entry.Rows, row)
 
-	}))
+			row = NewContainerRow(resourcesRoot, AlignLeft)
 
-	root.AddRow(AlignCenter).Add("flip label", NewLabel("Flip", nil, false, AlignCenter))
+			row.Add("show", NewButton("Show Cards", nil, nil, false, func() {
 
-	row = root.AddRow(AlignCenter)
-	row.Add("flip horizontally", NewButton("Horizontally", nil, nil, false, func() {
+				users := resource.Users(globals.Project)
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Flip(false)
-			}
-		}
+				if len(users) == 0 {
+					globals.EventLog.Log("No cards use this resource.", false)
+					return
+				}
 
-		globals.EventLog.Log("Map flipped horizontally.", false)
+				if users[0].Page != globals.Project.CurrentPage {
+					globals.Project.SetPage(users[0].Page)
+				}
 
-	}))
+				page := globals.Project.CurrentPage
+				page.Selection.Clear()
 
-	row = root.AddRow(AlignCenter)
+				onPage := []*Card{}
+				for _, card := range users {
+					if card.Page == page {
+						page.Selection.Add(card)
+						onPage = append(onPage, card)
+					}
+				}
 
-	row.Add("flip vertically", NewButton("Vertically", nil, nil, false, func() {
+				globals.Project.Camera.FocusOn(false, onPage...)
+
+			}))
+
+			if resource.OriginURL != "" {
+
+				row.Add("redownload", NewButton("Re-download", nil, nil, false, func() {
+					if err := globals.Resources.Redownload(resource); err != nil {
+						globals.EventLog.Log("Couldn't re-download resource: %s", true, err.Error())
+					} else {
+						globals.EventLog.Log("Re-downloading %s.", false, resource.OriginURL)
+					}
+					refreshResources()
+				}))
+
+				if resource.TempFile {
+					row.Add("localize", NewButton("Localize", nil, nil, false, func() {
+						if err := resource.Localize(globals.Project); err != nil {
+							globals.EventLog.Log("Couldn't localize resource: %s", true, err.Error())
+						} else {
+							globals.EventLog.Log("Resource localized to %s.", false, resource.LocalFilepath)
+						}
+						refreshResources()
+					}))
+				}
 
-		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
-			if card.ContentType == ContentTypeMap {
-				card.Contents.(*MapContents).MapData.Flip(true)
 			}
+
+			entry.Rows = append(entry.Rows, row)
+
+			row = NewContainerRow(resourcesRoot, AlignLeft)
+			row.Add("", NewSpacer(&sdl.FRect{0, 0, 32, 8}))
+			entry.Rows = append(entry.Rows, row)
+
+			resourceEntries = append(resourceEntries, entry)
+			resourcesRoot.Rows = append(resourcesRoot.Rows, entry.Rows...)
+
 		}
 
-		globals.EventLog.Log("Map flipped horizontally.", false)
+		resourceRecountTime = time.Time{}
 
-	}))
+	}
 
-	// Table menu
+	resourcesMenu.OnOpen = refreshResources
 
-	tableMenu := globals.MenuSystem.Add(NewMenu("table settings menu", &sdl.FRect{999999, 0, 500, 275}, MenuCloseButton), false)
-	tableMenu.Resizeable = true
-	tableMenu.Draggable = true
-	tableMenu.AnchorMode = MenuAnchorTopRight
+	resourcesRoot.OnUpdate = func() {
 
-	root = tableMenu.Pages["root"]
-	row = root.AddRow(AlignCenter)
-	row.Add("", NewLabel("Table Settings", nil, false, AlignCenter))
+		// Resources have been loaded or purged elsewhere
+		if len(globals.Resources) != resourceCount {
+			refreshResources()
+		}
 
-	row = root.AddRow(AlignCenter)
-	row.Add("label", NewLabel("Visualize As:", nil, false, AlignCenter))
-	row = root.AddRow(AlignCenter)
-	row.Add("table mode", NewButtonGroup(&sdl.FRect{0, 0, 32, 32}, false, func(index int) {
+		// Sizes and users are recounted every so often, but downloads in progress are shown as they happen
+		recount := time.Since(resourceRecountTime) > time.Second
 
-		mode := tableMenu.Pages["root"].FindElement("table mode", false).(*ButtonGroup)
-		for _, card := range globals.Project.CurrentPage.Cards {
-			if card.IsSelected() && card.ContentType == ContentTypeTable {
-				card.Contents.(*TableContents).TableData.ValueDisplayMode = mode.ChosenIndex
-				card.Contents.(*TableContents).TableData.Changed = true
-			}
+		if recount {
+			resourceRecountTime = time.Now()
 		}
 
-	}, nil, "Checkmarks", "Letters", "Numbers"))
-	row.ExpandElementSet.SelectAll()
+		totalSize := int64(0)
 
-	row = root.AddRow(AlignCenter)
-	row.Add("", NewSpacer(nil))
-	row = root.AddRow(AlignCenter)
-	row.Add("", NewLabel("Table Controls", nil, false, AlignCenter))
+		for _, entry := range resourceEntries {
 
-	row = root.AddRow(AlignCenter)
-	row.Add("swap", NewButton("Swap Rows and Columns", nil, nil, false, func() {
-		for c := range globals.Project.CurrentPage.Selection.Cards {
-			if c.ContentType == ContentTypeTable {
-				c.Contents.(*TableContents).TableData.SwapColumnsAndRows()
+			if recount || entry.Downloading {
+				updateResourceInfo(entry, recount)
 			}
+
+			totalSize += entry.Size
+
 		}
-	}))
+
+		if summary := fmt.Sprintf("%d resources, %s total", len(resourceEntries), formatFileSize(totalSize)); resourceSummary.TextAsString() != summary {
+			resourceSummary.SetText([]rune(summary))
+		}
+
+	}
+
+	// Missing Files Menu
+
+	missingMenu := globals.MenuSystem.Add(NewMenu("missing files", &sdl.FRect{globals.ScreenSize.X/2 - (700 / 2), 9999, 700, 400}, MenuCloseButton), false)
+	missingMenu.Draggable = true
+	missingMenu.Resizeable = true
+	missingMenu.AnchorMode = MenuAnchorBottom
+
+	missingRoot := missingMenu.Pages["root"]
+
+	missingHeaderRows := []*ContainerRow{
+		NewContainerRow(missingRoot, AlignCenter), // Title
+		NewContainerRow(missingRoot, AlignCenter), // Summary
+		NewContainerRow(missingRoot, AlignCenter), // Actions
+	}
+
+	missingHeaderRows[0].Add("", NewLabel("Missing Files", nil, false, AlignCenter))
+
+	missingSummary := NewLabel("No missing files", nil, false, AlignCenter)
+	missingHeaderRows[1].Add("", missingSummary)
+
+	missingFiles := []*MissingFile{}
+	missingRows := []*ContainerRow{}
+	var refreshMissingFiles func()
+
+	missingHeaderRows[2].Add("", NewButton("Search in Folder...", nil, nil, false, func() {
+
+		if len(missingFiles) == 0 {
+			return
+		}
+
+		folder, err := zenity.SelectFile(zenity.Title("Select Folder to Search for Missing Files..."), zenity.Directory())
+		if err == zenity.ErrCanceled {
+			return
+		} else if err != nil {
+			panic(err)
+		}
+
+		found, err := FindMissingFiles(missingFiles, folder)
+		if err != nil {
+			globals.EventLog.Log("Couldn't search folder for missing files: %s", true, err.Error())
+			return
+		}
+
+		relinked := globals.Project.Relink(found)
+		globals.EventLog.Log("Found %d of %d missing files; relinked %d cards.", false, len(found), len(missingFiles), relinked)
+		refreshMissingFiles()
+
+	}))
+
+	missingHeaderRows[2].Add("", NewButton("Refresh", nil, nil, false, func() { refreshMissingFiles() }))
+
+	refreshMissingFiles = func() {
+
+		for _, row := range missingRows {
+			row.Destroy()
+		}
+
+		missingRows = []*ContainerRow{}
+		missingFiles = globals.Project.MissingFiles()
+
+		sort.SliceStable(missingFiles, func(i, j int) bool { return missingFiles[i].Path < missingFiles[j].Path })
+
+		if len(missingFiles) == 0 {
+			missingSummary.SetText([]rune("No missing files"))
+		} else {
+			missingSummary.SetText([]rune(fmt.Sprintf("%d missing files", len(missingFiles))))
+		}
+
+		missingRoot.Rows = append([]*ContainerRow{}, missingHeaderRows...)
+
+		for _, m := range missingFiles {
+
+			missing := m
+
+			row := NewContainerRow(missingRoot, AlignLeft)
+			row.AlternateBGColor = true
+			pathLabel := NewLabel(missing.String(), nil, false, AlignLeft)
+			pathLabel.SetMaxSize(640, 32)
+			row.Add("path", pathLabel)
+			missingRows = append(missingRows, row)
+
+			row = NewContainerRow(missingRoot, AlignLeft)
+
+			row.Add("show", NewButton("Show Cards", nil, nil, false, func() {
+
+				if missing.Cards[0].Page != globals.Project.CurrentPage {
+					globals.Project.SetPage(missing.Cards[0].Page)
+				}
+
+				page := globals.Project.CurrentPage
+				page.Selection.Clear()
+
+				onPage := []*Card{}
+				for _, card := range missing.Cards {
+					if card.Page == page {
+						page.Selection.Add(card)
+						onPage = append(onPage, card)
+					}
+				}
+
+				globals.Project.Camera.FocusOn(false, onPage...)
+
+			}))
+
+			row.Add("locate", NewButton("Locate...", nil, nil, false, func() {
+
+				newPath, err := zenity.SelectFile(zenity.Title("Locate "+filepath.Base(filepath.FromSlash(missing.Path))+"..."), zenity.Filename(filepath.Dir(missing.Path)))
+				if err == zenity.ErrCanceled {
+					return
+				} else if err != nil {
+					panic(err)
+				}
+
+				relinked := globals.Project.Relink(map[*MissingFile]string{missing: newPath})
+				globals.EventLog.Log("Relinked %d cards to %s.", false, relinked, newPath)
+				refreshMissingFiles()
+
+			}))
+
+			missingRows = append(missingRows, row)
+
+			missingRoot.Rows = append(missingRoot.Rows, missingRows[len(missingRows)-2:]...)
+
+		}
+
+	}
+
+	missingMenu.OnOpen = refreshMissingFiles
+
+	// Progress History Menu
+
+	progressHistory := globals.MenuSystem.Add(NewMenu("progress history", &sdl.FRect{globals.ScreenSize.X/2 - (700 / 2), 9999, 700, 400}, MenuCloseButton), false)
+	progressHistory.Draggable = true
+	progressHistory.Resizeable = true
+	progressHistory.AnchorMode = MenuAnchorBottom
+
+	root = progressHistory.Pages["root"]
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Progress History", nil, false, AlignCenter))
+
+	chart := NewProgressChart(&sdl.FRect{0, 0, 640, 256})
+	chartPages := []*Page{}
+
+	refreshChart := func(index int) {
+		if index >= 0 && index < len(chartPages) {
+			chart.Samples = globals.Project.ProgressHistory(chartPages[index])
+		}
+	}
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Page:", nil, false, AlignLeft))
+	chartPageDropdown := NewDropdown(&sdl.FRect{0, 0, 320, 32}, false, refreshChart, nil, "Root")
+	row.Add("page", chartPageDropdown)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewButtonGroup(&sdl.FRect{0, 0, 320, 32}, false, func(index int) {
+		chart.Burnup = index == 1
+	}, nil, "Burndown", "Burnup"))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("chart", chart)
+	row.ExpandElementSet.SelectAll()
+
+	root.OnOpen = func() {
+
+		chartPages = []*Page{}
+		names := []string{}
+
+		for _, page := range globals.Project.Pages {
+			if page.Valid() {
+				chartPages = append(chartPages, page)
+				names = append(names, page.Name())
+			}
+		}
+
+		chartPageDropdown.SetOptions(names...)
+		if chartPageDropdown.ChosenIndex >= len(chartPages) {
+			chartPageDropdown.ChosenIndex = 0
+		}
+
+		refreshChart(chartPageDropdown.ChosenIndex)
+
+	}
+
+	// Map palette menu
+
+	paletteMenu := globals.MenuSystem.Add(NewMenu("map palette menu", &sdl.FRect{0, 0, 300, 720}, MenuCloseButton), false)
+	paletteMenu.Center()
+	paletteMenu.Draggable = true
+	paletteMenu.Resizeable = true
+
+	root = paletteMenu.Pages["root"]
+
+	root.AddRow(AlignCenter).Add("color label", NewLabel("Colors", nil, false, AlignCenter))
+
+	addMapPaletteColorRows(root)
+
+	root.AddRow(AlignCenter).Add("edit palette", NewButton("Edit Palette...", nil, nil, false, func() {
+		globals.MenuSystem.Get("map palette editor").Open()
+	}))
+
+	root.AddRow(AlignCenter).Add("pattern label", NewLabel("Patterns", nil, false, AlignCenter))
+
+	button = NewButton("Solid", nil, &sdl.FRect{48, 128, 32, 32}, false, func() { MapPattern = MapPatternSolid })
+	row = root.AddRow(AlignCenter)
+	row.Add("pattern solid", button)
+
+	row = root.AddRow(AlignCenter)
+	button = NewButton("Crossed", nil, &sdl.FRect{80, 128, 32, 32}, false, func() { MapPattern = MapPatternCrossed })
+	row.Add("pattern crossed", button)
+
+	button = NewButton("Dotted", nil, &sdl.FRect{112, 128, 32, 32}, false, func() { MapPattern = MapPatternDotted })
+	row = root.AddRow(AlignCenter)
+	row.Add("pattern dotted", button)
+
+	button = NewButton("Checked", nil, &sdl.FRect{144, 128, 32, 32}, false, func() { MapPattern = MapPatternChecked })
+	row = root.AddRow(AlignCenter)
+	row.Add("pattern checked", button)
+
+	button = NewButton("Terrain", nil, &sdl.FRect{144, 128, 32, 32}, false, func() { MapPattern = MapPatternTerrain })
+	row = root.AddRow(AlignCenter)
+	row.Add("pattern terrain", button)
+
+	root.AddRow(AlignCenter).Add("shift label", NewLabel("Shift", nil, false, AlignCenter))
+
+	shiftNumberSpinner := NewNumberSpinner(&sdl.FRect{0, 0, 128, 32}, false, nil)
+	shiftNumberSpinner.SetLimits(1, math.MaxFloat64)
+	root.AddRow(AlignCenter).Add("shift number", shiftNumberSpinner)
+
+	left := NewButton("Left", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).MapData.Push(-int(shiftNumberSpinner.Value), 0, true)
+			}
+		}
+		globals.EventLog.Log("Map shifted by %d to the left.", false, int(shiftNumberSpinner.Value))
+
+	})
+
+	right := NewButton("Right", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).MapData.Push(int(shiftNumberSpinner.Value), 0, true)
+			}
+		}
+		globals.EventLog.Log("Map shifted by %d to the right.", false, int(shiftNumberSpinner.Value))
+
+	})
+
+	up := NewButton("Up", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).MapData.Push(0, -int(shiftNumberSpinner.Value), true)
+			}
+		}
+
+		globals.EventLog.Log("Map shifted by %d upward.", false, int(shiftNumberSpinner.Value))
+
+	})
+
+	down := NewButton("Down", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).MapData.Push(0, int(shiftNumberSpinner.Value), true)
+			}
+		}
+
+		globals.EventLog.Log("Map shifted by %d downward.", false, int(shiftNumberSpinner.Value))
+
+	})
+
+	row = root.AddRow(AlignCenter)
+	row.Add("shift up", up)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("shift left", left)
+	row.Add("spacer", NewSpacer(&sdl.FRect{0, 0, 32, 32}))
+	row.Add("shift right", right)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("shift down", down)
+
+	root.AddRow(AlignCenter).Add("rotate label", NewLabel("Rotate", nil, false, AlignCenter))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("rotate left", NewButton("Left", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).Rotate(false)
+			}
+		}
+
+	}))
+
+	row.Add("spacer", NewSpacer(&sdl.FRect{0, 0, 32, 32}))
+
+	row.Add("rotate right", NewButton("Right", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).Rotate(true)
+			}
+		}
+
+	}))
+
+	root.AddRow(AlignCenter).Add("flip label", NewLabel("Flip", nil, false, AlignCenter))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("flip horizontally", NewButton("Horizontally", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).Flip(false)
+			}
+		}
+
+	}))
+
+	row = root.AddRow(AlignCenter)
+
+	row.Add("flip vertically", NewButton("Vertically", nil, nil, false, func() {
+
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).Flip(true)
+			}
+		}
+
+	}))
+
+	root.AddRow(AlignCenter).Add("layers", NewButton("Levels & Layers...", nil, nil, false, func() {
+		globals.MenuSystem.Get("map layers menu").Open()
+	}))
+
+	row = root.AddRow(AlignCenter)
+
+	row.Add("export map", NewButton("Export...", nil, nil, false, func() {
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).ExportAs()
+				break
+			}
+		}
+	}))
+
+	row.Add("import map", NewButton("Import...", nil, nil, false, func() {
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).ImportAs()
+				break
+			}
+		}
+	}))
+
+	root.AddRow(AlignCenter).Add("merge maps", NewButton("Merge Selected Maps", nil, nil, false, func() {
+
+		maps := []*MapContents{}
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				maps = append(maps, card.Contents.(*MapContents))
+			}
+		}
+
+		if err := MergeMaps(maps); err != nil {
+			globals.EventLog.Log("Can't merge maps: %s.", true, err.Error())
+			return
+		}
+
+		globals.EventLog.Log("Merged %d Map cards into one.", false, len(maps))
+
+	}))
+
+	// Map palette editor
+
+	paletteEditor := globals.MenuSystem.Add(NewMenu("map palette editor", &sdl.FRect{0, 0, 320, 560}, MenuCloseButton), false)
+	paletteEditor.Center()
+	paletteEditor.Draggable = true
+	paletteEditor.Resizeable = true
+
+	paletteEditorRoot := paletteEditor.Pages["root"]
+
+	paletteEditIndex := 0
+	paletteEditorState := ""
+
+	var paletteHexText *Label
+
+	paletteWheel := NewColorWheel()
+	paletteWheel.OnColorChange = func() {
+		paletteHexText.SetText([]rune("#" + paletteWheel.SampledColor.ToHexString()[:6]))
+	}
+
+	paletteHexText = NewLabel("#FFFFFF", &sdl.FRect{0, 0, 192, 32}, false, AlignCenter)
+	paletteHexText.Editable = true
+	paletteHexText.MaxLength = 7
+	paletteHexText.RegexString = RegexHex
+	paletteHexText.OnClickOut = func() {
+		if color, ok := parseHexColor(paletteHexText.TextAsString()); ok {
+			h, s, v := color.HSV()
+			paletteWheel.SetHSV(h, s, v)
+			paletteHexText.SetTextRaw([]rune("#" + color.ToHexString()[:6]))
+		}
+	}
+
+	selectPaletteEntry := func(index int) {
+		paletteEditIndex = index
+		color := MapPaletteColors[index]
+		h, s, v := color.HSV()
+		paletteWheel.SetHSV(h, s, v)
+		paletteHexText.SetTextRaw([]rune("#" + color.ToHexString()[:6]))
+	}
+
+	paletteEditorSignature := func() string {
+		return serializeMapPalette(MapPaletteColors) + ":" + strconv.Itoa(paletteEditIndex)
+	}
+
+	refreshPaletteEditor := func() {
+
+		paletteEditorRoot.Clear()
+
+		if paletteEditIndex >= len(MapPaletteColors) {
+			paletteEditIndex = len(MapPaletteColors) - 1
+		}
+
+		paletteEditorState = paletteEditorSignature()
+
+		paletteEditorRoot.AddRow(AlignCenter).Add("", NewLabel("Project Map Palette", nil, false, AlignCenter))
+
+		row := paletteEditorRoot.AddRow(AlignCenter)
+
+		for i, color := range MapPaletteColors {
+
+			if i%5 == 0 && i > 0 {
+				row = paletteEditorRoot.AddRow(AlignCenter)
+			}
+
+			index := i
+			swatch := NewIconButton(0, 0, &sdl.FRect{48, 128, 32, 32}, globals.GUITexture, false, func() { selectPaletteEntry(index) })
+			swatch.BGIconSrc = &sdl.FRect{144, 96, 32, 32}
+			swatch.Tint = color
+			if i == paletteEditIndex {
+				swatch.IconSrc = &sdl.FRect{48, 160, 32, 32}
+			}
+			row.Add("entry"+strconv.Itoa(i), swatch)
+
+		}
+
+		paletteEditorRoot.AddRow(AlignCenter).Add("color wheel", paletteWheel)
+		paletteEditorRoot.AddRow(AlignCenter).Add("hex text", paletteHexText)
+
+		row = paletteEditorRoot.AddRow(AlignCenter)
+		row.Add("set color", NewButton("Set Color", nil, nil, false, func() {
+			colors := append([]Color{}, MapPaletteColors...)
+			colors[paletteEditIndex] = paletteWheel.SampledColor.Clone()
+			globals.Project.SetMapPalette(colors)
+		}))
+		row.Add("add color", NewButton("Add Color", nil, nil, false, func() {
+			if len(MapPaletteColors) >= MapPaletteMaxColors {
+				globals.EventLog.Log("Map palettes can't have more than %d colors.", true, MapPaletteMaxColors)
+				return
+			}
+			globals.Project.SetMapPalette(append(append([]Color{}, MapPaletteColors...), paletteWheel.SampledColor.Clone()))
+			paletteEditIndex = len(MapPaletteColors) - 1
+		}))
+
+		row = paletteEditorRoot.AddRow(AlignCenter)
+		row.Add("remove color", NewButton("Remove Color", nil, nil, false, func() {
+			if !globals.Project.RemoveMapPaletteColor(paletteEditIndex) {
+				globals.EventLog.Log("Can't remove a palette's only color.", true)
+				return
+			}
+			globals.EventLog.Log("Palette color removed; Map cards using it now use the nearest remaining color.", false)
+		}))
+
+		row = paletteEditorRoot.AddRow(AlignCenter)
+		row.Add("import palette", NewButton("Import...", nil, nil, false, func() { globals.Project.ImportMapPalette() }))
+		row.Add("reset palette", NewButton("Reset to Default", nil, nil, false, func() {
+			globals.Project.SetMapPalette(DefaultMapPaletteColors)
+			globals.EventLog.Log("Map palette reset to the default.", false)
+		}))
+
+	}
+
+	paletteEditor.OnOpen = func() {
+		selectPaletteEntry(min(max(MapDrawingColor-1, 0), len(MapPaletteColors)-1))
+		refreshPaletteEditor()
+	}
+
+	paletteEditorRoot.OnUpdate = func() {
+		if paletteEditorSignature() != paletteEditorState {
+			refreshPaletteEditor()
+		}
+	}
+
+	// Map layers menu
+
+	mapLayersMenu := globals.MenuSystem.Add(NewMenu("map layers menu", &sdl.FRect{0, 0, 560, 420}, MenuCloseButton), false)
+	mapLayersMenu.Center()
+	mapLayersMenu.Draggable = true
+	mapLayersMenu.Resizeable = true
+
+	mapLayersRoot := mapLayersMenu.Pages["root"]
+
+	selectedMap := func() *MapContents {
+		for _, card := range globals.Project.CurrentPage.Selection.AsSlice() {
+			if card.ContentType == ContentTypeMap {
+				return card.Contents.(*MapContents)
+			}
+		}
+		return nil
+	}
+
+	// mapLayersSignature describes the selected map's levels and layers, so the menu can tell when it needs to be rebuilt
+	mapLayersSignature := func(mc *MapContents) string {
+
+		if mc == nil {
+			return ""
+		}
+
+		// Undoing recreates the levels, so the level's address is included to catch that
+		signature := fmt.Sprintf("%p:%p:%d:%d:%s", mc, mc.MapData.Level(), mc.MapData.CurrentLevel, len(mc.MapData.Levels), mc.MapData.Level().Name)
+		for _, layer := range mc.MapData.Level().Layers {
+			signature += fmt.Sprintf("|%s:%t:%.2f", layer.Name, layer.Visible, layer.Opacity)
+		}
+
+		return signature + ":" + strconv.Itoa(mc.MapData.Level().ActiveLayer)
+
+	}
+
+	promptName := func(title, current string) (string, bool) {
+		name, err := zenity.Entry("Name:", zenity.Title(title), zenity.EntryText(current))
+		if err != nil || strings.TrimSpace(name) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(name), true
+	}
+
+	mapLayerOpacities := []float32{1, 0.75, 0.5, 0.25}
+	mapLayersState := ""
+
+	refreshMapLayers := func() {
+
+		mapLayersRoot.Clear()
+
+		mc := selectedMap()
+		mapLayersState = mapLayersSignature(mc)
+
+		mapLayersRoot.AddRow(AlignCenter).Add("", NewLabel("Levels & Layers", nil, false, AlignCenter))
+
+		if mc == nil {
+			mapLayersRoot.AddRow(AlignCenter).Add("", NewLabel("Select a Map card to edit its levels and layers.", nil, false, AlignCenter))
+			return
+		}
+
+		mapData := mc.MapData
+
+		row := mapLayersRoot.AddRow(AlignCenter)
+		row.Add("previous level", NewButton("<", nil, nil, false, func() {
+			if mapData.CurrentLevel > 0 {
+				mapData.SetLevel(mapData.CurrentLevel - 1)
+			}
+		}))
+		row.Add("level", NewLabel(fmt.Sprintf("%s (%d / %d)", mapData.Level().Name, mapData.CurrentLevel+1, len(mapData.Levels)), nil, false, AlignCenter))
+		row.Add("next level", NewButton(">", nil, nil, false, func() {
+			if mapData.CurrentLevel < len(mapData.Levels)-1 {
+				mapData.SetLevel(mapData.CurrentLevel + 1)
+			}
+		}))
+
+		row = mapLayersRoot.AddRow(AlignCenter)
+		row.Add("add level", NewButton("Add Level", nil, nil, false, func() { mapData.AddLevel() }))
+		row.Add("rename level", NewButton("Rename", nil, nil, false, func() {
+			if name, ok := promptName("Rename Level", mapData.Level().Name); ok {
+				mapData.Level().Name = name
+				mapData.Commit()
+			}
+		}))
+		row.Add("remove level", NewButton("Remove", nil, nil, false, func() {
+			if !mapData.RemoveLevel() {
+				globals.EventLog.Log("Can't remove a map's only level.", true)
+			}
+		}))
+
+		mapLayersRoot.AddRow(AlignCenter).Add("", NewLabel("Layers (Top to Bottom)", nil, false, AlignCenter))
+
+		layers := mapData.Level().Layers
+
+		for i := len(layers) - 1; i >= 0; i-- {
+
+			index := i
+			layer := layers[i]
+
+			row = mapLayersRoot.AddRow(AlignLeft)
+			row.AlternateBGColor = true
+
+			visible := NewCheckbox(0, 0, false, nil)
+			visible.Checked = layer.Visible
+			visible.OnChange = func() {
+				layer.Visible = visible.Checked
+				mapData.Commit()
+			}
+			row.Add("visible", visible)
+
+			selectButton := NewButton(layer.Name, &sdl.FRect{0, 0, 192, 32}, nil, false, func() { mapData.SetActiveLayer(index) })
+			selectButton.FadeOnInactive = index != mapData.Level().ActiveLayer
+			row.Add("layer", selectButton)
+
+			opacity := NewDropdown(&sdl.FRect{0, 0, 96, 32}, false, func(chosen int) {
+				layer.Opacity = mapLayerOpacities[chosen]
+				mapData.Commit()
+			}, nil, "100%", "75%", "50%", "25%")
+
+			for o, value := range mapLayerOpacities {
+				if layer.Opacity >= value-0.01 {
+					opacity.ChosenIndex = o
+					break
+				}
+			}
+
+			row.Add("opacity", opacity)
+
+			row.Add("up", NewButton("Up", nil, nil, false, func() { mapData.MoveLayer(index, true) }))
+			row.Add("down", NewButton("Down", nil, nil, false, func() { mapData.MoveLayer(index, false) }))
+
+		}
+
+		row = mapLayersRoot.AddRow(AlignCenter)
+		row.Add("add layer", NewButton("Add Layer", nil, nil, false, func() { mapData.AddLayer() }))
+		row.Add("rename layer", NewButton("Rename", nil, nil, false, func() {
+			if name, ok := promptName("Rename Layer", mapData.Layer().Name); ok {
+				mapData.Layer().Name = name
+				mapData.Commit()
+			}
+		}))
+		row.Add("remove layer", NewButton("Remove", nil, nil, false, func() {
+			if !mapData.RemoveLayer() {
+				globals.EventLog.Log("Can't remove a level's only layer.", true)
+			}
+		}))
+
+	}
+
+	mapLayersMenu.OnOpen = refreshMapLayers
+
+	mapLayersRoot.OnUpdate = func() {
+		if mapLayersSignature(selectedMap()) != mapLayersState {
+			refreshMapLayers()
+		}
+	}
+
+	// Table menu
+
+	tableMenu := globals.MenuSystem.Add(NewMenu("table settings menu", &sdl.FRect{999999, 0, 500, 900}, MenuCloseButton), false)
+	tableMenu.Resizeable = true
+	tableMenu.Draggable = true
+	tableMenu.AnchorMode = MenuAnchorTopRight
+
+	root = tableMenu.Pages["root"]
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Table Settings", nil, false, AlignCenter))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("label", NewLabel("Visualize As:", nil, false, AlignCenter))
+	row = root.AddRow(AlignCenter)
+	row.Add("table mode", NewButtonGroup(&sdl.FRect{0, 0, 32, 32}, false, func(index int) {
+
+		mode := tableMenu.Pages["root"].FindElement("table mode", false).(*ButtonGroup)
+		for _, card := range globals.Project.CurrentPage.Cards {
+			if card.IsSelected() && card.ContentType == ContentTypeTable {
+				card.Contents.(*TableContents).TableData.ValueDisplayMode = mode.ChosenIndex
+				card.Contents.(*TableContents).TableData.Changed = true
+			}
+		}
+
+	}, nil, "Checkmarks", "Letters", "Numbers"))
+	row.ExpandElementSet.SelectAll()
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+
+	selectedTable := func() *TableData {
+		if table := globals.Project.CurrentPage.SelectedTable(); table != nil {
+			return table.TableData
+		}
+		return nil
+	}
+
+	// The cell being edited in the formula bar; this only changes when it isn't being edited, so clicking on another
+	// cell still saves the text to the cell it was typed for.
+	var formulaCell *TableDataContents
+
+	cellName := NewLabel("Click on a cell to edit it.", &sdl.FRect{0, 0, 400, 32}, false, AlignCenter)
+
+	cellType := NewButtonGroup(&sdl.FRect{0, 0, 32, 32}, false, func(index int) {
+		if td := selectedTable(); td != nil && td.ActiveCell != nil {
+			td.SetCellType(index, td.ActiveCell)
+		}
+	}, nil, tableCellTypeNames...)
+
+	cellFormula := NewLabel("", &sdl.FRect{0, 0, 400, 32}, false, AlignLeft)
+	cellFormula.Editable = true
+	cellFormula.RegexString = RegexNoNewlines
+	cellFormula.OnClickOut = func() {
+		if formulaCell != nil {
+			formulaCell.TableData.SetCellText(formulaCell, cellFormula.TextAsString())
+		}
+	}
+
+	cellResult := NewLabel("", &sdl.FRect{0, 0, 400, 32}, false, AlignCenter)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("cell name", cellName)
+	row = root.AddRow(AlignCenter)
+	row.Add("cell type", cellType)
+	row.ExpandElementSet.SelectAll()
+	row = root.AddRow(AlignCenter)
+	row.Add("cell formula", cellFormula)
+	row = root.AddRow(AlignCenter)
+	row.Add("cell result", cellResult)
+
+	applyCellType := func(column bool) {
+
+		td := selectedTable()
+		if td == nil {
+			return
+		}
+
+		x, y, ok := td.CellPosition(td.ActiveCell)
+		if !ok {
+			globals.EventLog.Log("Click on a cell to choose the type to use first.", true)
+			return
+		}
+
+		cells := []*TableDataContents{}
+
+		if column {
+			for i := 0; i < td.Height; i++ {
+				cells = append(cells, td.Data[i][x])
+			}
+		} else {
+			cells = append(cells, td.Data[y][:td.Width]...)
+		}
+
+		td.SetCellType(td.ActiveCell.Type, cells...)
+
+	}
+
+	row = root.AddRow(AlignCenter)
+	row.Add("type row", NewButton("Use Type for Row", nil, nil, false, func() { applyCellType(false) }))
+	row.Add("type column", NewButton("Use Type for Column", nil, nil, false, func() { applyCellType(true) }))
+
+	setFilter := func(name string, value string) {
+		if table := globals.Project.CurrentPage.SelectedTable(); table != nil {
+			table.Card.Properties.Get(name).Set(value)
+		}
+	}
+
+	rowFilter := NewDropdown(&sdl.FRect{0, 0, 256, 32}, false, func(index int) {
+		setFilter(TablePropertyRowFilter, TableFilterOptions[index])
+	}, nil, TableFilterOptions...)
+
+	columnFilter := NewDropdown(&sdl.FRect{0, 0, 256, 32}, false, func(index int) {
+		setFilter(TablePropertyColumnFilter, TableFilterOptions[index])
+	}, nil, TableFilterOptions...)
+
+	filterText := NewLabel("", &sdl.FRect{0, 0, 400, 32}, false, AlignLeft)
+	filterText.Editable = true
+	filterText.RegexString = RegexNoNewlines
+	filterText.OnClickOut = func() {
+		setFilter(TablePropertyFilterText, filterText.TextAsString())
+	}
+
+	root.OnUpdate = func() {
+
+		td := selectedTable()
+
+		if td != nil {
+
+			for _, filter := range []*Dropdown{rowFilter, columnFilter} {
+
+				name := TablePropertyRowFilter
+				if filter == columnFilter {
+					name = TablePropertyColumnFilter
+				}
+
+				filter.ChosenIndex = 0
+				for i, option := range TableFilterOptions {
+					if option == td.filterSetting(name) {
+						filter.ChosenIndex = i
+					}
+				}
+
+			}
+
+			if text := td.filterSetting(TablePropertyFilterText); !filterText.Editing && filterText.TextAsString() != text {
+				filterText.SetTextRaw([]rune(text))
+			}
+
+		}
+
+		var cell *TableDataContents
+		x, y := 0, 0
+
+		if td != nil {
+			var ok bool
+			if x, y, ok = td.CellPosition(td.ActiveCell); ok {
+				cell = td.ActiveCell
+			}
+		}
+
+		if !cellFormula.Editing {
+
+			formulaCell = cell
+
+			text := ""
+			if cell != nil {
+				text = cell.Text
+			}
+
+			if cellFormula.TextAsString() != text {
+				cellFormula.SetTextRaw([]rune(text))
+			}
+
+			if cell != nil && td.FocusFormulaBar {
+				cellFormula.BeginEditing()
+			}
+
+		}
+
+		if td != nil {
+			td.FocusFormulaBar = false
+		}
+
+		name := "Click on a cell to edit it."
+		result := ""
+
+		if cell != nil {
+
+			name = "Cell " + TableCellName(x, y)
+			cellType.ChosenIndex = cell.Type
+
+			if cell.IsFormula() {
+				if r := newTableEvaluator(td).Result(x, y); r.Err != nil {
+					result = "Error: " + r.Err.Error()
+				} else {
+					result = "Result: " + r.Text
+				}
+			}
+
+		}
+
+		if cellName.TextAsString() != name {
+			cellName.SetText([]rune(name))
+		}
+
+		if cellResult.TextAsString() != result {
+			cellResult.SetText([]rune(result))
+		}
+
+	}
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Table Controls", nil, false, AlignCenter))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("swap", NewButton("Swap Rows and Columns", nil, nil, false, func() {
+		for c := range globals.Project.CurrentPage.Selection.Cards {
+			if c.ContentType == ContentTypeTable {
+				c.Contents.(*TableContents).TableData.SwapColumnsAndRows()
+			}
+		}
+	}))
 
 	row = root.AddRow(AlignCenter)
 	row.Add("swap", NewButton("Clear", nil, nil, false, func() {
@@ -3782,9 +4942,359 @@ horizontally.`))
 		}
 	}))
 
+	row = root.AddRow(AlignCenter)
+	row.Add("import", NewButton("Import...", nil, nil, false, func() {
+		if table := globals.Project.CurrentPage.SelectedTable(); table != nil {
+			table.ImportFrom()
+		}
+	}))
+	row.Add("export", NewButton("Export...", nil, nil, false, func() {
+		if table := globals.Project.CurrentPage.SelectedTable(); table != nil {
+			table.ExportAs()
+		}
+	}))
+	row.Add("copy", NewButton("Copy as TSV", nil, nil, false, func() {
+		if table := globals.Project.CurrentPage.SelectedTable(); table != nil {
+			table.TableData.CopyAsTSV()
+		}
+	}))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Sorting", nil, false, AlignCenter))
+
+	sortOrder := NewButtonGroup(&sdl.FRect{0, 0, 32, 32}, false, nil, nil, "Ascending", "Descending")
+
+	row = root.AddRow(AlignCenter)
+	row.Add("sort order", sortOrder)
+	row.ExpandElementSet.SelectAll()
+
+	row = root.AddRow(AlignCenter)
+	row.Add("sort column", NewButton("Sort Rows by Cell's Column", nil, nil, false, func() {
+
+		td := selectedTable()
+		if td == nil {
+			return
+		}
+
+		x, _, ok := td.CellPosition(td.ActiveCell)
+		if !ok {
+			globals.EventLog.Log("Click on a cell in the column to sort by first.", true)
+			return
+		}
+
+		td.SortRows(x, sortOrder.ChosenIndex == 1)
+
+	}))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("sort completion", NewButton("Sort Rows by Completion", nil, nil, false, func() {
+		if td := selectedTable(); td != nil {
+			td.SortRows(-1, sortOrder.ChosenIndex == 1)
+		}
+	}))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Filters", nil, false, AlignCenter))
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Only Show Rows Containing:", nil, false, AlignLeft))
+	row = root.AddRow(AlignCenter)
+	row.Add("filter text", filterText)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Rows:", nil, false, AlignLeft))
+	row.Add("row filter", rowFilter)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("", NewLabel("Columns:", nil, false, AlignLeft))
+	row.Add("column filter", columnFilter)
+
+	// Sound card menu
+
+	soundMenu := globals.MenuSystem.Add(NewMenu("sound card settings", &sdl.FRect{999999, 0, 560, 820}, MenuCloseButton), false)
+	soundMenu.Resizeable = true
+	soundMenu.Draggable = true
+	soundMenu.AnchorMode = MenuAnchorTopRight
+
+	soundRoot := soundMenu.Pages["root"]
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewLabel("Sound Card Settings", nil, false, AlignCenter))
+
+	var activeSound *SoundContents
+
+	nowPlaying := NewLabel("No sound loaded", nil, false, AlignCenter)
+	nowPlaying.SetMaxSize(512, 32)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("now playing", nowPlaying)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("previous", NewButton("Previous Track", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SkipTrack(-1)
+		}
+	}))
+	row.Add("next", NewButton("Next Track", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SkipTrack(1)
+		}
+	}))
+
+	shuffleCheckbox := NewCheckbox(0, 0, false, nil)
+	repeatDropdown := NewDropdown(&sdl.FRect{0, 0, 256, 32}, false, nil, nil, SoundRepeatModes...)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewLabel("Shuffle:", nil, false, AlignCenter))
+	row.Add("shuffle", shuffleCheckbox)
+	row.Add("repeat", repeatDropdown)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewLabel("Playlist", nil, false, AlignCenter))
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("add", NewButton("Add Files...", nil, nil, false, func() {
+
+		if activeSound == nil {
+			return
+		}
+
+		paths, err := zenity.SelectFileMultiple(zenity.Title("Add audio files to playlist..."), zenity.FileFilters{{Name: "Audio files", Patterns: []string{"*.wav", "*.ogg", "*.oga", "*.mp3", "*.flac"}}})
+
+		if err == zenity.ErrCanceled {
+			return
+		} else if err != nil {
+			globals.EventLog.Log(err.Error(), true)
+			return
+		}
+
+		activeSound.AddToPlaylist(paths...)
+
+	}))
+	row.Add("clear", NewButton("Clear Playlist", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SetPlaylist(nil)
+		}
+	}))
+
+	playlistHeaderRows := append([]*ContainerRow{}, soundRoot.Rows...)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewLabel("Trim", nil, false, AlignCenter))
+
+	trimLabel := NewLabel("Playing the whole track", nil, false, AlignCenter)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("trim", trimLabel)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("trim start", NewButton("Trim Start", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SetTrimPoint(true)
+		}
+	}))
+	row.Add("trim end", NewButton("Trim End", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SetTrimPoint(false)
+		}
+	}))
+	row.Add("reset trim", NewButton("Reset Trim", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.ResetTrim()
+		}
+	}))
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewLabel("A-B Loop", nil, false, AlignCenter))
+
+	loopLabel := NewLabel("No loop set", nil, false, AlignCenter)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("loop", loopLabel)
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("set a", NewButton("Set A", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SetLoopPoint(true)
+		}
+	}))
+	row.Add("set b", NewButton("Set B", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.SetLoopPoint(false)
+		}
+	}))
+	row.Add("clear loop", NewButton("Clear Loop", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.ClearLoop()
+		}
+	}))
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewSpacer(nil))
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("", NewLabel("Markers", nil, false, AlignCenter))
+
+	row = soundRoot.AddRow(AlignCenter)
+	row.Add("add marker", NewButton("Add Marker at Current Time", nil, nil, false, func() {
+		if activeSound != nil {
+			activeSound.AddMarker("")
+		}
+	}))
+
+	markerHeaderRows := append([]*ContainerRow{}, soundRoot.Rows[len(playlistHeaderRows):]...)
+
+	soundEntryRows := []*ContainerRow{}
+	soundMenuState := ""
+
+	refreshSoundMenu := func() {
+
+		for _, row := range soundEntryRows {
+			row.Destroy()
+		}
+
+		soundEntryRows = []*ContainerRow{}
+
+		playlistRows := []*ContainerRow{}
+		markerRows := []*ContainerRow{}
+
+		if activeSound != nil {
+
+			current := activeSound.TrackIndex()
+
+			for i, path := range activeSound.Playlist() {
+
+				index := i
+
+				name := []rune(filepath.Base(path))
+				if len(name) > 32 {
+					name = append(name[:29], []rune("...")...)
+				}
+
+				if index == current {
+					name = append([]rune("> "), name...)
+				}
+
+				row := NewContainerRow(soundRoot, AlignLeft)
+				row.AlternateBGColor = true
+				row.Add("track", NewButton(string(name), nil, nil, false, func() { activeSound.PlayTrack(index) }))
+				row.Add("up", NewButton("Up", nil, nil, false, func() { activeSound.MoveInPlaylist(index, -1) }))
+				row.Add("down", NewButton("Down", nil, nil, false, func() { activeSound.MoveInPlaylist(index, 1) }))
+				row.Add("remove", NewButton("Remove", nil, nil, false, func() { activeSound.RemoveFromPlaylist(index) }))
+				playlistRows = append(playlistRows, row)
+
+			}
+
+			for _, m := range activeSound.TrackMarkers() {
+
+				marker := m
+
+				nameLabel := NewLabel(marker.Name, &sdl.FRect{0, 0, 256, 32}, false, AlignLeft)
+				nameLabel.Editable = true
+				nameLabel.RegexString = RegexNoNewlines
+				nameLabel.OnClickOut = func() {
+					if name := nameLabel.TextAsString(); name != marker.Name {
+						renamed := marker
+						renamed.Name = name
+						activeSound.UpdateMarker(marker, &renamed)
+					}
+				}
+
+				row := NewContainerRow(soundRoot, AlignLeft)
+				row.AlternateBGColor = true
+				row.Add("jump", NewButton(formatPlaybackTime(secondsToDuration(marker.Time)), nil, nil, false, func() { activeSound.JumpTo(marker.Time) }))
+				row.Add("name", nameLabel)
+				row.Add("remove", NewButton("Remove", nil, nil, false, func() { activeSound.UpdateMarker(marker, nil) }))
+				markerRows = append(markerRows, row)
+
+			}
+
+		}
+
+		soundEntryRows = append(append(soundEntryRows, playlistRows...), markerRows...)
+
+		soundRoot.Rows = append([]*ContainerRow{}, playlistHeaderRows...)
+		soundRoot.Rows = append(soundRoot.Rows, playlistRows...)
+		soundRoot.Rows = append(soundRoot.Rows, markerHeaderRows...)
+		soundRoot.Rows = append(soundRoot.Rows, markerRows...)
+
+	}
+
+	soundRoot.OnUpdate = func() {
+
+		if activeSound != nil && (!activeSound.Card.Valid || !activeSound.Card.selected || activeSound.Card.ContentType != ContentTypeSound) {
+			activeSound = nil
+		}
+
+		if activeSound == nil {
+			for card := range globals.Project.CurrentPage.Selection.Cards {
+				if card.Valid && card.selected && card.ContentType == ContentTypeSound {
+					activeSound = card.Contents.(*SoundContents)
+					shuffleCheckbox.Property = card.Properties.Get("shuffle")
+					repeatDropdown.UpdateProperty(card.Properties.Get("repeat"))
+					break
+				}
+			}
+		}
+
+		state := ""
+		nowPlayingText := "No sound loaded"
+		loopText := "No loop set"
+		trimText := "Playing the whole track"
+
+		if activeSound != nil {
+
+			props := activeSound.Card.Properties
+			state = fmt.Sprintf("%p", activeSound) + props.Get("filepath").AsString()
+			for _, name := range []string{"playlist", "markers"} {
+				if prop := props.GetIfExists(name); prop != nil && prop.IsString() {
+					state += prop.AsString()
+				}
+			}
+
+			nowPlayingText = activeSound.SoundNameLabel.TextAsString()
+
+			if loop, ok := activeSound.Loop(); ok {
+				loopText = "Looping " + formatPlaybackTime(secondsToDuration(loop.Start)) + " - " + formatPlaybackTime(secondsToDuration(loop.End))
+			}
+
+			if trim, ok := activeSound.Trim(); ok {
+				trimText = "Playing " + formatPlaybackTime(secondsToDuration(trim.Start)) + " - " + formatPlaybackTime(secondsToDuration(trim.End))
+			}
+
+		}
+
+		// Marker names are edited in place, so the rows aren't recreated out from under them
+		if state != soundMenuState && globals.State != StateTextEditing {
+			soundMenuState = state
+			refreshSoundMenu()
+		}
+
+		if nowPlaying.TextAsString() != nowPlayingText {
+			nowPlaying.SetText([]rune(nowPlayingText))
+		}
+
+		if loopLabel.TextAsString() != loopText {
+			loopLabel.SetText([]rune(loopText))
+		}
+
+		if trimLabel.TextAsString() != trimText {
+			trimLabel.SetText([]rune(trimText))
+		}
+
+	}
+
 	// Web menu
 
-	webMenu := globals.MenuSystem.Add(NewMenu("web card settings", &sdl.FRect{99999, 0, 650, 460}, MenuCloseButton), false)
+	webMenu := globals.MenuSystem.Add(NewMenu("web card settings", &sdl.FRect{99999, 0, 650, 700}, MenuCloseButton), false)
 	webMenu.Resizeable = true
 	webMenu.Draggable = true
 	webMenu.AnchorMode = MenuAnchorTopRight
@@ -3807,8 +5317,8 @@ horizontally.`))
 			}
 			if autoResize.Checked {
 				originalCenter := activeCard.Center()
-				activeCard.Rect.W = float32(wc.BrowserTab.BufferWidth)
-				activeCard.Rect.H = float32(wc.BrowserTab.BufferHeight)
+				activeCard.Rect.W = float32(wc.Width())
+				activeCard.Rect.H = float32(wc.Height())
 				activeCard.Rect.X = originalCenter.X - (activeCard.Rect.W / 2)
 				activeCard.Rect.Y = originalCenter.Y - (activeCard.Rect.H / 2)
 				activeCard.LockPosition()
@@ -3831,8 +5341,8 @@ horizontally.`))
 			}
 			if autoResize.Checked {
 				originalCenter := activeCard.Center()
-				activeCard.Rect.W = float32(wc.BrowserTab.BufferWidth)
-				activeCard.Rect.H = float32(wc.BrowserTab.BufferHeight)
+				activeCard.Rect.W = float32(wc.Width())
+				activeCard.Rect.H = float32(wc.Height())
 				activeCard.Rect.X = originalCenter.X - (activeCard.Rect.W / 2)
 				activeCard.Rect.Y = originalCenter.Y - (activeCard.Rect.H / 2)
 				activeCard.LockPosition()
@@ -3870,6 +5380,79 @@ horizontally.`))
 	row.Add("fps", updateOnlyWhenDropdown)
 	row.ExpandElementSet.SelectAll()
 
+	zoomScrollbar := NewScrollbar(&sdl.FRect{0, 0, 64, 32}, 0.25, 2, false, nil)
+	zoomScrollbar.DisplayValue = true
+
+	setZoomScrollbar := func(zoom float64) {
+		zoomScrollbar.TargetValue = (float32(zoom) - zoomScrollbar.ValueMin) / (zoomScrollbar.ValueMax - zoomScrollbar.ValueMin)
+		zoomScrollbar.Value = zoomScrollbar.TargetValue
+	}
+
+	zoomScrollbar.OnRelease = func() {
+		if activeCard != nil {
+			wc := activeCard.Contents.(*InternetContents)
+			wc.Card.Properties.Get("zoom").Set(float64(zoomScrollbar.ValueMin + (zoomScrollbar.ValueMax-zoomScrollbar.ValueMin)*zoomScrollbar.TargetValue))
+			if wc.BrowserTab != nil {
+				wc.BrowserTab.UpdateZoom()
+			}
+		}
+	}
+
+	row = root.AddRow(AlignCenter)
+	row.Add("label", NewLabel("Zoom:", nil, false, AlignCenter))
+	row.Add("zoom", zoomScrollbar)
+	row.Add("global zoom", NewButton("Use Global", nil, nil, false, func() {
+		if activeCard != nil {
+			wc := activeCard.Contents.(*InternetContents)
+			wc.Card.Properties.Get("zoom").Set(0.0)
+			setZoomScrollbar(wc.ZoomLevel())
+			if wc.BrowserTab != nil {
+				wc.BrowserTab.UpdateZoom()
+			}
+		}
+	}))
+	row.ExpandElementSet.SelectAll()
+
+	profileDropdown := NewDropdown(&sdl.FRect{0, 0, 32, 32}, false, func(index int) {
+		if activeCard != nil {
+			activeCard.Contents.(*InternetContents).RestartBrowserTab()
+		}
+	}, nil, InternetCardProfileGlobal, InternetCardProfileProject, InternetCardProfileCard)
+
+	row = root.AddRow(AlignCenter)
+	row.Add("label", NewLabel("Browser Profile:", nil, false, AlignCenter))
+	row.Add("profile", profileDropdown)
+	row.ExpandElementSet.SelectAll()
+
+	watchCheckbox := NewCheckbox(0, 0, false, nil)
+	watchNotifyCheckbox := NewCheckbox(0, 0, false, nil)
+	watchIntervalDropdown := NewDropdown(&sdl.FRect{0, 0, 32, 32}, false, nil, nil, InternetCardWatch30Seconds, InternetCardWatch1Minute, InternetCardWatch5Minutes, InternetCardWatch15Minutes, InternetCardWatch1Hour)
+
+	watchSelectorLabel := NewLabel("", nil, false, AlignLeft)
+	watchSelectorLabel.Editable = true
+	watchSelectorLabel.RegexString = RegexNoNewlines
+
+	row = root.AddRow(AlignCenter)
+	row.Add("hint", NewTooltip(`Watch For Changes:
+When enabled, the page is checked for changes periodically;
+if its visible text (or the text of the element matching
+the CSS selector, if one is given) changes, the card flashes
+and the change is logged.`))
+	row.Add("label", NewLabel("Watch For Changes:", nil, false, AlignCenter))
+	row.Add("watch", watchCheckbox)
+	row.Add("interval", watchIntervalDropdown)
+	row.ExpandElementSet.SelectAll()
+
+	row = root.AddRow(AlignCenter)
+	row.Add("label", NewLabel("Watched CSS Selector:", nil, false, AlignCenter))
+	row.Add("selector", watchSelectorLabel)
+	row.ExpandElementSet.SelectAll()
+
+	row = root.AddRow(AlignCenter)
+	row.Add("label", NewLabel("Desktop Notification On Change:", nil, false, AlignCenter))
+	row.Add("notify", watchNotifyCheckbox)
+	row.ExpandElementSet.SelectAll()
+
 	row = root.AddRow(AlignCenter)
 	row.Add("url", NewLabel("URL:", nil, false, AlignCenter))
 	row = root.AddRow(AlignCenter)
@@ -3884,6 +5467,14 @@ horizontally.`))
 		}
 	}))
 
+	freezeButton := NewButton("Freeze Page", nil, nil, false, func() {
+		if activeCard != nil {
+			activeCard.Contents.(*InternetContents).ToggleFreeze()
+		}
+	})
+	row.Add("freeze", freezeButton)
+	row.ExpandElementSet.SelectAll()
+
 	row = root.AddRow(AlignCenter)
 	row.Add("spacer", NewSpacer(nil))
 
@@ -3910,6 +5501,7 @@ horizontally.`))
 				}
 
 				w := card.Contents.(*InternetContents)
+				w.syncFrozenState()
 
 				if w.BrowserTab != nil {
 					w.BrowserTab.UpdateBufferSize(w.Width(), w.Height())
@@ -3930,7 +5522,9 @@ horizontally.`))
 			if card.Valid && card.selected && card.ContentType == ContentTypeInternet && activeCard != card {
 
 				card.Properties.Get("url").Set(activeCard.Properties.Get("url").AsString())
-				card.Contents.(*InternetContents).BrowserTab.Navigate(card.Properties.Get("url").AsString())
+				if browserTab := card.Contents.(*InternetContents).BrowserTab; browserTab != nil {
+					browserTab.Navigate(card.Properties.Get("url").AsString())
+				}
 
 			}
 
@@ -3951,6 +5545,21 @@ horizontally.`))
 
 	root.OnUpdate = func() {
 
+		if activeCard != nil && activeCard.ContentType == ContentTypeInternet {
+			freezeText := "Freeze Page"
+			if activeCard.Contents.(*InternetContents).Frozen() {
+				freezeText = "Unfreeze Page"
+			}
+			if freezeButton.Label.TextAsString() != freezeText {
+				freezeButton.Label.SetText([]rune(freezeText))
+			}
+
+			// Selectors can't be used with the text-only browser, which only has the page's text
+			if tab := activeCard.Contents.(*InternetContents).BrowserTab; tab != nil {
+				watchSelectorLabel.Editable = tab.Backend.Interactive()
+			}
+		}
+
 		if (activeCard != nil && (!activeCard.Valid || !activeCard.selected)) || activeCard == nil {
 
 			for card := range globals.Project.CurrentPage.Selection.Cards {
@@ -3960,6 +5569,16 @@ horizontally.`))
 					aspectRatioDropdown.UpdateProperty(card.Properties.Get("aspect ratio"))
 					updateFramerateDropdown.UpdateProperty(card.Properties.Get("update framerate"))
 					updateOnlyWhenDropdown.UpdateProperty(card.Properties.Get("update only when"))
+					profileDropdown.UpdateProperty(card.Properties.Get("browser profile"))
+					watchCheckbox.Property = card.Properties.Get("watch")
+					watchNotifyCheckbox.Property = card.Properties.Get("watch notify")
+					watchIntervalDropdown.UpdateProperty(card.Properties.Get("watch interval"))
+
+					if watchSelectorLabel.Property != card.Properties.Get("watch selector") {
+						watchSelectorLabel.Property = card.Properties.Get("watch selector")
+						watchSelectorLabel.SetText([]rune(watchSelectorLabel.Property.AsString()))
+					}
+					setZoomScrollbar(card.Contents.(*InternetContents).ZoomLevel())
 
 					if urlLabel.Property != card.Properties.Get("url") {
 						urlLabel.Property = card.Properties.Get("url")
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Map cards that touch on the page's grid act as one continuous surface: values on a neighboring Map card count as
+// neighbors for auto-tiling, and flood fills and lines carry on into them. Positions here are in grid cells relative
+// to the top-left corner of the Map card doing the lookup, so they can lie outside of its own bounds.
+
+// ConnectedMaps returns the other Map cards directly touching this one's sides (or overlapping it).
+func (mc *MapContents) ConnectedMaps() []*MapContents {
+
+	rect := mc.Card.Rect
+	gs := globals.GridSize
+	grid := mc.Card.Page.Grid
+
+	found := []*MapContents{}
+	added := map[*Card]bool{mc.Card: true}
+
+	areas := [][4]float32{
+		{rect.X - gs, rect.Y, rect.W + gs*2, rect.H},
+		{rect.X, rect.Y - gs, rect.W, rect.H + gs*2},
+	}
+
+	for _, area := range areas {
+
+		for _, card := range grid.CardsInArea(area[0], area[1], area[2], area[3]) {
+
+			if added[card] || !card.Valid || card.ContentType != ContentTypeMap {
+				continue
+			}
+
+			added[card] = true
+			found = append(found, card.Contents.(*MapContents))
+
+		}
+
+	}
+
+	return found
+
+}
+
+// InvalidateConnections marks this map and the maps it was touching to be redrawn on their next update, as their
+// textures depend on each other. It's called whenever a map moves, or is resized or removed.
+func (mc *MapContents) InvalidateConnections() {
+	mc.connectionsOutdated = true
+	mc.InvalidateNeighbors()
+}
+
+// InvalidateNeighbors marks just the maps touching this one to be redrawn, for when its contents change (as it's
+// redrawn itself then anyway).
+func (mc *MapContents) InvalidateNeighbors() {
+	for _, n := range mc.neighbors {
+		n.connectionsOutdated = true
+	}
+}
+
+// updateConnections finds the maps touching this one and redraws it if it's been invalidated since the last update.
+func (mc *MapContents) updateConnections() {
+
+	if !mc.connectionsOutdated {
+		return
+	}
+
+	mc.connectionsOutdated = false
+
+	neighbors := mc.ConnectedMaps()
+
+	// Maps that have just started touching this one need redrawing too
+	for _, n := range neighbors {
+		if !slices.Contains(mc.neighbors, n) {
+			n.connectionsOutdated = true
+		}
+	}
+
+	// A map on its own only needs redrawing if it's just lost its neighbors
+	hadNeighbors := len(mc.neighbors) > 0
+
+	mc.neighbors = neighbors
+
+	if len(neighbors) > 0 || hadNeighbors {
+		mc.UpdateTexture()
+	}
+
+}
+
+// connectedMapAt returns the Map card at the given cell relative to this one (which can be this one), along with the
+// position of the cell within it.
+func (mc *MapContents) connectedMapAt(x, y int) (*MapContents, int, int) {
+
+	if x >= 0 && y >= 0 && x < mc.MapData.Width && y < mc.MapData.Height {
+		return mc, x, y
+	}
+
+	gs := globals.GridSize
+
+	// Use the center of the cell so neighbors that aren't quite aligned to the grid still line up
+	cx := mc.Card.Rect.X + (float32(x)+0.5)*gs
+	cy := mc.Card.Rect.Y + (float32(y)+0.5)*gs
+
+	for _, card := range mc.Card.Page.Grid.CardsInArea(cx-gs/2, cy-gs/2, gs, gs) {
+
+		if card == mc.Card || !card.Valid || card.ContentType != ContentTypeMap {
+			continue
+		}
+
+		if cx < card.Rect.X || cy < card.Rect.Y || cx >= card.Rect.X+card.Rect.W || cy >= card.Rect.Y+card.Rect.H {
+			continue
+		}
+
+		other := card.Contents.(*MapContents)
+		return other, int(math.Floor(float64((cx - card.Rect.X) / gs))), int(math.Floor(float64((cy - card.Rect.Y) / gs)))
+
+	}
+
+	return nil, 0, 0
+
+}
+
+// matchingLayer returns the layer on another map that lines up with the given layer of this one: the layer at the same
+// index on its current level.
+func (mc *MapContents) matchingLayer(other *MapContents, layer *MapLayer) *MapLayer {
+
+	if other == mc {
+		return layer
+	}
+
+	for i, l := range mc.MapData.Level().Layers {
+		if l == layer {
+			if otherLayers := other.MapData.Level().Layers; i < len(otherLayers) {
+				return otherLayers[i]
+			}
+			break
+		}
+	}
+
+	return nil
+
+}
+
+// ConnectedGet returns the value of the given layer at a cell relative to this map, looking into neighboring Map cards
+// for cells outside of it. -1 is returned for cells not on any map.
+func (mc *MapContents) ConnectedGet(layer *MapLayer, x, y int) int {
+
+	other, ox, oy := mc.connectedMapAt(x, y)
+	if other == nil {
+		return -1
+	}
+
+	otherLayer := mc.matchingLayer(other, layer)
+	if otherLayer == nil {
+		return -1
+	}
+
+	return other.MapData.LayerGetI(otherLayer, ox, oy)
+
+}
+
+// ConnectedSet sets the value of the given layer at a cell relative to this map, setting it on a neighboring Map card
+// for cells outside of it. The map that was changed is returned (or nil if the cell isn't on any map).
+func (mc *MapContents) ConnectedSet(layer *MapLayer, x, y, value int) *MapContents {
+
+	other, ox, oy := mc.connectedMapAt(x, y)
+	if other == nil {
+		return nil
+	}
+
+	otherLayer := mc.matchingLayer(other, layer)
+	if otherLayer == nil || other.MapData.LayerGetI(otherLayer, ox, oy) < 0 {
+		return nil
+	}
+
+	if otherLayer.Data[oy][ox] != value {
+		PlayUISound(UISoundTypeTap)
+	}
+
+	otherLayer.Data[oy][ox] = value
+
+	return other
+
+}
+
+// commitConnected saves changes made to neighboring maps through ConnectedSet; this map's own changes are handled by the
+// caller as usual.
+func (mc *MapContents) commitConnected(changed map[*MapContents]bool) {
+
+	for other := range changed {
+		if other != mc {
+			other.MapData.Commit()
+		}
+	}
+
+}
+
+// ConnectedFill flood fills the area of matching values starting at the given cell, spreading into neighboring Map
+// cards. It returns if anything changed.
+func (mc *MapContents) ConnectedFill(start Vector, fill int) bool {
+
+	layer := mc.MapData.Layer()
+	sx, sy := int(start.X), int(start.Y)
+
+	empty := mc.ConnectedGet(layer, sx, sy)
+
+	if empty < 0 || empty == fill {
+		return false
+	}
+
+	type cell struct{ X, Y int }
+
+	changed := map[*MapContents]bool{}
+	queue := []cell{{sx, sy}}
+	checked := map[cell]bool{{sx, sy}: true}
+
+	for len(queue) > 0 {
+
+		c := queue[0]
+		queue = queue[1:]
+
+		if changedMap := mc.ConnectedSet(layer, c.X, c.Y, fill); changedMap != nil {
+			changed[changedMap] = true
+		}
+
+		for _, n := range []cell{{c.X - 1, c.Y}, {c.X + 1, c.Y}, {c.X, c.Y - 1}, {c.X, c.Y + 1}} {
+			if !checked[n] && mc.ConnectedGet(layer, n.X, n.Y) == empty {
+				checked[n] = true
+				queue = append(queue, n)
+			}
+		}
+
+	}
+
+	mc.commitConnected(changed)
+
+	return len(changed) > 0
+
+}
+
+// UnclampedGridCursorPosition returns the grid cell the mouse is over relative to this map, even if it's outside of it.
+func (mc *MapContents) UnclampedGridCursorPosition() Vector {
+
+	mp := globals.Mouse.WorldPosition()
+
+	return Vector{
+		float32(math.Floor(float64((mp.X - mc.Card.Rect.X) / globals.GridSize))),
+		float32(math.Floor(float64((mp.Y - mc.Card.Rect.Y) / globals.GridSize))),
+	}
+
+}
+
+// lineCells returns the cells along a line between two cells, stepping horizontally and vertically in turn so the line
+// is connected.
+func lineCells(start, end Vector) []Vector {
+
+	cells := []Vector{start.Rounded()}
+
+	if start.Rounded().Equals(end.Rounded()) {
+		return cells
+	}
+
+	dir := end.Sub(start).Normalized()
+	horizontal := true
+
+	for i := 0; i < 100000; i++ {
+
+		if horizontal {
+			start.X += dir.X / 2
+		} else {
+			start.Y += dir.Y / 2
+		}
+
+		horizontal = !horizontal
+
+		if !start.Rounded().Equals(cells[len(cells)-1]) {
+			cells = append(cells, start.Rounded())
+		}
+
+		if start.Rounded().Equals(end.Rounded()) {
+			break
+		}
+
+	}
+
+	return cells
+
+}
+
+// ConnectedLine draws a line between two cells relative to this map, carrying on across neighboring Map cards.
+func (mc *MapContents) ConnectedLine(start, end Vector, fill int) bool {
+
+	layer := mc.MapData.Layer()
+	changed := map[*MapContents]bool{}
+
+	for _, cell := range lineCells(start, end) {
+		if changedMap := mc.ConnectedSet(layer, int(cell.X), int(cell.Y), fill); changedMap != nil {
+			changed[changedMap] = true
+		}
+	}
+
+	mc.commitConnected(changed)
+
+	return len(changed) > 0
+
+}
+
+// MergeMaps combines the given Map cards into the top-left-most one, resizing it to cover all of them. Levels and
+// layers are matched up by index, and the other cards are deleted.
+func MergeMaps(maps []*MapContents) error {
+
+	if len(maps) < 2 {
+		return errors.New("select at least two Map cards to merge")
+	}
+
+	gs := globals.GridSize
+
+	sort.SliceStable(maps, func(i, j int) bool {
+		if maps[i].Card.Rect.Y != maps[j].Card.Rect.Y {
+			return maps[i].Card.Rect.Y < maps[j].Card.Rect.Y
+		}
+		return maps[i].Card.Rect.X < maps[j].Card.Rect.X
+	})
+
+	cellOf := func(v float32) int { return int(math.Round(float64(v / gs))) }
+
+	x0, y0 := cellOf(maps[0].Card.Rect.X), cellOf(maps[0].Card.Rect.Y)
+	x1, y1 := x0, y0
+
+	for _, m := range maps {
+		x0 = min(x0, cellOf(m.Card.Rect.X))
+		y0 = min(y0, cellOf(m.Card.Rect.Y))
+		x1 = max(x1, cellOf(m.Card.Rect.X)+m.MapData.Width)
+		y1 = max(y1, cellOf(m.Card.Rect.Y)+m.MapData.Height)
+	}
+
+	width := x1 - x0
+	height := y1 - y0
+
+	maxCells := int(min(4096, SmallestRendererMaxTextureSize()) / int32(gs))
+
+	if width > maxCells || height > maxCells {
+		return errors.New("the merged map would be too large")
+	}
+
+	target := maps[0]
+
+	// Build the merged levels, using the names and settings of the first map to have each level and layer
+	levels := []*MapLevel{}
+
+	for _, m := range maps {
+
+		for li, level := range m.MapData.Levels {
+
+			if li >= len(levels) {
+				levels = append(levels, &MapLevel{Name: level.Name, ActiveLayer: level.ActiveLayer})
+			}
+
+			merged := levels[li]
+
+			for lj, layer := range level.Layers {
+
+				if lj >= len(merged.Layers) {
+					merged.Layers = append(merged.Layers, &MapLayer{
+						Name:    layer.Name,
+						Visible: layer.Visible,
+						Opacity: layer.Opacity,
+						Data:    emptyMapGrid(width, height),
+					})
+				}
+
+				ox := cellOf(m.Card.Rect.X) - x0
+				oy := cellOf(m.Card.Rect.Y) - y0
+
+				for y := 0; y < m.MapData.Height; y++ {
+					for x := 0; x < m.MapData.Width; x++ {
+						if value := m.MapData.LayerGetI(layer, x, y); value > 0 {
+							merged.Layers[lj].Data[oy+y][ox+x] = value
+						}
+					}
+				}
+
+			}
+
+		}
+
+	}
+
+	target.Card.Rect.X = float32(x0) * gs
+	target.Card.Rect.Y = float32(y0) * gs
+	target.Card.Recreate(float32(width)*gs, float32(height)*gs)
+	target.Card.LockPosition()
+
+	target.MapData.Levels = levels
+	target.RecreateTexture()
+	target.MapData.syncActiveLayer()
+	target.MapData.Commit()
+
+	others := []*Card{}
+	for _, m := range maps[1:] {
+		others = append(others, m.Card)
+	}
+
+	target.Card.Page.DeleteCards(others...)
+
+	return nil
+
+}
@@ -0,0 +1,839 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ncruces/zenity"
+)
+
+// Map values are exported to Tiled as tile IDs directly (the palette index in the low bits, and the pattern as the
+// MapPattern bit flags above it), using a generated tileset with one tile for each possible value.
+const (
+	MapTilesetName    = "MasterPlan"
+	MapTilesetColumns = 16
+	MapTilesetRows    = MapPatternTerrain/MapTilesetColumns + 1
+	MapTileSize       = 32
+
+	tiledGIDMask = 0x0FFFFFFF // Tiled stores tile flipping in the top bits of each GID
+)
+
+// mapTilePatterns lists the patterns in the order the MapPattern bit flags go.
+var mapTilePatterns = []int{MapPatternSolid, MapPatternCrossed, MapPatternDotted, MapPatternChecked, MapPatternTerrain}
+
+// MapTilesetImage renders the tileset used for Tiled exports, where the tile at index (value - 1) represents the
+// given map value.
+func MapTilesetImage() *image.NRGBA {
+
+	img := image.NewNRGBA(image.Rect(0, 0, MapTilesetColumns*MapTileSize, MapTilesetRows*MapTileSize))
+
+	for _, pattern := range mapTilePatterns {
+
+		for colorIndex := range MapPaletteColors {
+
+			value := pattern | (colorIndex + 1)
+			tx := ((value - 1) % MapTilesetColumns) * MapTileSize
+			ty := ((value - 1) / MapTilesetColumns) * MapTileSize
+
+			base := MapPaletteColors[colorIndex]
+			shade := base.Sub(40)
+
+			for y := 0; y < MapTileSize; y++ {
+
+				for x := 0; x < MapTileSize; x++ {
+
+					c := base
+
+					switch pattern {
+					case MapPatternCrossed:
+						if (x+y)%8 == 0 || (x-y+MapTileSize)%8 == 0 {
+							c = shade
+						}
+					case MapPatternDotted:
+						if x%8 == 3 && y%8 == 3 {
+							c = shade
+						}
+					case MapPatternChecked:
+						if (x/8+y/8)%2 == 0 {
+							c = shade
+						}
+					case MapPatternTerrain:
+						if (x*7+y*13+x*y)%11 == 0 {
+							c = shade
+						}
+					}
+
+					img.Set(tx+x, ty+y, color.NRGBA{c[0], c[1], c[2], c[3]})
+
+				}
+
+			}
+
+		}
+
+	}
+
+	return img
+
+}
+
+// NearestMapPaletteValue returns the map value (palette index + 1) whose color is closest to the given color, or 0
+// if it's mostly transparent.
+func NearestMapPaletteValue(c color.Color) int {
+
+	r, g, b, a := c.RGBA()
+
+	if a < 0x8000 {
+		return 0
+	}
+
+	// Undo alpha premultiplication
+	r = r * 0xffff / a
+	g = g * 0xffff / a
+	b = b * 0xffff / a
+
+	return nearestPaletteIndex(MapPaletteColors, NewColor(uint8(r>>8), uint8(g>>8), uint8(b>>8), 255)) + 1
+
+}
+
+type tmxMap struct {
+	XMLName      xml.Name     `xml:"map"`
+	Version      string       `xml:"version,attr"`
+	Orientation  string       `xml:"orientation,attr"`
+	RenderOrder  string       `xml:"renderorder,attr"`
+	Width        int          `xml:"width,attr"`
+	Height       int          `xml:"height,attr"`
+	TileWidth    int          `xml:"tilewidth,attr"`
+	TileHeight   int          `xml:"tileheight,attr"`
+	Infinite     int          `xml:"infinite,attr"`
+	NextLayerID  int          `xml:"nextlayerid,attr"`
+	NextObjectID int          `xml:"nextobjectid,attr"`
+	Tilesets     []tmxTileset `xml:"tileset"`
+	Layers       []tmxLayer   `xml:"layer"`
+	Groups       []tmxGroup   `xml:"group"`
+}
+
+type tmxTileset struct {
+	XMLName    xml.Name  `xml:"tileset"`
+	FirstGID   int       `xml:"firstgid,attr,omitempty"`
+	Source     string    `xml:"source,attr,omitempty"`
+	Name       string    `xml:"name,attr,omitempty"`
+	TileWidth  int       `xml:"tilewidth,attr,omitempty"`
+	TileHeight int       `xml:"tileheight,attr,omitempty"`
+	Spacing    int       `xml:"spacing,attr,omitempty"`
+	Margin     int       `xml:"margin,attr,omitempty"`
+	TileCount  int       `xml:"tilecount,attr,omitempty"`
+	Columns    int       `xml:"columns,attr,omitempty"`
+	Image      *tmxImage `xml:"image"`
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr,omitempty"`
+	Height int    `xml:"height,attr,omitempty"`
+}
+
+type tmxGroup struct {
+	ID      int        `xml:"id,attr"`
+	Name    string     `xml:"name,attr"`
+	Visible string     `xml:"visible,attr,omitempty"`
+	Layers  []tmxLayer `xml:"layer"`
+	Groups  []tmxGroup `xml:"group"`
+}
+
+type tmxLayer struct {
+	ID      int     `xml:"id,attr"`
+	Name    string  `xml:"name,attr"`
+	Width   int     `xml:"width,attr"`
+	Height  int     `xml:"height,attr"`
+	Opacity float32 `xml:"opacity,attr,omitempty"`
+	Visible string  `xml:"visible,attr,omitempty"`
+	Data    tmxData `xml:"data"`
+}
+
+type tmxData struct {
+	Encoding    string `xml:"encoding,attr,omitempty"`
+	Compression string `xml:"compression,attr,omitempty"`
+	Content     string `xml:",chardata"`
+}
+
+// visibleLayerGrid returns the layer's values within the map's bounds as a flat, row-major list.
+func (mapData *MapData) visibleLayerGrid(layer *MapLayer) []int {
+
+	values := make([]int, 0, mapData.Width*mapData.Height)
+
+	for y := 0; y < mapData.Height; y++ {
+		for x := 0; x < mapData.Width; x++ {
+			values = append(values, max(0, mapData.LayerGetI(layer, x, y)))
+		}
+	}
+
+	return values
+
+}
+
+// writeMapTileset saves the Tiled tileset image next to the exported map, returning its filename.
+func writeMapTileset(exportPath string) (string, error) {
+
+	tilesetName := strings.TrimSuffix(filepath.Base(exportPath), filepath.Ext(exportPath)) + "_tiles.png"
+
+	out, err := os.Create(filepath.Join(filepath.Dir(exportPath), tilesetName))
+	if err != nil {
+		return "", err
+	}
+
+	defer out.Close()
+
+	if err := png.Encode(out, MapTilesetImage()); err != nil {
+		return "", err
+	}
+
+	return tilesetName, nil
+
+}
+
+// ExportTMX saves the map as a Tiled .tmx file, with each level as a group of tile layers.
+func (mc *MapContents) ExportTMX(path string) error {
+
+	tilesetName, err := writeMapTileset(path)
+	if err != nil {
+		return err
+	}
+
+	mapData := mc.MapData
+
+	tmx := tmxMap{
+		Version:      "1.10",
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        mapData.Width,
+		Height:       mapData.Height,
+		TileWidth:    MapTileSize,
+		TileHeight:   MapTileSize,
+		NextObjectID: 1,
+		Tilesets: []tmxTileset{{
+			FirstGID:   1,
+			Name:       MapTilesetName,
+			TileWidth:  MapTileSize,
+			TileHeight: MapTileSize,
+			TileCount:  MapTilesetColumns * MapTilesetRows,
+			Columns:    MapTilesetColumns,
+			Image:      &tmxImage{Source: tilesetName, Width: MapTilesetColumns * MapTileSize, Height: MapTilesetRows * MapTileSize},
+		}},
+	}
+
+	id := 1
+
+	for levelIndex, level := range mapData.Levels {
+
+		group := tmxGroup{ID: id, Name: level.Name}
+		if levelIndex != mapData.CurrentLevel {
+			group.Visible = "0"
+		}
+		id++
+
+		for _, layer := range level.Layers {
+
+			csv := strings.Builder{}
+			csv.WriteString("\n")
+
+			for i, value := range mapData.visibleLayerGrid(layer) {
+				csv.WriteString(strconv.Itoa(value))
+				if i < mapData.Width*mapData.Height-1 {
+					csv.WriteString(",")
+				}
+				if (i+1)%mapData.Width == 0 {
+					csv.WriteString("\n")
+				}
+			}
+
+			tl := tmxLayer{
+				ID:      id,
+				Name:    layer.Name,
+				Width:   mapData.Width,
+				Height:  mapData.Height,
+				Opacity: layer.Opacity,
+				Data:    tmxData{Encoding: "csv", Content: csv.String()},
+			}
+
+			if !layer.Visible {
+				tl.Visible = "0"
+			}
+
+			group.Layers = append(group.Layers, tl)
+			id++
+
+		}
+
+		tmx.Groups = append(tmx.Groups, group)
+
+	}
+
+	tmx.NextLayerID = id
+
+	data, err := xml.MarshalIndent(tmx, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+
+}
+
+// ExportTiledJSON saves the map as a Tiled .json map, with each level as a group of tile layers.
+func (mc *MapContents) ExportTiledJSON(path string) error {
+
+	tilesetName, err := writeMapTileset(path)
+	if err != nil {
+		return err
+	}
+
+	mapData := mc.MapData
+
+	id := 1
+	groups := []map[string]any{}
+
+	for levelIndex, level := range mapData.Levels {
+
+		group := map[string]any{
+			"type":    "group",
+			"id":      id,
+			"name":    level.Name,
+			"visible": levelIndex == mapData.CurrentLevel,
+			"opacity": 1,
+			"x":       0,
+			"y":       0,
+		}
+		id++
+
+		layers := []map[string]any{}
+
+		for _, layer := range level.Layers {
+
+			layers = append(layers, map[string]any{
+				"type":    "tilelayer",
+				"id":      id,
+				"name":    layer.Name,
+				"width":   mapData.Width,
+				"height":  mapData.Height,
+				"x":       0,
+				"y":       0,
+				"opacity": layer.Opacity,
+				"visible": layer.Visible,
+				"data":    mapData.visibleLayerGrid(layer),
+			})
+			id++
+
+		}
+
+		group["layers"] = layers
+		groups = append(groups, group)
+
+	}
+
+	tiledMap := map[string]any{
+		"type":         "map",
+		"version":      "1.10",
+		"orientation":  "orthogonal",
+		"renderorder":  "right-down",
+		"width":        mapData.Width,
+		"height":       mapData.Height,
+		"tilewidth":    MapTileSize,
+		"tileheight":   MapTileSize,
+		"infinite":     false,
+		"nextlayerid":  id,
+		"nextobjectid": 1,
+		"tilesets": []map[string]any{{
+			"firstgid":    1,
+			"name":        MapTilesetName,
+			"tilewidth":   MapTileSize,
+			"tileheight":  MapTileSize,
+			"tilecount":   MapTilesetColumns * MapTilesetRows,
+			"columns":     MapTilesetColumns,
+			"image":       tilesetName,
+			"imagewidth":  MapTilesetColumns * MapTileSize,
+			"imageheight": MapTilesetRows * MapTileSize,
+			"margin":      0,
+			"spacing":     0,
+		}},
+		"layers": groups,
+	}
+
+	data, err := json.MarshalIndent(tiledMap, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+
+}
+
+// ExportPNG saves the visible layers of the current level as an indexed PNG, one pixel per cell. Pixel indices match
+// map palette indices (0 being empty), so patterns aren't included.
+func (mc *MapContents) ExportPNG(path string) error {
+
+	mapData := mc.MapData
+
+	palette := color.Palette{color.NRGBA{0, 0, 0, 0}}
+	for _, c := range MapPaletteColors {
+		palette = append(palette, color.NRGBA{c[0], c[1], c[2], c[3]})
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, mapData.Width, mapData.Height), palette)
+
+	for _, layer := range mapData.Level().Layers {
+
+		if !layer.Visible {
+			continue
+		}
+
+		for y := 0; y < mapData.Height; y++ {
+			for x := 0; x < mapData.Width; x++ {
+				if value := mapData.LayerGetI(layer, x, y); value > 0 {
+					img.SetColorIndex(x, y, uint8(mc.ColorIndexToColor(value)))
+				}
+			}
+		}
+
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	return png.Encode(out, img)
+
+}
+
+// importGrid resizes the map to fit the given grid of values and puts them into the active layer.
+func (mc *MapContents) importGrid(width, height int, valueAt func(x, y int) int) {
+
+	mc.Card.Recreate(float32(width)*globals.GridSize, float32(height)*globals.GridSize)
+	mc.RecreateTexture()
+
+	if mc.MapData.Width < width || mc.MapData.Height < height {
+		globals.EventLog.Log("Warning: The imported map is %dx%d, but Map cards can only be %dx%d; it has been cut off.", true, width, height, mc.MapData.Width, mc.MapData.Height)
+	}
+
+	for y := 0; y < mc.MapData.Height; y++ {
+		for x := 0; x < mc.MapData.Width; x++ {
+			value := 0
+			if x < width && y < height {
+				value = valueAt(x, y)
+			}
+			mc.MapData.SetI(x, y, value)
+		}
+	}
+
+	mc.MapData.Commit()
+
+}
+
+// ImportPNG loads an image into the map's active layer, one cell per pixel, using the nearest palette color for each
+// as a solid cell.
+func (mc *MapContents) ImportPNG(path string) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+
+	mc.importGrid(bounds.Dx(), bounds.Dy(), func(x, y int) int {
+		// Imported cells are solid; the pattern selected for drawing isn't part of the image
+		return NearestMapPaletteValue(img.At(bounds.Min.X+x, bounds.Min.Y+y)) | MapPatternSolid
+	})
+
+	return nil
+
+}
+
+// TMXLayerNames returns the names of the tile layers in the given .tmx file, with any groups they're in.
+func TMXLayerNames(path string) ([]string, error) {
+
+	tmx, err := readTMX(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	for _, layer := range tmx.allLayers() {
+		names = append(names, layer.Name)
+	}
+
+	return names, nil
+
+}
+
+func readTMX(path string) (*tmxMap, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmx := &tmxMap{}
+
+	if err := xml.Unmarshal(data, tmx); err != nil {
+		return nil, err
+	}
+
+	return tmx, nil
+
+}
+
+// allLayers returns the map's tile layers, including those in groups; layers in groups are named "Group/Layer".
+func (tmx *tmxMap) allLayers() []tmxLayer {
+
+	layers := append([]tmxLayer{}, tmx.Layers...)
+
+	var addGroup func(prefix string, group tmxGroup)
+
+	addGroup = func(prefix string, group tmxGroup) {
+
+		for _, layer := range group.Layers {
+			layer.Name = prefix + group.Name + "/" + layer.Name
+			layers = append(layers, layer)
+		}
+
+		for _, sub := range group.Groups {
+			addGroup(prefix+group.Name+"/", sub)
+		}
+
+	}
+
+	for _, group := range tmx.Groups {
+		addGroup("", group)
+	}
+
+	return layers
+
+}
+
+// decodeGIDs returns the GIDs stored in a TMX layer's data, in any of Tiled's encodings.
+func (data tmxData) decodeGIDs() ([]uint32, error) {
+
+	gids := []uint32{}
+
+	switch data.Encoding {
+
+	case "csv":
+
+		for _, field := range strings.Split(data.Content, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			gids = append(gids, uint32(gid))
+		}
+
+	case "base64":
+
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data.Content))
+		if err != nil {
+			return nil, err
+		}
+
+		var reader io.Reader = bytes.NewReader(raw)
+
+		switch data.Compression {
+		case "":
+		case "zlib":
+			if reader, err = zlib.NewReader(reader); err != nil {
+				return nil, err
+			}
+		case "gzip":
+			if reader, err = gzip.NewReader(reader); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported TMX compression: %s", data.Compression)
+		}
+
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i+4 <= len(decompressed); i += 4 {
+			gids = append(gids, binary.LittleEndian.Uint32(decompressed[i:]))
+		}
+
+	default:
+		return nil, errors.New("unsupported TMX layer encoding; please save the map with CSV or Base64 layer data")
+
+	}
+
+	return gids, nil
+
+}
+
+// tilesetValues returns the map value to use for each of the tileset's tiles. Tiles from MasterPlan's own tileset
+// map back directly (keeping their patterns), while tiles from other tilesets become solid cells of the palette color
+// nearest to the tile's average color.
+func tilesetValues(tmxPath string, tileset tmxTileset) (map[int]int, error) {
+
+	if tileset.Source != "" {
+
+		tsxPath := filepath.Join(filepath.Dir(tmxPath), tileset.Source)
+
+		data, err := os.ReadFile(tsxPath)
+		if err != nil {
+			return nil, err
+		}
+
+		external := tmxTileset{}
+		if err := xml.Unmarshal(data, &external); err != nil {
+			return nil, err
+		}
+
+		external.FirstGID = tileset.FirstGID
+		tileset = external
+		tmxPath = tsxPath
+
+	}
+
+	values := map[int]int{}
+
+	if tileset.Name == MapTilesetName {
+		for id := 0; id < MapTilesetColumns*MapTilesetRows; id++ {
+			values[id] = id + 1
+		}
+		return values, nil
+	}
+
+	if tileset.Image == nil || tileset.TileWidth <= 0 || tileset.TileHeight <= 0 {
+		return values, nil
+	}
+
+	file, err := os.Open(filepath.Join(filepath.Dir(tmxPath), tileset.Image.Source))
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+
+	columns := tileset.Columns
+	if columns <= 0 {
+		columns = max(1, (bounds.Dx()-tileset.Margin*2+tileset.Spacing)/(tileset.TileWidth+tileset.Spacing))
+	}
+
+	count := tileset.TileCount
+	if count <= 0 {
+		count = columns * max(1, (bounds.Dy()-tileset.Margin*2+tileset.Spacing)/(tileset.TileHeight+tileset.Spacing))
+	}
+
+	for id := 0; id < count; id++ {
+
+		tx := bounds.Min.X + tileset.Margin + (id%columns)*(tileset.TileWidth+tileset.Spacing)
+		ty := bounds.Min.Y + tileset.Margin + (id/columns)*(tileset.TileHeight+tileset.Spacing)
+
+		var r, g, b, a, n uint64
+
+		for y := ty; y < ty+tileset.TileHeight; y++ {
+			for x := tx; x < tx+tileset.TileWidth; x++ {
+				cr, cg, cb, ca := img.At(x, y).RGBA()
+				r += uint64(cr)
+				g += uint64(cg)
+				b += uint64(cb)
+				a += uint64(ca)
+				n++
+			}
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		if value := NearestMapPaletteValue(color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)}); value > 0 {
+			values[id] = value | MapPatternSolid
+		}
+
+	}
+
+	return values, nil
+
+}
+
+// ImportTMX loads the named tile layer from a Tiled .tmx file into the map's active layer.
+func (mc *MapContents) ImportTMX(path, layerName string) error {
+
+	tmx, err := readTMX(path)
+	if err != nil {
+		return err
+	}
+
+	var layer *tmxLayer
+
+	for _, l := range tmx.allLayers() {
+		if l.Name == layerName {
+			layer = &l
+			break
+		}
+	}
+
+	if layer == nil {
+		return fmt.Errorf("no tile layer named %s", layerName)
+	}
+
+	gids, err := layer.Data.decodeGIDs()
+	if err != nil {
+		return err
+	}
+
+	// Each tileset's tiles, by GID
+	gidValues := map[int]int{}
+
+	for _, tileset := range tmx.Tilesets {
+
+		values, err := tilesetValues(path, tileset)
+		if err != nil {
+			globals.EventLog.Log("Warning: Couldn't read tileset %s: %s", true, tileset.Name+tileset.Source, err.Error())
+			continue
+		}
+
+		for id, value := range values {
+			gidValues[tileset.FirstGID+id] = value
+		}
+
+	}
+
+	mc.importGrid(layer.Width, layer.Height, func(x, y int) int {
+		i := y*layer.Width + x
+		if i >= len(gids) {
+			return 0
+		}
+		return gidValues[int(gids[i]&tiledGIDMask)]
+	})
+
+	return nil
+
+}
+
+// ExportAs prompts for where to export the map, exporting it as a Tiled map or an indexed PNG depending on the chosen
+// file's extension.
+func (mc *MapContents) ExportAs() {
+
+	filename, err := zenity.SelectFileSave(zenity.Title("Export Map..."), zenity.ConfirmOverwrite(),
+		zenity.FileFilter{Name: "Tiled Map (*.tmx)", Patterns: []string{"*.tmx"}},
+		zenity.FileFilter{Name: "Tiled JSON Map (*.json)", Patterns: []string{"*.json"}},
+		zenity.FileFilter{Name: "Indexed PNG Image (*.png)", Patterns: []string{"*.png"}},
+	)
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = mc.ExportTiledJSON(filename)
+	case ".png":
+		err = mc.ExportPNG(filename)
+	case ".tmx":
+		err = mc.ExportTMX(filename)
+	default:
+		filename += ".tmx"
+		err = mc.ExportTMX(filename)
+	}
+
+	if err != nil {
+		globals.EventLog.Log("Error exporting map: %s", true, err.Error())
+		return
+	}
+
+	globals.EventLog.Log("Map exported to %s.", false, filename)
+
+}
+
+// ImportAs prompts for an image or Tiled map to import into the map's active layer, replacing its contents. If a
+// Tiled map has several tile layers, the layer to import is chosen from a list.
+func (mc *MapContents) ImportAs() {
+
+	filename, err := zenity.SelectFile(zenity.Title("Import Map..."),
+		zenity.FileFilter{Name: "Image or Tiled Map (*.png / *.tmx)", Patterns: []string{"*.png", "*.gif", "*.jpg", "*.jpeg", "*.tmx"}},
+	)
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	if strings.ToLower(filepath.Ext(filename)) == ".tmx" {
+
+		names, err := TMXLayerNames(filename)
+		if err != nil {
+			globals.EventLog.Log("Error importing map: %s", true, err.Error())
+			return
+		}
+
+		if len(names) == 0 {
+			globals.EventLog.Log("Error importing map: %s has no tile layers.", true, filename)
+			return
+		}
+
+		layerName := names[0]
+
+		if len(names) > 1 {
+			layerName, err = zenity.List("Layer to import:", names, zenity.Title("Import Map..."))
+			if err != nil || layerName == "" {
+				return
+			}
+		}
+
+		err = mc.ImportTMX(filename, layerName)
+
+	} else {
+		err = mc.ImportPNG(filename)
+	}
+
+	if err != nil {
+		globals.EventLog.Log("Error importing map: %s", true, err.Error())
+		return
+	}
+
+	globals.EventLog.Log("Map imported from %s.", false, filename)
+
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/ncruces/zenity"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Map values store their color index in the bits below the pattern flags, so a palette can't hold more colors than that.
+const MapPaletteMaxColors = MapPatternCrossed - 1
+
+// ParseMapPalette reads a palette stored as comma-separated hex colors (i.e. "FAF0F0,969696").
+func ParseMapPalette(data string) []Color {
+
+	colors := []Color{}
+
+	for _, hex := range strings.Split(data, ",") {
+		if c, ok := parseHexColor(hex); ok {
+			colors = append(colors, c)
+		}
+	}
+
+	return colors
+
+}
+
+// parseHexColor parses a "#RRGGBB" or "RRGGBB" color, returning false if it isn't one.
+func parseHexColor(hex string) (Color, bool) {
+
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+
+	if len(hex) != 6 {
+		return nil, false
+	}
+
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return nil, false
+	}
+
+	return ColorFromHexString(hex), true
+
+}
+
+func serializeMapPalette(colors []Color) string {
+
+	hexes := []string{}
+
+	for _, c := range colors {
+		hexes = append(hexes, c.ToHexString()[:6])
+	}
+
+	return strings.Join(hexes, ",")
+
+}
+
+// MapPalette returns the project's map palette, which is the default palette unless the project has its own.
+func (project *Project) MapPalette() []Color {
+
+	if prop := project.Properties.GetIfExists(ProjectMapPalette); prop != nil {
+		if colors := ParseMapPalette(prop.AsString()); len(colors) > 0 {
+			return colors
+		}
+	}
+
+	return append([]Color{}, DefaultMapPaletteColors...)
+
+}
+
+// ApplyMapPalette makes the project's palette the one maps are drawn and edited with.
+func (project *Project) ApplyMapPalette() {
+
+	MapPaletteColors = project.MapPalette()
+
+	if MapDrawingColor > len(MapPaletteColors) {
+		MapDrawingColor = len(MapPaletteColors)
+	}
+
+	refreshMapPaletteButtons()
+
+	for _, page := range project.Pages {
+		for _, card := range page.Cards {
+			if card.ContentType == ContentTypeMap {
+				card.Contents.(*MapContents).UpdateTexture()
+			}
+		}
+	}
+
+}
+
+// SetMapPalette sets the project's map palette. Map values with color indices past the end of the new palette are
+// changed to the palette color nearest to the one they had.
+func (project *Project) SetMapPalette(colors []Color) {
+
+	if len(colors) > MapPaletteMaxColors {
+		colors = colors[:MapPaletteMaxColors]
+	}
+
+	if len(colors) == 0 {
+		colors = append([]Color{}, DefaultMapPaletteColors...)
+	}
+
+	oldColors := MapPaletteColors
+
+	project.remapMapColors(func(colorIndex int) int {
+		if colorIndex <= len(colors) {
+			return colorIndex
+		}
+		if colorIndex <= len(oldColors) {
+			return nearestPaletteIndex(colors, oldColors[colorIndex-1]) + 1
+		}
+		return len(colors)
+	})
+
+	data := serializeMapPalette(colors)
+	if data == serializeMapPalette(DefaultMapPaletteColors) {
+		data = ""
+	}
+
+	project.Properties.Get(ProjectMapPalette).Set(data)
+	project.ApplyMapPalette()
+	project.SetModifiedState()
+
+}
+
+// RemoveMapPaletteColor removes a color from the project's palette. Map cells using the removed color switch to the
+// nearest remaining color, and the indices of the colors after it are shifted down so cells keep their colors.
+func (project *Project) RemoveMapPaletteColor(index int) bool {
+
+	oldColors := MapPaletteColors
+
+	if len(oldColors) <= 1 || index < 0 || index >= len(oldColors) {
+		return false
+	}
+
+	colors := append(append([]Color{}, oldColors[:index]...), oldColors[index+1:]...)
+
+	replacement := nearestPaletteIndex(colors, oldColors[index]) + 1
+
+	project.remapMapColors(func(colorIndex int) int {
+		if colorIndex == index+1 {
+			return replacement
+		} else if colorIndex > index+1 {
+			return colorIndex - 1
+		}
+		return colorIndex
+	})
+
+	if MapDrawingColor > index+1 {
+		MapDrawingColor--
+	} else if MapDrawingColor == index+1 {
+		MapDrawingColor = replacement
+	}
+
+	project.SetMapPalette(colors)
+
+	return true
+
+}
+
+// remapMapColors changes the color index of every value on every Map card in the project, leaving patterns alone.
+// The palette itself isn't undoable, so the maps' undo states are remapped as well; otherwise, undoing an earlier edit
+// would bring back color indices from before the palette changed.
+func (project *Project) remapMapColors(remap func(colorIndex int) int) {
+
+	for _, page := range project.Pages {
+
+		for _, card := range page.Cards {
+
+			if card.ContentType != ContentTypeMap {
+				continue
+			}
+
+			mc := card.Contents.(*MapContents)
+			changed := false
+
+			for _, layer := range mc.MapData.AllLayers() {
+				for y := range layer.Data {
+					for x, value := range layer.Data[y] {
+						if newValue := remapMapValue(value, remap); newValue != value {
+							layer.Data[y][x] = newValue
+							changed = true
+						}
+					}
+				}
+			}
+
+			if changed {
+				card.Properties.Get("contents").SetRaw(mc.MapData.Serialize())
+				mc.UpdateTexture()
+			}
+
+		}
+
+	}
+
+	history := project.UndoHistory
+
+	for _, frame := range append(append([]*UndoFrame{}, history.Frames...), history.CurrentFrame) {
+
+		for _, state := range frame.States {
+
+			if gjson.Get(state.Serialized, "contents").String() != ContentTypeMap {
+				continue
+			}
+
+			contents := gjson.Get(state.Serialized, "properties.contents").String()
+
+			if remapped, changed := remapSerializedMapColors(contents, remap); changed {
+				state.Serialized, _ = sjson.Set(state.Serialized, "properties.contents", remapped)
+			}
+
+		}
+
+	}
+
+}
+
+// remapMapValue returns the map value with its color index changed by the given function, keeping its pattern.
+func remapMapValue(value int, remap func(colorIndex int) int) int {
+
+	patterns := MapPatternCrossed | MapPatternDotted | MapPatternChecked | MapPatternTerrain
+
+	if colorIndex := value &^ patterns; colorIndex > 0 {
+		return remap(colorIndex) | (value & patterns)
+	}
+
+	return value
+
+}
+
+// remapSerializedMapColors changes the color indices of the values in serialized map contents, returning the new
+// contents and whether any values changed.
+func remapSerializedMapColors(data string, remap func(colorIndex int) int) (string, bool) {
+
+	parsed := gjson.Parse(data)
+
+	paths := []string{}
+
+	if levels := parsed.Get("levels"); levels.Exists() {
+		for i, level := range levels.Array() {
+			for j := range level.Get("layers").Array() {
+				paths = append(paths, "levels."+strconv.Itoa(i)+".layers."+strconv.Itoa(j)+".contents")
+			}
+		}
+	} else {
+		// Maps from before levels and layers were added have a single grid of contents
+		paths = append(paths, "contents")
+	}
+
+	changed := false
+
+	for _, path := range paths {
+
+		grid := [][]int{}
+		gridChanged := false
+
+		for _, row := range parsed.Get(path).Array() {
+			values := []int{}
+			for _, v := range row.Array() {
+				value := int(v.Int())
+				if newValue := remapMapValue(value, remap); newValue != value {
+					value = newValue
+					gridChanged = true
+				}
+				values = append(values, value)
+			}
+			grid = append(grid, values)
+		}
+
+		if gridChanged {
+			data, _ = sjson.Set(data, path, grid)
+			changed = true
+		}
+
+	}
+
+	return data, changed
+
+}
+
+// nearestPaletteIndex returns the index of the palette color closest to the given one.
+func nearestPaletteIndex(palette []Color, c Color) int {
+
+	best := 0
+	bestDist := -1
+
+	for i, pc := range palette {
+
+		dr := int(c[0]) - int(pc[0])
+		dg := int(c[1]) - int(pc[1])
+		db := int(c[2]) - int(pc[2])
+		dist := dr*dr + dg*dg + db*db
+
+		if bestDist < 0 || dist < bestDist {
+			bestDist = dist
+			best = i
+		}
+
+	}
+
+	return best
+
+}
+
+// LoadMapPaletteFile reads the colors from a GIMP (.gpl) palette, or a .hex palette with one hex color per line.
+func LoadMapPaletteFile(path string) ([]Color, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	gpl := strings.ToLower(filepath.Ext(path)) == ".gpl"
+	colors := []Color{}
+
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 0; scanner.Scan(); lineNumber++ {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") && gpl {
+			continue
+		}
+
+		if !gpl {
+			if c, ok := parseHexColor(line); ok {
+				colors = append(colors, c)
+			}
+			continue
+		}
+
+		if lineNumber == 0 {
+			if line != "GIMP Palette" {
+				return nil, errors.New("not a GIMP palette file")
+			}
+			continue
+		}
+
+		// Color lines are "R G B Name"; header lines like "Name: ..." and "Columns: ..." don't start with numbers
+		fields := strings.Fields(line)
+
+		if len(fields) < 3 {
+			continue
+		}
+
+		rgb := [3]uint8{}
+		valid := true
+
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				valid = false
+				break
+			}
+			rgb[i] = uint8(v)
+		}
+
+		if valid {
+			colors = append(colors, NewColor(rgb[0], rgb[1], rgb[2], 255))
+		}
+
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(colors) == 0 {
+		return nil, errors.New("no colors found in palette file")
+	}
+
+	return colors, nil
+
+}
+
+// ImportMapPalette prompts for a palette file to replace the project's map palette with.
+func (project *Project) ImportMapPalette() {
+
+	filename, err := zenity.SelectFile(zenity.Title("Import Map Palette..."), zenity.FileFilter{Name: "Palette File (*.gpl / *.hex)", Patterns: []string{"*.gpl", "*.hex"}})
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	colors, err := LoadMapPaletteFile(filename)
+	if err != nil {
+		globals.EventLog.Log("Error importing map palette: %s", true, err.Error())
+		return
+	}
+
+	if len(colors) > MapPaletteMaxColors {
+		globals.EventLog.Log("Warning: %s has %d colors, but map palettes can only have %d; the rest have been left out.", true, filepath.Base(filename), len(colors), MapPaletteMaxColors)
+	}
+
+	project.SetMapPalette(colors)
+
+	globals.EventLog.Log("Map palette imported from %s.", false, filename)
+
+}
+
+// addMapPaletteColorRows adds a button for each color in the current map palette to the container.
+func addMapPaletteColorRows(container *Container) {
+
+	row := container.AddRow(AlignCenter)
+
+	for i, color := range MapPaletteColors {
+
+		if i%4 == 0 && i > 0 {
+			row = container.AddRow(AlignCenter)
+		}
+		index := i
+		iconButton := NewIconButton(0, 0, &sdl.FRect{48, 128, 32, 32}, globals.GUITexture, false, func() { MapDrawingColor = index + 1 })
+		iconButton.BGIconSrc = &sdl.FRect{144, 96, 32, 32}
+		iconButton.Tint = color
+		row.Add("paletteColor"+strconv.Itoa(i), iconButton)
+	}
+
+}
+
+// refreshMapPaletteButtons rebuilds the map palette menu's color buttons, as the palette can change.
+func refreshMapPaletteButtons() {
+
+	paletteMenu := globals.MenuSystem.Get("map palette menu")
+	if paletteMenu == nil {
+		return
+	}
+
+	root := paletteMenu.Pages["root"]
+
+	insertAt := -1
+	rows := []*ContainerRow{}
+
+	for i, row := range root.Rows {
+		if row.FindElement("paletteColor", true) != nil {
+			if insertAt < 0 {
+				insertAt = i
+			}
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if insertAt < 0 {
+		return
+	}
+
+	root.Rows = rows
+	addMapPaletteColorRows(root)
+
+	colorRows := append([]*ContainerRow{}, root.Rows[len(rows):]...)
+	root.Rows = append(append(append([]*ContainerRow{}, rows[:insertAt]...), colorRows...), rows[insertAt:]...)
+
+}
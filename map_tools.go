@@ -0,0 +1,379 @@
+package main
+
+import (
+	"math"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// MapSelection is a rectangular region of a map layer selected with the selection tool. Once it's moved, rotated or
+// flipped, its contents are lifted off of the layer and "float" above what was underneath until it's deselected.
+type MapSelection struct {
+	Layer      *MapLayer
+	X, Y, W, H int
+	Data       [][]int // The selected values, once lifted
+	Under      [][]int // The values underneath the lifted selection
+	Lifted     bool
+	Moving     bool
+	MoveStart  Vector
+	MoveOrigin Vector
+}
+
+// mapShapeCells returns the cells covered by a rectangle or ellipse drawn with the given tool from start to end.
+func mapShapeCells(tool int, start, end Vector, filled bool) []Vector {
+
+	x0 := int(min(start.X, end.X))
+	y0 := int(min(start.Y, end.Y))
+	x1 := int(max(start.X, end.X))
+	y1 := int(max(start.Y, end.Y))
+
+	inside := func(x, y int) bool {
+
+		if x < x0 || x > x1 || y < y0 || y > y1 {
+			return false
+		}
+
+		if tool == MapEditToolEllipse {
+			// Test each cell's center against an ellipse that fills the bounding box
+			rx := float64(x1-x0)/2 + 0.5
+			ry := float64(y1-y0)/2 + 0.5
+			dx := (float64(x) - float64(x0+x1)/2) / rx
+			dy := (float64(y) - float64(y0+y1)/2) / ry
+			return dx*dx+dy*dy <= 1
+		}
+
+		return true
+
+	}
+
+	cells := []Vector{}
+
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if !inside(x, y) {
+				continue
+			}
+			if filled || !inside(x-1, y) || !inside(x+1, y) || !inside(x, y-1) || !inside(x, y+1) {
+				cells = append(cells, Vector{float32(x), float32(y)})
+			}
+		}
+	}
+
+	return cells
+
+}
+
+// updateShapeTool handles drawing rectangles and ellipses by dragging from one corner to the other; holding the filled
+// shape modifier fills them in, and the right mouse button erases instead.
+func (mc *MapContents) updateShapeTool(mp, gp Vector) bool {
+
+	leftMB := globals.Mouse.Button(sdl.BUTTON_LEFT)
+	rightMB := globals.Mouse.Button(sdl.BUTTON_RIGHT)
+
+	if mp.Inside(mc.Card.Rect) {
+
+		globals.Mouse.SetCursor(CursorPencil)
+
+		if leftMB.Pressed() || rightMB.Pressed() {
+			mc.ShapeStart = gp
+		}
+
+	}
+
+	if mc.ShapeStart.X < 0 || mc.ShapeStart.Y < 0 || !(leftMB.Released() || rightMB.Released()) {
+		return false
+	}
+
+	fill := mc.ColorIndex()
+	if rightMB.Released() {
+		fill = 0
+	}
+
+	for _, cell := range mapShapeCells(mc.Tool, mc.ShapeStart, gp, globals.Keybindings.Pressed(KBMapFilledShape)) {
+		mc.MapData.Set(cell, fill)
+	}
+
+	mc.ShapeStart.X = -1
+	mc.ShapeStart.Y = -1
+
+	return true
+
+}
+
+// updateSelectTool handles selecting a region by dragging, and moving the selection by dragging inside of it. Holding
+// the copy modifier while starting a move leaves the original values in place, and right-clicking inside the
+// selection clears it.
+func (mc *MapContents) updateSelectTool(mp, gp Vector) bool {
+
+	leftMB := globals.Mouse.Button(sdl.BUTTON_LEFT)
+	rightMB := globals.Mouse.Button(sdl.BUTTON_RIGHT)
+
+	sel := mc.Selection
+	changed := false
+
+	if mp.Inside(mc.Card.Rect) {
+
+		overSelection := sel != nil && int(gp.X) >= sel.X && int(gp.X) < sel.X+sel.W && int(gp.Y) >= sel.Y && int(gp.Y) < sel.Y+sel.H
+
+		if overSelection {
+			globals.Mouse.SetCursor(CursorHand)
+		}
+
+		if leftMB.Pressed() {
+
+			if overSelection {
+
+				if !sel.Lifted {
+					mc.liftSelection(globals.Keybindings.Pressed(KBMapCopySelection))
+				} else if globals.Keybindings.Pressed(KBMapCopySelection) {
+					// Stamp the selection down where it is, and carry on with a copy of it
+					sel.Under = mc.MapData.copyRegion(sel.Layer, sel.X, sel.Y, sel.W, sel.H)
+				}
+
+				sel.Moving = true
+				sel.MoveStart = gp
+				sel.MoveOrigin = Vector{float32(sel.X), float32(sel.Y)}
+
+			} else {
+				mc.Selection = nil
+				mc.ShapeStart = gp
+			}
+
+		} else if rightMB.Pressed() && overSelection {
+
+			if !sel.Lifted {
+				mc.liftSelection(false)
+			}
+
+			for y := range sel.Data {
+				for x := range sel.Data[y] {
+					sel.Data[y][x] = 0
+				}
+			}
+
+			mc.stampSelection()
+			changed = true
+
+		}
+
+	}
+
+	if sel != nil && sel.Moving {
+
+		offset := gp.Sub(sel.MoveStart)
+		x := int(sel.MoveOrigin.X + offset.X)
+		y := int(sel.MoveOrigin.Y + offset.Y)
+
+		if x != sel.X || y != sel.Y {
+			mc.unstampSelection()
+			sel.X = x
+			sel.Y = y
+			sel.Under = mc.MapData.copyRegion(sel.Layer, sel.X, sel.Y, sel.W, sel.H)
+			mc.stampSelection()
+			mc.UpdateTexture()
+		}
+
+		if leftMB.Released() {
+			sel.Moving = false
+			changed = true
+		}
+
+	} else if mc.ShapeStart.X >= 0 && mc.ShapeStart.Y >= 0 && leftMB.Released() {
+
+		mc.Selection = &MapSelection{
+			Layer: mc.MapData.Layer(),
+			X:     int(min(mc.ShapeStart.X, gp.X)),
+			Y:     int(min(mc.ShapeStart.Y, gp.Y)),
+			W:     int(math.Abs(float64(gp.X-mc.ShapeStart.X))) + 1,
+			H:     int(math.Abs(float64(gp.Y-mc.ShapeStart.Y))) + 1,
+		}
+
+		mc.ShapeStart.X = -1
+		mc.ShapeStart.Y = -1
+
+	}
+
+	return changed
+
+}
+
+func emptyMapGrid(w, h int) [][]int {
+	grid := make([][]int, h)
+	for y := range grid {
+		grid[y] = make([]int, w)
+	}
+	return grid
+}
+
+// copyRegion returns a copy of a rectangular region of the layer; cells outside of the map are 0.
+func (mapData *MapData) copyRegion(layer *MapLayer, x, y, w, h int) [][]int {
+
+	region := emptyMapGrid(w, h)
+
+	for ry := range region {
+		for rx := range region[ry] {
+			region[ry][rx] = max(0, mapData.LayerGetI(layer, x+rx, y+ry))
+		}
+	}
+
+	return region
+
+}
+
+// pasteRegion writes the region into the layer at the given position, skipping cells outside of the map.
+func (mapData *MapData) pasteRegion(layer *MapLayer, x, y int, region [][]int) {
+
+	for ry := range region {
+		for rx := range region[ry] {
+			if mapData.LayerGetI(layer, x+rx, y+ry) >= 0 {
+				layer.Data[y+ry][x+rx] = region[ry][rx]
+			}
+		}
+	}
+
+}
+
+// liftSelection picks the selected values up off of the layer so they can be moved around; if copy is false, the
+// cells they came from are cleared.
+func (mc *MapContents) liftSelection(copy bool) {
+
+	sel := mc.Selection
+
+	sel.Data = mc.MapData.copyRegion(sel.Layer, sel.X, sel.Y, sel.W, sel.H)
+
+	if copy {
+		sel.Under = mc.MapData.copyRegion(sel.Layer, sel.X, sel.Y, sel.W, sel.H)
+	} else {
+		sel.Under = emptyMapGrid(sel.W, sel.H)
+	}
+
+	sel.Lifted = true
+
+}
+
+// stampSelection draws the lifted selection onto the layer; empty cells let what's underneath show through.
+func (mc *MapContents) stampSelection() {
+
+	sel := mc.Selection
+
+	stamp := emptyMapGrid(sel.W, sel.H)
+
+	for y := range stamp {
+		for x := range stamp[y] {
+			stamp[y][x] = sel.Under[y][x]
+			if sel.Data[y][x] != 0 {
+				stamp[y][x] = sel.Data[y][x]
+			}
+		}
+	}
+
+	mc.MapData.pasteRegion(sel.Layer, sel.X, sel.Y, stamp)
+
+}
+
+// unstampSelection restores what was underneath the lifted selection.
+func (mc *MapContents) unstampSelection() {
+	mc.MapData.pasteRegion(mc.Selection.Layer, mc.Selection.X, mc.Selection.Y, mc.Selection.Under)
+}
+
+// transformSelection lifts the selection if necessary and replaces its contents with the result of the given grid
+// transformation, keeping its top-left corner in place.
+func (mc *MapContents) transformSelection(transform func(data [][]int) [][]int) {
+
+	sel := mc.Selection
+
+	if !sel.Lifted {
+		mc.liftSelection(false)
+	}
+
+	mc.unstampSelection()
+
+	sel.Data = transform(sel.Data)
+	sel.H = len(sel.Data)
+	sel.W = len(sel.Data[0])
+	sel.Under = mc.MapData.copyRegion(sel.Layer, sel.X, sel.Y, sel.W, sel.H)
+
+	mc.stampSelection()
+	mc.MapData.Commit()
+
+}
+
+// Rotate rotates the selection if there is one, or the whole map otherwise.
+func (mc *MapContents) Rotate(counterClockwise bool) {
+
+	if mc.Selection == nil {
+		mc.MapData.Rotate(counterClockwise)
+		return
+	}
+
+	mc.transformSelection(func(data [][]int) [][]int { return rotateMapGrid(data, counterClockwise) })
+
+	if counterClockwise {
+		globals.EventLog.Log("Selection rotated 90 degrees counter-clockwise.", false)
+	} else {
+		globals.EventLog.Log("Selection rotated 90 degrees clockwise.", false)
+	}
+
+}
+
+// Flip flips the selection if there is one, or the whole map otherwise.
+func (mc *MapContents) Flip(vertical bool) {
+
+	if mc.Selection == nil {
+		mc.MapData.Flip(vertical)
+		return
+	}
+
+	mc.transformSelection(func(data [][]int) [][]int { return flipMapGrid(data, vertical) })
+
+	if vertical {
+		globals.EventLog.Log("Selection flipped vertically.", false)
+	} else {
+		globals.EventLog.Log("Selection flipped horizontally.", false)
+	}
+
+}
+
+// drawGridRect outlines the given region of the map's grid.
+func (mc *MapContents) drawGridRect(x, y, w, h int, color Color) {
+
+	pos := globals.Project.Camera.UntranslatePoint(Vector{mc.Card.Rect.X + float32(x)*globals.GridSize, mc.Card.Rect.Y + float32(y)*globals.GridSize})
+	ThickRect(int32(pos.X), int32(pos.Y), int32(float32(w)*globals.GridSize), int32(float32(h)*globals.GridSize), 2, color)
+
+}
+
+// drawToolPreviews shows the shape being drawn, the selection being dragged out, or the current selection.
+func (mc *MapContents) drawToolPreviews() {
+
+	gp := mc.GridCursorPosition()
+	dragging := mc.ShapeStart.X >= 0 && mc.ShapeStart.Y >= 0
+
+	if dragging && (mc.Tool == MapEditToolRectangle || mc.Tool == MapEditToolEllipse) {
+
+		for _, cell := range mapShapeCells(mc.Tool, mc.ShapeStart, gp, globals.Keybindings.Pressed(KBMapFilledShape)) {
+			mc.drawGridRect(int(cell.X), int(cell.Y), 1, 1, NewColor(200, 220, 240, 255))
+		}
+
+	} else if dragging && mc.Tool == MapEditToolSelect {
+
+		x := int(min(mc.ShapeStart.X, gp.X))
+		y := int(min(mc.ShapeStart.Y, gp.Y))
+		w := int(math.Abs(float64(gp.X-mc.ShapeStart.X))) + 1
+		h := int(math.Abs(float64(gp.Y-mc.ShapeStart.Y))) + 1
+		mc.drawGridRect(x, y, w, h, NewColor(200, 220, 240, 255))
+
+	}
+
+	if sel := mc.Selection; sel != nil {
+
+		// Pulse the selection's outline so it stands out from the cursor
+		c := NewColor(200, 220, 240, 255)
+		if math.Sin(globals.Time*8) > 0 {
+			c = NewColor(60, 80, 120, 255)
+		}
+
+		mc.drawGridRect(sel.X, sel.Y, sel.W, sel.H, c)
+
+	}
+
+}
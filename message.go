@@ -8,6 +8,7 @@ const (
 	MessageCardDeleted                   = "MessageCardDeleted"
 	MessageCardRestored                  = "MessageCardRestored"
 	MessageCardMoveStack                 = "MessageCardMoveStack"
+	MessageCardMoved                     = "MessageCardMoved"
 	MessageCardDestroyed                 = "MessageCardDestroyed"
 	MessageContentSwitched               = "MessageContentSwitched"
 	MessageThemeChange                   = "MessageThemeChange"
@@ -23,6 +24,7 @@ const (
 	MessageProjectLoaded                 = "MessageProjectLoaded"
 	MessageProjectSaveInitiated          = "MessageProjectSaveInitiated"
 	MessageProjectSaveCompleted          = "MessageProjectSaveCompleted"
+	MessageResourceSniffed               = "MessageResourceSniffed"
 	// MessageCardDeserialized = "MessageCardDeserialized"
 )
 
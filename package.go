@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mholt/archives"
+	"github.com/ncruces/zenity"
+	"github.com/otiai10/copy"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const PackageFilesDirectory = "files"
+
+// PackageReport describes the results of packaging or importing a project bundle.
+type PackageReport struct {
+	ProjectPath string   // The path to the project file within the bundle
+	Copied      int      // How many files were copied into the bundle
+	Missing     []string // Files that were referenced by cards but couldn't be found
+}
+
+func (report *PackageReport) Log(action string) {
+
+	globals.EventLog.Log("%s %s (%d files included).", false, action, report.ProjectPath, report.Copied)
+
+	if len(report.Missing) > 0 {
+		globals.EventLog.Log("Warning: %d referenced files could not be found:\n%s", true, len(report.Missing), strings.Join(report.Missing, "\n"))
+	}
+
+}
+
+// isWebLink returns if the given card filepath points to an online resource rather than a file on disk.
+func isWebLink(fp string) bool {
+	parsed, err := url.Parse(fp)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https")
+}
+
+// Package bundles the project together with every file its cards reference into either a folder or a zip file at
+// the given destination, rewriting the cards' file paths to point to the bundled copies. Online resources are left
+// as links, and pasted images are already stored within the project file itself.
+func (project *Project) Package(destination string, asZip bool) (*PackageReport, error) {
+
+	name := "project"
+	if project.Filepath != "" {
+		name = strings.TrimSuffix(filepath.Base(project.Filepath), filepath.Ext(project.Filepath))
+	}
+
+	bundleDir := destination
+
+	if asZip {
+
+		stagingDir, err := os.MkdirTemp("", "masterplan_package")
+		if err != nil {
+			return nil, err
+		}
+
+		defer os.RemoveAll(stagingDir)
+
+		bundleDir = filepath.Join(stagingDir, name)
+
+	} else if entries, err := os.ReadDir(bundleDir); err == nil && len(entries) > 0 {
+		return nil, errors.New("destination folder isn't empty: " + bundleDir)
+	}
+
+	if err := os.MkdirAll(filepath.Join(bundleDir, PackageFilesDirectory), 0755); err != nil {
+		return nil, err
+	}
+
+	report := &PackageReport{
+		ProjectPath: filepath.Join(bundleDir, name+".plan"),
+	}
+
+	// Copy each referenced file into the bundle once, giving files that share a name unique ones
+	bundled := map[string]string{}
+	usedNames := map[string]bool{}
+
+	for _, page := range project.Pages {
+
+		for _, card := range page.Cards {
+
+			if !card.Valid {
+				continue
+			}
+
+			for _, file := range card.ReferencedFiles() {
+
+				original := file.Path
+
+				// Programs to run stay where they are, as they're tied to the computer running them
+				if file.Property == "run" || isWebLink(original) {
+					continue
+				}
+
+				if res, exists := globals.Resources[original]; exists && res.SaveFile {
+					continue
+				}
+
+				if _, done := bundled[original]; done {
+					continue
+				}
+
+				if !FileExists(original) {
+					report.Missing = append(report.Missing, original)
+					bundled[original] = ""
+					continue
+				}
+
+				ext := filepath.Ext(original)
+				base := strings.TrimSuffix(filepath.Base(original), ext)
+				bundledName := base + ext
+
+				for i := 2; usedNames[strings.ToLower(bundledName)]; i++ {
+					bundledName = base + "_" + strconv.Itoa(i) + ext
+				}
+
+				usedNames[strings.ToLower(bundledName)] = true
+
+				if err := copy.Copy(original, filepath.Join(bundleDir, PackageFilesDirectory, bundledName)); err != nil {
+					return nil, err
+				}
+
+				bundled[original] = PackageFilesDirectory + "/" + bundledName
+				report.Copied++
+
+			}
+
+		}
+
+	}
+
+	// Cards prepare for being saved when the save starts, so they need to be told when it's done, however it ends
+	project.SendMessage(NewMessage(MessageProjectSaveInitiated, nil, nil))
+	defer project.SendMessage(NewMessage(MessageProjectSaveCompleted, nil, nil))
+
+	// Serialize the project as though it were saved within the bundle, and then point its cards to the bundled files
+	originalPath := project.Filepath
+	project.Filepath = report.ProjectPath
+	saveData := project.Serialize()
+	project.Filepath = originalPath
+
+	// Directories on this computer won't exist wherever the bundle ends up
+	saveData, _ = sjson.Delete(saveData, "properties."+ProjectCacheDirectory)
+	saveData, _ = sjson.Delete(saveData, "properties."+ProjectBrowserProfileDirectory)
+
+	for pageIndex, page := range gjson.Get(saveData, "pages").Array() {
+
+		for cardIndex, card := range page.Get("cards").Array() {
+
+			for _, file := range SavedCardFiles(card) {
+
+				path := file.Path
+				if !filepath.IsAbs(path) {
+					path = filepath.Clean(filepath.Join(bundleDir, filepath.FromSlash(path)))
+				}
+
+				if newPath := bundled[path]; newPath != "" {
+					propPath := fmt.Sprintf("pages.%d.cards.%d.properties.%s", pageIndex, cardIndex, file.Property)
+					saveData, _ = sjson.Set(saveData, propPath, ReplaceFilePath(file.Property, gjson.Get(saveData, propPath).String(), file, newPath))
+				}
+
+			}
+
+		}
+
+	}
+
+	saveData = gjson.Get(saveData, "@pretty").String()
+
+	if err := os.WriteFile(report.ProjectPath, []byte(saveData), 0644); err != nil {
+		return nil, err
+	}
+
+	if asZip {
+
+		ctx := context.Background()
+
+		files, err := archives.FilesFromDisk(ctx, nil, map[string]string{bundleDir: name})
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := os.Create(destination)
+		if err != nil {
+			return nil, err
+		}
+
+		defer out.Close()
+
+		if err := (archives.Zip{}).Archive(ctx, out, files); err != nil {
+			return nil, err
+		}
+
+		report.ProjectPath = destination
+
+	}
+
+	return report, nil
+
+}
+
+// ImportPackage unpacks a zipped project bundle into a folder next to it (if it's zipped), and then checks the
+// bundle's project file for any referenced files that are missing. It returns the report, whose ProjectPath is the
+// project file to load.
+func ImportPackage(source string) (*PackageReport, error) {
+
+	report := &PackageReport{}
+
+	zipped := strings.ToLower(filepath.Ext(source)) == ".zip"
+
+	if zipped {
+
+		destDir := strings.TrimSuffix(source, filepath.Ext(source))
+
+		for i := 2; FolderExists(destDir) || FileExists(destDir); i++ {
+			destDir = strings.TrimSuffix(source, filepath.Ext(source)) + "_" + strconv.Itoa(i)
+		}
+
+		archive, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+
+		defer archive.Close()
+
+		err = (archives.Zip{}).Extract(context.Background(), archive, func(ctx context.Context, f archives.FileInfo) error {
+
+			target := filepath.Join(destDir, filepath.FromSlash(f.NameInArchive))
+
+			// Don't let a malformed archive write outside of the destination folder
+			if !strings.HasPrefix(target, filepath.Clean(destDir)+string(filepath.Separator)) {
+				return nil
+			}
+
+			if f.IsDir() {
+				return os.MkdirAll(target, 0755)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			if strings.ToLower(filepath.Ext(target)) == ".plan" && report.ProjectPath == "" {
+				report.ProjectPath = target
+			}
+
+			in, err := f.Open()
+			if err != nil {
+				return err
+			}
+
+			defer in.Close()
+
+			out, err := os.Create(target)
+			if err != nil {
+				return err
+			}
+
+			defer out.Close()
+
+			n, err := io.Copy(out, in)
+			if err == nil && n > 0 && !strings.EqualFold(filepath.Ext(target), ".plan") {
+				report.Copied++
+			}
+
+			return err
+
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if report.ProjectPath == "" {
+			return nil, errors.New("no project file found in " + source)
+		}
+
+	} else {
+		report.ProjectPath = source
+	}
+
+	data, err := os.ReadFile(report.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	projectDir := filepath.Dir(report.ProjectPath)
+
+	for _, page := range gjson.GetBytes(data, "pages").Array() {
+
+		for _, card := range page.Get("cards").Array() {
+
+			for _, file := range SavedCardFiles(card) {
+
+				fp := file.Path
+
+				if file.Property == "run" || isWebLink(fp) || gjson.GetBytes(data, "savedimages").Get(gjson.Escape(fp)).Exists() {
+					continue
+				}
+
+				path := fp
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(projectDir, filepath.FromSlash(path))
+				}
+
+				if !FileExists(path) {
+					report.Missing = append(report.Missing, fp)
+				} else if !zipped {
+					// Nothing was unpacked from a folder bundle, so count the files it already holds instead
+					report.Copied++
+				}
+
+			}
+
+		}
+
+	}
+
+	return report, nil
+
+}
+
+// PackageAs prompts for where to package the project, either as a zip file or into a folder.
+func (project *Project) PackageAs(asZip bool) {
+
+	var destination string
+	var err error
+
+	if asZip {
+
+		destination, err = zenity.SelectFileSave(zenity.Title("Package MasterPlan Project..."), zenity.ConfirmOverwrite(), zenity.FileFilter{Name: "Zip File (*.zip)", Patterns: []string{"*.zip"}})
+
+		if err == nil && strings.ToLower(filepath.Ext(destination)) != ".zip" {
+			destination += ".zip"
+		}
+
+	} else {
+		destination, err = zenity.SelectFile(zenity.Title("Select Empty Folder to Package MasterPlan Project Into..."), zenity.Directory())
+	}
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	report, err := project.Package(destination, asZip)
+	if err != nil {
+		globals.EventLog.Log("Error packaging project: %s", true, err.Error())
+		return
+	}
+
+	report.Log("Project packaged to")
+
+}
+
+// OpenPackage prompts for a packaged project to import, unpacking it and then asking to load it.
+func (project *Project) OpenPackage() {
+
+	source, err := zenity.SelectFile(zenity.Title("Select Packaged MasterPlan Project to Import..."), zenity.FileFilter{Name: "Packaged Project (*.zip / *.plan)", Patterns: []string{"*.zip", "*.plan"}})
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	report, err := ImportPackage(source)
+	if err != nil {
+		globals.EventLog.Log("Error importing packaged project: %s", true, err.Error())
+		return
+	}
+
+	report.Log("Packaged project imported to")
+
+	project.LoadConfirmationTo = report.ProjectPath
+	loadConfirm := globals.MenuSystem.Get("confirm load")
+	loadConfirm.Center()
+	loadConfirm.Open()
+
+}
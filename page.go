@@ -38,6 +38,13 @@ type Page struct {
 	DeserializationLinks []string
 
 	PointingSubpageCard *Card
+
+	Kanban           bool // Whether the Page is laid out as a Kanban board, with column header Cards
+	ArrangeKanban    bool
+	kanbanMembership map[*Card]*Card
+	kanbanDropped    map[*Card]bool
+
+	pastedLinks map[*Card]*Resource // Cards created for pasted links that are waiting to find out what the links point to
 }
 
 var globalPageID = uint64(0)
@@ -90,6 +97,15 @@ func (page *Page) Update() {
 		card.Update()
 	}
 
+	for card, resource := range page.pastedLinks {
+		if !card.Valid || card.ContentType != ContentTypeImage {
+			delete(page.pastedLinks, card)
+		} else if _, sniffed := resource.SniffedMimeType(); sniffed {
+			delete(page.pastedLinks, card)
+			card.ReceiveMessage(NewMessage(MessageResourceSniffed, card, nil))
+		}
+	}
+
 	if page.IsCurrent() {
 
 		// We only want to set the pan and zoom of a page if it's not loading the project (as it sets the page to be current to take screenshots for subpages).
@@ -98,6 +114,13 @@ func (page *Page) Update() {
 			page.Zoom = page.Project.Camera.Zoom
 		}
 
+		if page.ArrangeKanban {
+			if page.Kanban {
+				page.ArrangeKanbanColumns()
+			}
+			page.ArrangeKanban = false
+		}
+
 		if page.UpdateStacks {
 
 			// In this loop, the Stacks are subject to change.
@@ -259,6 +282,28 @@ func (page *Page) Name() string {
 	return "Root"
 }
 
+// CompletionTotals returns the completion and maximum completion levels of all completable Cards on the Page.
+// If includeSubpages is true, the totals of any Sub-Pages on the Page are added in as well.
+func (page *Page) CompletionTotals(includeSubpages bool) (float32, float32) {
+
+	completed, maximum := completionTotals(page.Cards)
+
+	if includeSubpages {
+
+		for _, card := range page.Cards {
+			if sub, ok := card.Contents.(*SubPageContents); ok && sub.SubPage != nil && sub.SubPage != page {
+				c, m := sub.SubPage.CompletionTotals(true)
+				completed += c
+				maximum += m
+			}
+		}
+
+	}
+
+	return completed, maximum
+
+}
+
 func (page *Page) Serialize() string {
 
 	pageData := "{}"
@@ -267,6 +312,10 @@ func (page *Page) Serialize() string {
 	pageData, _ = sjson.Set(pageData, "pan", page.Pan)
 	pageData, _ = sjson.Set(pageData, "zoom", page.Zoom)
 
+	if page.Kanban {
+		pageData, _ = sjson.Set(pageData, "kanban", true)
+	}
+
 	// Sort the cards by their position so the serialization is more stable. (Otherwise, clicking on
 	// a Card adjusts the sort order, and therefore the order in which Cards are serialized.)
 	cards := append([]*Card{}, page.Cards...)
@@ -299,6 +348,8 @@ func (page *Page) DeserializePageData(data string) {
 		page.Zoom = 1
 	}
 
+	page.Kanban = gjson.Get(data, "kanban").Bool()
+
 	if globalPageID < page.ID {
 		globalPageID = page.ID + 1
 	}
@@ -619,6 +670,41 @@ func (page *Page) HandleDroppedFiles(filePath string) {
 
 }
 
+// pastedLinkContentType returns the type of card to create for a pasted link to the given Resource, or an empty
+// string if it isn't a type of file that MasterPlan can display.
+func pastedLinkContentType(res *Resource) string {
+
+	if strings.Contains(res.MimeType, "image") || res.Extension == ".tga" || res.Extension == ".svg" {
+		return ContentTypeImage
+	} else if strings.Contains(res.MimeType, "audio") {
+		return ContentTypeSound
+	} else if strings.Contains(res.MimeType, "text") {
+		return ContentTypeNote
+	}
+
+	return ""
+
+}
+
+// loadPastedLink points the given card to the pasted link.
+func loadPastedLink(card *Card, link string) {
+
+	switch contents := card.Contents.(type) {
+
+	case *ImageContents:
+		contents.LoadFileFrom(link)
+
+	case *SoundContents:
+		contents.LoadFileFrom(link)
+
+	case *NoteContents:
+		// TODO: Fix this
+		contents.Label.SetText([]rune(link))
+
+	}
+
+}
+
 func (page *Page) HandleExternalPaste() {
 
 	if clipboardImg := clipboard.Read(clipboard.FmtImage); clipboardImg != nil {
@@ -641,33 +727,33 @@ func (page *Page) HandleExternalPaste() {
 
 		text := string(txt)
 
-		if res := globals.Resources.Get(text); res != nil && res.MimeType != "" {
+		if res := globals.Resources.Get(text); res != nil && res.DownloadError() == nil {
 
-			if strings.Contains(res.MimeType, "image") || res.Extension == ".tga" || res.Extension == ".svg" {
+			if _, sniffed := res.SniffedMimeType(); !sniffed {
 
+				// Until enough of the link has downloaded to tell what it is, it's shown as an image (which displays
+				// the download's progress)
 				card := page.CreateNewCard(ContentTypeImage)
 				card.Contents.(*ImageContents).LoadFileFrom(text)
 
-			} else if strings.Contains(res.MimeType, "audio") {
-
-				card := page.CreateNewCard(ContentTypeSound)
-				card.Contents.(*SoundContents).LoadFileFrom(text)
-
-			} else if strings.Contains(res.MimeType, "text") {
-
-				card := page.CreateNewCard(ContentTypeNote)
-				noteContents := card.Contents.(*NoteContents)
-				// noteContents.Label.Editing = true
+				if page.pastedLinks == nil {
+					page.pastedLinks = map[*Card]*Resource{}
+				}
 
-				// TODO: Fix this
-				noteContents.Label.SetText([]rune(text))
-				// noteContents.Label.Editing = false
-				// card.Properties.Get("description").Set(text)
+				page.pastedLinks[card] = res
 
+			} else if contentType := pastedLinkContentType(res); contentType != "" {
+				loadPastedLink(page.CreateNewCard(contentType), text)
 			} else {
 				globals.EventLog.Log("WARNING: Unsure of type of file at pasted link:\n%s\nNo card was created for this link.", true, text)
 			}
 
+		} else if table := page.SelectedTable(); table != nil && (strings.Contains(text, "\t") || strings.Contains(strings.TrimRight(text, "\r\n"), "\n")) {
+
+			// Tab-separated text (i.e. cells copied from a spreadsheet) goes into the selected table, as does text on
+			// several lines, which is what a single column of cells is copied as
+			table.TableData.PasteTSV(text)
+
 		} else {
 
 			text = strings.ReplaceAll(text, "\r\n", "\n")
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,7 +49,12 @@ const (
 
 	// Per-Project Properties
 
-	ProjectCacheDirectory = "CacheDirectory"
+	ProjectCacheDirectory          = "CacheDirectory"
+	ProjectProgressHistory         = "ProgressHistory"
+	ProjectBrowserProfileDirectory = "BrowserProfileDirectory"
+	ProjectMapPalette              = "MapPalette"
+
+	ProgressHistoryDateFormat = "2006-01-02"
 )
 
 type Project struct {
@@ -98,6 +104,7 @@ func NewProject() *Project {
 	project.CreateGridTexture()
 
 	project.Properties.Get(ProjectCacheDirectory).Set("")
+	project.Properties.Get(ProjectBrowserProfileDirectory).Set("")
 
 	globalCardID = 0
 
@@ -372,20 +379,54 @@ func (project *Project) Save() {
 		return
 	}
 
+	project.RecordProgressHistory()
+
+	saveData := project.Serialize()
+
+	if file, err := os.Create(project.Filepath); err != nil {
+		log.Println(err)
+	} else {
+		file.Write([]byte(saveData))
+		file.Close()
+		file.Sync() // Ensure the save file is written
+	}
+
+	if project.BackingUp {
+		globals.EventLog.Log("Project back-up successfully saved.", false)
+	} else {
+		globals.EventLog.Log("Project saved successfully.", false)
+
+		// Don't add backups to recent files list.
+		AddFileToRecentFilesList(project.Filepath)
+	}
+
+	project.SendMessage(NewMessage(MessageProjectSaveCompleted, nil, nil))
+
+	project.Modified = false
+
+}
+
+// Serialize returns the project's save data as JSON, with file paths relative to the project's Filepath.
+func (project *Project) Serialize() string {
+
 	saveData, _ := sjson.Set("{}", "version", globals.Version.String())
 
 	saveData, _ = sjson.Set(saveData, "pan", project.Camera.TargetPosition)
 	saveData, _ = sjson.Set(saveData, "zoom", project.Camera.TargetZoom)
 	saveData, _ = sjson.Set(saveData, "currentPage", project.CurrentPage.ID)
 
-	if cache := project.Properties.Get(ProjectCacheDirectory); cache.AsString() != "" {
-		cache.Set(project.PathToRelative(cache.AsString(), true))
+	for _, dirProp := range []string{ProjectCacheDirectory, ProjectBrowserProfileDirectory} {
+		if dir := project.Properties.Get(dirProp); dir.AsString() != "" {
+			dir.Set(project.PathToRelative(dir.AsString(), true))
+		}
 	}
 
 	saveData, _ = sjson.SetRaw(saveData, "properties", project.Properties.Serialize(true))
 
-	if cache := project.Properties.Get(ProjectCacheDirectory); cache.AsString() != "" {
-		cache.Set(project.PathToAbsolute(cache.AsString(), true))
+	for _, dirProp := range []string{ProjectCacheDirectory, ProjectBrowserProfileDirectory} {
+		if dir := project.Properties.Get(dirProp); dir.AsString() != "" {
+			dir.Set(project.PathToAbsolute(dir.AsString(), true))
+		}
 	}
 
 	savedImages := map[string]string{}
@@ -439,6 +480,7 @@ func (project *Project) Save() {
 		converted := []convertedFilepath{}
 
 		for _, card := range page.Cards {
+			card.RecordFileFingerprints()
 			if fp := card.Properties.GetIfExists("filepath"); fp != nil && (globals.Resources.Get(fp.AsString()) == nil || !globals.Resources.Get(fp.AsString()).SaveFile) && FileExists(fp.AsString()) {
 				converted = append(converted, convertedFilepath{Original: fp.AsString(), PropName: "filepath", Card: card})
 				fp.Set(project.PathToRelative(fp.AsString(), false))
@@ -447,6 +489,10 @@ func (project *Project) Save() {
 				converted = append(converted, convertedFilepath{Original: run.AsString(), PropName: "run", Card: card})
 				run.Set(project.PathToRelative(run.AsString(), false))
 			}
+			if playlist := card.Properties.GetIfExists("playlist"); playlist != nil && playlist.IsString() && playlist.AsString() != "" {
+				converted = append(converted, convertedFilepath{Original: playlist.AsString(), PropName: "playlist", Card: card})
+				playlist.Set(project.PlaylistPaths(playlist.AsString(), true))
+			}
 		}
 
 		pageData += page.Serialize()
@@ -497,26 +543,73 @@ func (project *Project) Save() {
 
 	saveData = gjson.Get(saveData, "@pretty").String()
 
-	if file, err := os.Create(project.Filepath); err != nil {
-		log.Println(err)
-	} else {
-		file.Write([]byte(saveData))
-		file.Close()
-		file.Sync() // Ensure the save file is written
+	return saveData
+
+}
+
+// RecordProgressHistory samples the current completion totals of each valid Page (including its Sub-Pages) and stores
+// them in the project's progress history under today's date, overwriting any earlier sample from the same day.
+func (project *Project) RecordProgressHistory() {
+
+	history := project.Properties.Get(ProjectProgressHistory)
+
+	data := "{}"
+	if history.IsString() && history.AsString() != "" {
+		data = history.AsString()
 	}
 
-	if project.BackingUp {
-		globals.EventLog.Log("Project back-up successfully saved.", false)
-	} else {
-		globals.EventLog.Log("Project saved successfully.", false)
+	today := time.Now().Format(ProgressHistoryDateFormat)
+
+	for _, page := range project.Pages {
+
+		if !page.Valid() {
+			continue
+		}
+
+		completed, maximum := page.CompletionTotals(true)
+		data, _ = sjson.Set(data, strconv.FormatUint(page.ID, 10)+"."+today, []float32{completed, maximum})
 
-		// Don't add backups to recent files list.
-		AddFileToRecentFilesList(project.Filepath)
 	}
 
-	project.SendMessage(NewMessage(MessageProjectSaveCompleted, nil, nil))
+	history.Set(data)
 
-	project.Modified = false
+}
+
+// ProgressSample is a single day's entry in a Page's progress history.
+type ProgressSample struct {
+	Date      time.Time
+	Completed float32
+	Maximum   float32
+}
+
+// ProgressHistory returns the recorded progress samples for the given Page, sorted by date.
+func (project *Project) ProgressHistory(page *Page) []ProgressSample {
+
+	samples := []ProgressSample{}
+
+	history := project.Properties.Get(ProjectProgressHistory)
+
+	if !history.IsString() || history.AsString() == "" {
+		return samples
+	}
+
+	history.AsJSON().Get(strconv.FormatUint(page.ID, 10)).ForEach(func(key, value gjson.Result) bool {
+		if date, err := time.Parse(ProgressHistoryDateFormat, key.String()); err == nil {
+			values := value.Array()
+			if len(values) >= 2 {
+				samples = append(samples, ProgressSample{
+					Date:      date,
+					Completed: float32(values[0].Float()),
+					Maximum:   float32(values[1].Float()),
+				})
+			}
+		}
+		return true
+	})
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Date.Before(samples[j].Date) })
+
+	return samples
 
 }
 
@@ -915,6 +1008,10 @@ func OpenProjectFrom(filename string) {
 				cache.Set(newProject.PathToAbsolute(cache.AsString(), true))
 			}
 
+			if profile := newProject.Properties.Get(ProjectBrowserProfileDirectory); profile.AsString() != "" {
+				profile.Set(newProject.PathToAbsolute(profile.AsString(), true))
+			}
+
 			for fpName, imgData := range gjson.Get(json, "savedimages").Map() {
 
 				imgOut := []byte{}
@@ -1216,7 +1313,7 @@ func (project *Project) GlobalShortcuts() {
 
 	}
 
-	if globals.State == StateNeutral || globals.State == StateMapEditing || globals.State == StateCardArrow || globals.State == StateCardLink {
+	if globals.State == StateNeutral || globals.State == StateCardToolEditing || globals.State == StateCardArrow || globals.State == StateCardLink {
 
 		dx := float32(0)
 		dy := float32(0)
@@ -1351,7 +1448,7 @@ func (project *Project) GlobalShortcuts() {
 
 	}
 
-	if kb.Pressed(KBNewCardOfPrevType) && (globals.State == StateNeutral || globals.State == StateMapEditing || globals.State == StateTextEditing) {
+	if kb.Pressed(KBNewCardOfPrevType) && (globals.State == StateNeutral || globals.State == StateCardToolEditing || globals.State == StateTextEditing) {
 		newCard := project.CurrentPage.CreateNewCard(project.LastCardType)
 		kb.Shortcuts[KBNewCardOfPrevType].ConsumeKeys()
 		placeCardInStack(newCard, false)
@@ -1368,7 +1465,7 @@ func (project *Project) GlobalShortcuts() {
 		newCard.Update()
 	}
 
-	if globals.State == StateNeutral || globals.State == StateMapEditing || globals.State == StateCardArrow {
+	if globals.State == StateNeutral || globals.State == StateCardToolEditing || globals.State == StateCardArrow {
 
 		kb := globals.Keybindings
 
@@ -1658,6 +1755,22 @@ func (project *Project) SetPage(page *Page) {
 
 }
 
+// BrowserProfileDirectory returns the browser user-data directory for web cards using a per-project (or per-card)
+// profile. If it hasn't been set, it defaults to a folder next to the project file.
+func (project *Project) BrowserProfileDirectory() string {
+
+	if dir := project.Properties.Get(ProjectBrowserProfileDirectory).AsString(); dir != "" {
+		return dir
+	}
+
+	if project.Filepath != "" {
+		return strings.TrimSuffix(project.Filepath, filepath.Ext(project.Filepath)) + "_browser_profile"
+	}
+
+	return filepath.Join(os.TempDir(), "masterplan", "browser_profile")
+
+}
+
 func (project *Project) PathToRelative(fp string, directory bool) string {
 
 	var exists bool
@@ -122,7 +122,10 @@ func (prop *Property) SetInts(values ...int64) {
 
 	jsonStr := "["
 
-	for _, v := range values {
+	for i, v := range values {
+		if i > 0 {
+			jsonStr += ","
+		}
 		jsonStr += strconv.Itoa(int(v))
 	}
 
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// The card properties that point to files on disk that can go missing.
+var RelinkableProperties = []string{"filepath", "run"}
+
+// The card properties that hold JSON lists of files, like a Sound card's playlist.
+var FileListProperties = []string{"playlist"}
+
+func isFileListProperty(propName string) bool {
+	for _, name := range FileListProperties {
+		if name == propName {
+			return true
+		}
+	}
+	return false
+}
+
+// CardFile is a file that a card points to, and the property that points to it.
+type CardFile struct {
+	Property string
+	Path     string
+}
+
+// ReferencedFiles returns every file the card's properties point to, including each of the files in list properties.
+func (card *Card) ReferencedFiles() []CardFile {
+
+	files := []CardFile{}
+
+	for _, propName := range RelinkableProperties {
+		if prop := card.Properties.GetIfExists(propName); prop != nil && prop.IsString() && strings.TrimSpace(prop.AsString()) != "" {
+			files = append(files, CardFile{Property: propName, Path: prop.AsString()})
+		}
+	}
+
+	for _, propName := range FileListProperties {
+		if prop := card.Properties.GetIfExists(propName); prop != nil && prop.IsString() {
+			for _, path := range gjson.Parse(prop.AsString()).Array() {
+				if strings.TrimSpace(path.String()) != "" {
+					files = append(files, CardFile{Property: propName, Path: path.String()})
+				}
+			}
+		}
+	}
+
+	return files
+
+}
+
+// SavedCardFiles is ReferencedFiles for a card in a project's save data.
+func SavedCardFiles(card gjson.Result) []CardFile {
+
+	files := []CardFile{}
+
+	for _, propName := range RelinkableProperties {
+		if path := card.Get("properties." + propName).String(); strings.TrimSpace(path) != "" {
+			files = append(files, CardFile{Property: propName, Path: path})
+		}
+	}
+
+	for _, propName := range FileListProperties {
+		for _, path := range gjson.Parse(card.Get("properties." + propName).String()).Array() {
+			if strings.TrimSpace(path.String()) != "" {
+				files = append(files, CardFile{Property: propName, Path: path.String()})
+			}
+		}
+	}
+
+	return files
+
+}
+
+// ReplaceFilePath returns the property's new value (as set with SetRaw) after swapping one of the files it points to
+// for another; list properties only have the matching entry replaced.
+func ReplaceFilePath(propName, value string, file CardFile, newPath string) string {
+
+	if !isFileListProperty(propName) {
+		return newPath
+	}
+
+	paths := []string{}
+	json.Unmarshal([]byte(value), &paths)
+
+	for i, path := range paths {
+		if path == file.Path {
+			paths[i] = newPath
+		}
+	}
+
+	data, _ := json.Marshal(paths)
+	return string(data)
+
+}
+
+// cardFileFingerprint returns the fingerprint recorded for one of the files the card points to. List properties keep
+// theirs as a JSON object of paths to fingerprints.
+func (card *Card) cardFileFingerprint(file CardFile) string {
+
+	prop := card.Properties.GetIfExists(fingerprintProperty(file.Property))
+
+	if prop == nil || !prop.IsString() {
+		return ""
+	}
+
+	if !isFileListProperty(file.Property) {
+		return prop.AsString()
+	}
+
+	fingerprints := map[string]string{}
+	json.Unmarshal([]byte(prop.AsString()), &fingerprints)
+	return fingerprints[file.Path]
+
+}
+
+func (card *Card) setCardFileFingerprint(file CardFile, fingerprint string) {
+
+	prop := card.Properties.Get(fingerprintProperty(file.Property))
+	prop.OnlySerializeInSaves = true
+
+	if !isFileListProperty(file.Property) {
+		prop.SetRaw(fingerprint)
+		return
+	}
+
+	fingerprints := map[string]string{}
+	if prop.IsString() {
+		json.Unmarshal([]byte(prop.AsString()), &fingerprints)
+	}
+
+	fingerprints[file.Path] = fingerprint
+
+	data, _ := json.Marshal(fingerprints)
+	prop.SetRaw(string(data))
+
+}
+
+const fileFingerprintSampleSize = 64 * 1024
+
+// fingerprintProperty returns the name of the property used to store the fingerprint of the file a card property
+// points to (i.e. "filepath fingerprint").
+func fingerprintProperty(propName string) string {
+	return propName + " fingerprint"
+}
+
+type fileFingerprintCacheEntry struct {
+	ModTime     time.Time
+	Size        int64
+	Fingerprint string
+}
+
+var fileFingerprintCache = map[string]fileFingerprintCacheEntry{}
+
+// FileFingerprint returns a quick fingerprint of a file's contents: its size and a hash of its start and end. It's
+// used to find files that have been moved or renamed. An empty string is returned if the file can't be read.
+func FileFingerprint(path string) string {
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return ""
+	}
+
+	if cached, exists := fileFingerprintCache[path]; exists && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		return cached.Fingerprint
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+
+	defer file.Close()
+
+	hash := sha1.New()
+
+	if _, err := io.CopyN(hash, file, fileFingerprintSampleSize); err != nil && err != io.EOF {
+		return ""
+	}
+
+	if info.Size() > fileFingerprintSampleSize*2 {
+
+		if _, err := file.Seek(-fileFingerprintSampleSize, io.SeekEnd); err != nil {
+			return ""
+		}
+
+		if _, err := io.Copy(hash, file); err != nil {
+			return ""
+		}
+
+	}
+
+	fingerprint := strconv.FormatInt(info.Size(), 10) + "-" + hex.EncodeToString(hash.Sum(nil))
+
+	fileFingerprintCache[path] = fileFingerprintCacheEntry{
+		ModTime:     info.ModTime(),
+		Size:        info.Size(),
+		Fingerprint: fingerprint,
+	}
+
+	return fingerprint
+
+}
+
+// fingerprintSize returns the file size stored in a fingerprint, or -1 if it's invalid.
+func fingerprintSize(fingerprint string) int64 {
+
+	sizeString, _, found := strings.Cut(fingerprint, "-")
+	if !found {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(sizeString, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return size
+
+}
+
+// RecordFileFingerprints stores the fingerprint of each file the card points to, so it can be found again if it's
+// moved or renamed. Fingerprints of files that are already missing are left alone.
+func (card *Card) RecordFileFingerprints() {
+
+	for _, file := range card.ReferencedFiles() {
+
+		if !FileExists(file.Path) {
+			continue
+		}
+
+		if res, exists := globals.Resources[file.Path]; exists && res.SaveFile {
+			continue
+		}
+
+		if fingerprint := FileFingerprint(file.Path); fingerprint != "" {
+			card.setCardFileFingerprint(file, fingerprint)
+		}
+
+	}
+
+}
+
+// MissingFile is a file that a card property points to, but which no longer exists.
+type MissingFile struct {
+	Path        string
+	Property    string
+	Fingerprint string
+	Cards       []*Card
+}
+
+// MissingFiles returns every file that the project's cards point to that no longer exists, grouped by path.
+func (project *Project) MissingFiles() []*MissingFile {
+
+	missing := []*MissingFile{}
+	byPath := map[string]*MissingFile{}
+
+	for _, page := range project.Pages {
+
+		for _, card := range page.Cards {
+
+			if !card.Valid {
+				continue
+			}
+
+			for _, file := range card.ReferencedFiles() {
+
+				propName := file.Property
+				path := strings.TrimSpace(file.Path)
+
+				// Programs to run can be commands on the PATH rather than files
+				if isWebLink(path) || (propName == "run" && !strings.ContainsAny(path, `/\`)) {
+					continue
+				}
+
+				if res, exists := globals.Resources[path]; exists && res.SaveFile {
+					continue
+				}
+
+				if FileExists(path) || FolderExists(path) {
+					continue
+				}
+
+				key := propName + ":" + path
+
+				if _, exists := byPath[key]; !exists {
+					byPath[key] = &MissingFile{Path: path, Property: propName}
+					missing = append(missing, byPath[key])
+				}
+
+				entry := byPath[key]
+				entry.Cards = append(entry.Cards, card)
+
+				if fp := card.cardFileFingerprint(file); fp != "" && entry.Fingerprint == "" {
+					entry.Fingerprint = fp
+				}
+
+			}
+
+		}
+
+	}
+
+	return missing
+
+}
+
+// FindMissingFiles searches the given folder (and its subfolders) for the missing files, first by fingerprint (so
+// renamed files are found), and then by name. It returns the new path for each missing file that was found.
+func FindMissingFiles(missing []*MissingFile, folder string) (map[*MissingFile]string, error) {
+
+	byName := map[string][]string{}
+	bySize := map[int64][]string{}
+
+	sizes := map[int64]bool{}
+	for _, m := range missing {
+		if size := fingerprintSize(m.Fingerprint); size >= 0 {
+			sizes[size] = true
+		}
+	}
+
+	err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+
+		// Skip folders that can't be read rather than giving up on the search
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		name := strings.ToLower(d.Name())
+		byName[name] = append(byName[name], path)
+
+		if info, err := d.Info(); err == nil && sizes[info.Size()] {
+			bySize[info.Size()] = append(bySize[info.Size()], path)
+		}
+
+		return nil
+
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[*MissingFile]string{}
+
+	for _, m := range missing {
+
+		if m.Fingerprint != "" {
+
+			for _, candidate := range bySize[fingerprintSize(m.Fingerprint)] {
+				if FileFingerprint(candidate) == m.Fingerprint {
+					found[m] = candidate
+					break
+				}
+			}
+
+			if _, ok := found[m]; ok {
+				continue
+			}
+
+		}
+
+		// If there are several files with the same name, prefer the one whose path looks the most like the original's
+		candidates := byName[strings.ToLower(filepath.Base(filepath.FromSlash(m.Path)))]
+		best := -1
+
+		for _, candidate := range candidates {
+
+			if score := sharedPathSuffix(m.Path, candidate); score > best {
+				best = score
+				found[m] = candidate
+			}
+
+		}
+
+	}
+
+	return found, nil
+
+}
+
+// sharedPathSuffix returns how many trailing path elements the two paths have in common.
+func sharedPathSuffix(a, b string) int {
+
+	aParts := strings.Split(filepath.ToSlash(a), "/")
+	bParts := strings.Split(filepath.ToSlash(b), "/")
+
+	count := 0
+
+	for count < len(aParts) && count < len(bParts) && strings.EqualFold(aParts[len(aParts)-1-count], bParts[len(bParts)-1-count]) {
+		count++
+	}
+
+	return count
+
+}
+
+// Relink points every card using the missing files to their new paths. All changed cards are captured in the same
+// undo frame, so relinking can be undone in one step.
+func (project *Project) Relink(found map[*MissingFile]string) int {
+
+	relinked := 0
+
+	for missing, newPath := range found {
+
+		for _, card := range missing.Cards {
+
+			prop := card.Properties.Get(missing.Property)
+			prop.SetRaw(ReplaceFilePath(missing.Property, prop.AsString(), CardFile{Property: missing.Property, Path: missing.Path}, newPath))
+
+			if fp := FileFingerprint(newPath); fp != "" {
+				card.setCardFileFingerprint(CardFile{Property: missing.Property, Path: newPath}, fp)
+			}
+
+			if loader, ok := card.Contents.(ResourceLoader); ok {
+				loader.LoadFile()
+			}
+
+			project.UndoHistory.Capture(NewUndoState(card))
+			card.CreateUndoState = false
+
+			relinked++
+
+		}
+
+	}
+
+	if relinked > 0 {
+		project.SetModifiedState()
+	}
+
+	return relinked
+
+}
+
+func (missing *MissingFile) String() string {
+
+	cards := "1 card"
+	if len(missing.Cards) != 1 {
+		cards = fmt.Sprintf("%d cards", len(missing.Cards))
+	}
+
+	return fmt.Sprintf("%s (%s, %s)", missing.Path, missing.Property, cards)
+
+}
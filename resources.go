@@ -1,26 +1,26 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"image/gif"
 	"log"
 	"math"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Zyko0/go-sdl3/img"
 	"github.com/Zyko0/go-sdl3/sdl"
-	"github.com/cavaliergopher/grab/v3"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/gopxl/beep/v2"
 	"github.com/gopxl/beep/v2/flac"
 	"github.com/gopxl/beep/v2/mp3"
 	"github.com/gopxl/beep/v2/vorbis"
 	"github.com/gopxl/beep/v2/wav"
+	"github.com/otiai10/copy"
 )
 
 type ResourceBank map[string]*Resource
@@ -50,6 +50,60 @@ func (resourceBank ResourceBank) Get(resourcePath string) *Resource {
 
 }
 
+// Redownload deletes the given downloaded Resource and downloads it again, reloading it for any cards that use it.
+// This is also how failed downloads are retried.
+func (resourceBank ResourceBank) Redownload(resource *Resource) error {
+
+	if resource.OriginURL == "" {
+		return errors.New("resource wasn't downloaded")
+	}
+
+	resource.Destroy()
+	os.Remove(resource.LocalFilepath)
+	delete(resourceBank, resource.Name)
+
+	if resourceBank.Get(resource.Name) == nil {
+		return errors.New("couldn't download " + resource.OriginURL)
+	}
+
+	for _, card := range resource.Users(globals.Project) {
+		if loader, ok := card.Contents.(ResourceLoader); ok {
+			loader.LoadFile()
+		}
+	}
+
+	return nil
+
+}
+
+// PurgeUnused unloads every project Resource that no card in the given project uses, deleting the files of those that
+// were downloaded. It returns how many Resources were purged and how many bytes of files were deleted.
+func (resourceBank ResourceBank) PurgeUnused(project *Project) (int, int64) {
+
+	count := 0
+	freed := int64(0)
+
+	for resourceName, resource := range resourceBank {
+
+		if !resource.Destructible || resource.IsAsset() || resource.SaveFile || len(resource.Users(project)) > 0 {
+			continue
+		}
+
+		if resource.OriginURL != "" {
+			freed += resource.FileSize()
+			resource.TempFile = true // Downloaded files are deleted on destruction
+		}
+
+		resource.Destroy()
+		delete(resourceBank, resourceName)
+		count++
+
+	}
+
+	return count, freed
+
+}
+
 func (resourceBank ResourceBank) Destroy() {
 
 	for resourceName, resource := range resourceBank {
@@ -67,11 +121,15 @@ type Resource struct {
 	Extension     string
 	Data          interface{} // The data the resource represents; this might be an image, a sound stream, etc.
 	MimeType      string
-	Response      *grab.Response
-	TempFile      bool // Whether the file is temporary (and so should be deleted after MasterPlan closes) - downloaded images, for example, are temporary
-	SaveFile      bool // Whether the file should be saved along with the project - pasted screenshots, as an example, are saved
+	Download      *Download
+	OriginURL     string // The URL the Resource was downloaded from; empty for offline files
+	TempFile      bool   // Whether the file is temporary (and so should be deleted after MasterPlan closes) - downloaded images, for example, are temporary
+	SaveFile      bool   // Whether the file should be saved along with the project - pasted screenshots, as an example, are saved
 	Parsed        bool
 	Destructible  bool // System resources aren't able to be deleted
+
+	waveform      *SoundWaveform // Cached for audio files; see Resource.Waveform()
+	waveformMutex sync.Mutex
 }
 
 func NewResource(resourcePath string) (*Resource, error) {
@@ -105,47 +163,43 @@ func NewResource(resourcePath string) (*Resource, error) {
 			// It's online, so we don't need to save it
 		}
 
-		if req, err := grab.NewRequest("", resourcePath); err != nil {
+		parsed, err := url.Parse(resourcePath)
+		if err != nil {
 			return nil, err
-		} else {
-			unescapedPath, _ := url.QueryUnescape(req.URL().Path)
-			req.Filename = filepath.Join(destDir, filepath.FromSlash(req.URL().Hostname()+"/"+unescapedPath))
-
-			// It's already been downloaded
-			if FileExists(req.Filename) {
-				resource.LocalFilepath = req.Filename
-				resource.Extension = filepath.Ext(resource.LocalFilepath)
-				resource.Parse()
-				return resource, nil
-			}
-
-			resource.LocalFilepath = req.Filename
-			resource.Extension = filepath.Ext(resourcePath)
-			resource.Response = globals.GrabClient.Do(req) // This can take time, up to and including seconds to execute
-
-			if resource.Response.IsComplete() && resource.Response.Err() != nil {
-				return nil, resource.Response.Err()
-			} else if site, err := http.Get(resourcePath); err == nil {
-
-				// We're going to get what the Mime data is by downloading the first 5kb of a link, and then passing
-				// it into mimetype.Detect().
-
-				r := bufio.NewScanner(site.Body)
-				r.Buffer([]byte{}, 5000)
-				r.Scan()
+		}
 
-				resource.MimeType = mimetype.Detect(r.Bytes()).String()
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return nil, errors.New("not a file or web link: " + resourcePath)
+		}
 
-			}
+		resource.OriginURL = resourcePath
+		resource.LocalFilepath = resourceCachePath(destDir, parsed)
 
+		// It's already been downloaded
+		if FileExists(resource.LocalFilepath) {
+			resource.Extension = filepath.Ext(resource.LocalFilepath)
+			resource.Parse()
+			return resource, nil
 		}
 
+		resource.Extension = filepath.Ext(resourcePath)
+		resource.Download = globals.Downloads.Enqueue(resourcePath, resource.LocalFilepath)
+
 	}
 
 	return resource, nil
 
 }
 
+// resourceCachePath returns where a Resource downloaded from the given URL is stored within the given cache directory.
+func resourceCachePath(cacheDir string, u *url.URL) string {
+	unescapedPath, _ := url.QueryUnescape(u.Path)
+	if unescapedPath == "" || strings.HasSuffix(unescapedPath, "/") {
+		unescapedPath += "index"
+	}
+	return filepath.Join(cacheDir, filepath.FromSlash(u.Hostname()+"/"+unescapedPath))
+}
+
 func (resource *Resource) Parse() {
 
 	// If the resource has already been parsed, then we can just skip it
@@ -301,20 +355,41 @@ func (resource *Resource) Parse() {
 // DownloadPercentage returns 0-1 as the Resource downloads, until it's finished downloading. Sometimes the download percentage is -1
 // for some things (gifer does this, for example).
 func (resource *Resource) DownloadPercentage() float64 {
-	if resource.Response == nil {
-		return 1
-	} else if resource.Response.Size() > 0 {
-		return resource.Response.Progress()
-	} else if resource.Response.IsComplete() {
+	if resource.Download == nil {
 		return 1
 	}
+	return resource.Download.Progress()
+}
+
+func (resource *Resource) FinishedDownloading() bool {
+	return resource.Download == nil || resource.Download.Succeeded()
+}
 
-	return -1
+// DownloadError returns why the Resource failed to download, or nil if it hasn't failed.
+func (resource *Resource) DownloadError() error {
+	if resource.Download == nil || !resource.Download.IsComplete() {
+		return nil
+	}
+	return resource.Download.Err()
+}
 
+// SniffedMimeType returns the Resource's MIME type and true once enough of it has downloaded to tell what it is (the
+// MIME type is empty if the download failed), or false if it's still too early to tell.
+func (resource *Resource) SniffedMimeType() (string, bool) {
+	if resource.MimeType == "" && resource.Download != nil {
+		if !resource.Download.Sniffed() {
+			return "", false
+		}
+		resource.MimeType = resource.Download.MimeType(0)
+	}
+	return resource.MimeType, true
 }
 
-func (resource *Resource) FinishedDownloading() bool {
-	return resource.Response == nil || resource.DownloadPercentage() >= 1
+// CancelUnusedDownload stops downloading the Resource if no cards in the given project use it any longer.
+func (resource *Resource) CancelUnusedDownload(project *Project) {
+	if resource.Download != nil && !resource.Download.IsComplete() && len(resource.Users(project)) == 0 {
+		resource.Download.Cancel()
+	}
 }
 
 func (resource *Resource) IsTexture() bool {
@@ -361,36 +436,138 @@ func (resource *Resource) AsNewSound(limitPlayback bool, channel int) (*Sound, e
 		}
 	}
 
-	originalFile, err := os.Open(resource.LocalFilepath)
+	originalStream, format, err := resource.decodeSound()
 	if err != nil {
 		return nil, err
 	}
 
-	var originalStream beep.StreamSeekCloser
-	var format beep.Format
+	s := NewSound(originalStream, format, channel)
+	s.filepath = resource.LocalFilepath
+	s.limitPlayback = limitPlayback
+	return s, nil
+}
+
+// decodeSound opens a new stream of the Resource's audio file.
+func (resource *Resource) decodeSound() (beep.StreamSeekCloser, beep.Format, error) {
+
+	originalFile, err := os.Open(resource.LocalFilepath)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
 
 	if resource.MimeType == "audio/mpeg" {
-		originalStream, format, err = mp3.Decode(originalFile)
+		return mp3.Decode(originalFile)
 	} else if resource.MimeType == "audio/wav" {
-		originalStream, format, err = wav.Decode(originalFile)
+		return wav.Decode(originalFile)
 	} else if resource.MimeType == "audio/flac" {
-		originalStream, format, err = flac.Decode(originalFile)
+		return flac.Decode(originalFile)
 	} else if strings.Contains(resource.MimeType, "ogg") {
-		originalStream, format, err = vorbis.Decode(originalFile)
+		return vorbis.Decode(originalFile)
+	}
+
+	originalFile.Close()
+	return nil, beep.Format{}, errors.New("unsupported audio format: " + resource.MimeType)
+
+}
+
+// IsAsset returns if the Resource is one of MasterPlan's own files, rather than one used by a project.
+func (resource *Resource) IsAsset() bool {
+	return strings.HasPrefix(resource.LocalFilepath, LocalRelativePath("assets"))
+}
+
+// FileSize returns the size of the Resource's file on disk in bytes, or how much of it has been downloaded so far.
+func (resource *Resource) FileSize() int64 {
+
+	if resource.Download != nil && !resource.Download.IsComplete() {
+		return resource.Download.BytesComplete()
+	}
+
+	if info, err := os.Stat(resource.LocalFilepath); err == nil {
+		return info.Size()
+	}
+
+	return 0
+
+}
+
+// Users returns the cards in the given project that make use of the Resource.
+func (resource *Resource) Users(project *Project) []*Card {
+
+	users := []*Card{}
+
+	for _, page := range project.Pages {
+
+		for _, card := range page.Cards {
+
+			if !card.Valid {
+				continue
+			}
+
+			for _, file := range card.ReferencedFiles() {
+				if file.Path == resource.Name {
+					users = append(users, card)
+					break
+				}
+			}
+
+		}
+
 	}
 
+	return users
+
+}
+
+// Localize moves a downloaded Resource into the given project's cache directory so that it's kept around (rather
+// than being deleted when MasterPlan closes) and doesn't need to be downloaded again.
+func (resource *Resource) Localize(project *Project) error {
+
+	if resource.OriginURL == "" {
+		return errors.New("resource wasn't downloaded")
+	}
+
+	if !resource.FinishedDownloading() {
+		return errors.New("resource hasn't finished downloading")
+	}
+
+	cacheDir := project.Properties.Get(ProjectCacheDirectory).AsString()
+
+	if cacheDir == "" || !FolderExists(cacheDir) {
+		return errors.New("project has no cache directory set")
+	}
+
+	u, err := url.Parse(resource.OriginURL)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	s := NewSound(originalStream, format, channel)
-	s.filepath = resource.LocalFilepath
-	s.limitPlayback = limitPlayback
-	return s, nil
+	dest := resourceCachePath(cacheDir, u)
+
+	if dest == resource.LocalFilepath {
+		return nil
+	}
+
+	if err := copy.Copy(resource.LocalFilepath, dest); err != nil {
+		return err
+	}
+
+	if resource.TempFile {
+		os.Remove(resource.LocalFilepath)
+	}
+
+	resource.LocalFilepath = dest
+	resource.TempFile = false
+
+	return nil
+
 }
 
 func (resource *Resource) Destroy() {
 
+	if resource.Download != nil && !resource.Download.IsComplete() {
+		resource.Download.Cancel()
+	}
+
 	if resource.TempFile {
 		os.Remove(resource.LocalFilepath)
 	}
@@ -405,4 +582,8 @@ func (resource *Resource) Destroy() {
 
 	resource.Data = nil
 
+	resource.waveformMutex.Lock()
+	resource.waveform = nil
+	resource.waveformMutex.Unlock()
+
 }
@@ -61,6 +61,8 @@ const (
 	SettingsBrowserPath                  = "Browser Path"
 	SettingsBrowserUserDataPath          = "Browser User Data Path"
 	SettingsBrowserDefaultURL            = "Browser Default URL"
+	SettingsBrowserBackend               = "Browser Backend"
+	SettingsBrowserRemoteURL             = "Browser Remote Debugging URL"
 	SettingsLightboxEffect               = "Window Lightbox Effect"
 
 	SettingsAudioSoundVolume = "SoundVolume"
@@ -150,6 +152,12 @@ const (
 	ImageBufferSizeMax   = "Max"
 )
 
+const (
+	BrowserBackendChrome = "Local Chrome"
+	BrowserBackendRemote = "Remote Debugging URL"
+	BrowserBackendText   = "Text Only"
+)
+
 const (
 	TableHeadersSelected = "Selected"
 	TableHeadersHover    = "Hovering"
@@ -180,6 +188,8 @@ func NewProgramSettings(load bool) *Properties {
 	props.Get(SettingsBrowserPath).Set("")
 	props.Get(SettingsBrowserDefaultURL).Set("https://www.duckduckgo.com/")
 	props.Get(SettingsBrowserUserDataPath).Set("")
+	props.Get(SettingsBrowserBackend).Set(BrowserBackendChrome)
+	props.Get(SettingsBrowserRemoteURL).Set("")
 	props.Get(SettingsAutoLoadLastProject).Set(false)
 	props.Get(SettingsSmoothMovement).Set(true)
 	props.Get(SettingsNumberTopLevelCards).Set(true)
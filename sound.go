@@ -27,6 +27,9 @@ type Sound struct {
 	volume        *effects.Volume
 	control       *beep.Ctrl
 	channel       int
+
+	trimStart int // Where playback starts, in samples
+	trimEnd   int // Where playback ends, in samples; 0 plays to the end of the stream
 }
 
 func NewSound(stream beep.StreamSeeker, format beep.Format, channel int) *Sound {
@@ -64,7 +67,7 @@ func (sound *Sound) ReloadStream() {
 
 	// }
 
-	resampled := beep.Resample(3, sound.Format.SampleRate, globals.ChosenAudioSampleRate, sound.Stream)
+	resampled := beep.Resample(3, sound.Format.SampleRate, globals.ChosenAudioSampleRate, trimmedStreamer{sound})
 
 	seq := beep.Seq(resampled, beep.Callback(func() {
 		sound.Empty = true
@@ -117,6 +120,9 @@ func (sound *Sound) Play() {
 		sound.ReloadStream()
 	}
 	speaker.Lock()
+	if pos := sound.Stream.Position(); pos < sound.trimStart || pos >= sound.playbackEnd() {
+		sound.Stream.Seek(sound.trimStart)
+	}
 	sound.control.Paused = false
 	speaker.Unlock()
 }
@@ -140,16 +146,57 @@ func (sound *Sound) Seek(to time.Duration) {
 		to = to - sound.Length()
 	}
 	speaker.Lock()
-	sound.Stream.Seek(sound.Format.SampleRate.N(to))
+	sound.Stream.Seek(sound.clampToTrim(sound.Format.SampleRate.N(to)))
 	speaker.Unlock()
 }
 
 func (sound *Sound) SeekPercentage(percentage float32) {
 	speaker.Lock()
-	sound.Stream.Seek(int(percentage * float32(sound.Stream.Len())))
+	sound.Stream.Seek(sound.clampToTrim(int(percentage * float32(sound.Stream.Len()))))
 	speaker.Unlock()
 }
 
+// SetTrim sets the portion of the sound that plays, without altering the sound itself; an end of 0 plays to the end.
+func (sound *Sound) SetTrim(start, end time.Duration) {
+
+	if sound.Stream == nil {
+		return
+	}
+
+	speaker.Lock()
+
+	length := sound.Stream.Len()
+
+	sound.trimStart = max(0, min(sound.Format.SampleRate.N(start), length))
+	sound.trimEnd = 0
+
+	if end > 0 {
+		sound.trimEnd = max(0, min(sound.Format.SampleRate.N(end), length))
+		if sound.trimEnd <= sound.trimStart {
+			sound.trimEnd = 0
+		}
+	}
+
+	if pos := sound.Stream.Position(); pos < sound.trimStart || pos > sound.playbackEnd() {
+		sound.Stream.Seek(sound.trimStart)
+	}
+
+	speaker.Unlock()
+
+}
+
+// playbackEnd returns the sample playback stops at.
+func (sound *Sound) playbackEnd() int {
+	if sound.trimEnd > 0 {
+		return sound.trimEnd
+	}
+	return sound.Stream.Len()
+}
+
+func (sound *Sound) clampToTrim(sample int) int {
+	return max(sound.trimStart, min(sample, sound.playbackEnd()))
+}
+
 func (sound *Sound) Length() time.Duration {
 	speaker.Lock()
 	d := sound.Format.SampleRate.D(sound.Stream.Len())
@@ -176,6 +223,40 @@ func (sound *Sound) Destroy() {
 	speaker.Unlock()
 }
 
+// trimmedStreamer streams a Sound's stream, stopping at its trimmed end.
+type trimmedStreamer struct {
+	sound *Sound
+}
+
+func (ts trimmedStreamer) Stream(samples [][2]float64) (int, bool) {
+
+	stream := ts.sound.Stream
+
+	// The Sound's been destroyed
+	if stream == nil {
+		return 0, false
+	}
+
+	remaining := ts.sound.playbackEnd() - stream.Position()
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	if len(samples) > remaining {
+		samples = samples[:remaining]
+	}
+
+	return stream.Stream(samples)
+
+}
+
+func (ts trimmedStreamer) Err() error {
+	if ts.sound.Stream == nil {
+		return nil
+	}
+	return ts.sound.Stream.Err()
+}
+
 // func (sound *Sound) TogglePause() {
 // 	if sound.Control.Paused {
 // 		sound.Play()
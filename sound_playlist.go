@@ -0,0 +1,562 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Sound cards can hold a playlist of files to play one after another, along with named markers and an A-B loop for
+// each track. These are all stored in the card's properties; markers and loops are tied to their track by its
+// filename, so they stay put if the files are moved.
+
+const (
+	SoundRepeatAll = "Repeat All"
+	SoundRepeatOne = "Repeat One"
+	SoundRepeatOff = "Repeat Off"
+)
+
+var SoundRepeatModes = []string{SoundRepeatAll, SoundRepeatOne, SoundRepeatOff}
+
+type SoundMarker struct {
+	Name string  `json:"name"`
+	Time float64 `json:"time"` // In seconds
+	File string  `json:"file"`
+}
+
+// SoundRange is a span of time in a track, used for A-B loops and trims.
+type SoundRange struct {
+	File  string  `json:"file"`
+	Start float64 `json:"start"` // In seconds
+	End   float64 `json:"end"`
+}
+
+func formatPlaybackTime(t time.Duration) string {
+	minutes := int(t.Seconds()) / 60
+	seconds := int(t.Seconds()) - (minutes * 60)
+	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (sc *SoundContents) readList(propName string, list any) {
+	if prop := sc.Card.Properties.GetIfExists(propName); prop != nil && prop.IsString() && prop.AsString() != "" {
+		json.Unmarshal([]byte(prop.AsString()), list)
+	}
+}
+
+func (sc *SoundContents) writeList(propName string, list any, empty bool) {
+
+	// Removed properties would linger when undoing, so they're cleared instead
+	if empty {
+		sc.Card.Properties.Get(propName).Set("")
+		return
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		globals.EventLog.Log("Couldn't save sound card %s: %s", true, propName, err.Error())
+		return
+	}
+
+	sc.Card.Properties.Get(propName).Set(string(data))
+
+}
+
+// trackName is the name markers and loops use to refer to the current track.
+func (sc *SoundContents) trackName() string {
+	return filepath.Base(sc.Card.Properties.Get("filepath").AsString())
+}
+
+// Playlist returns the paths of the files in the card's playlist.
+func (sc *SoundContents) Playlist() []string {
+	playlist := []string{}
+	sc.readList("playlist", &playlist)
+	return playlist
+}
+
+func (sc *SoundContents) SetPlaylist(playlist []string) {
+	sc.writeList("playlist", playlist, len(playlist) == 0)
+}
+
+// AddToPlaylist adds files to the end of the playlist; if the playlist is empty, the file currently loaded goes in first.
+func (sc *SoundContents) AddToPlaylist(paths ...string) {
+
+	playlist := sc.Playlist()
+
+	if current := sc.Card.Properties.Get("filepath").AsString(); len(playlist) == 0 && current != "" {
+		playlist = append(playlist, current)
+	}
+
+	for _, path := range paths {
+
+		exists := false
+		for _, existing := range playlist {
+			if existing == path {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			playlist = append(playlist, path)
+		}
+
+	}
+
+	sc.SetPlaylist(playlist)
+
+	if sc.TrackIndex() < 0 && len(playlist) > 0 {
+		sc.PlayTrack(0)
+	}
+
+}
+
+func (sc *SoundContents) RemoveFromPlaylist(index int) {
+
+	playlist := sc.Playlist()
+
+	if index < 0 || index >= len(playlist) {
+		return
+	}
+
+	sc.SetPlaylist(append(playlist[:index], playlist[index+1:]...))
+
+}
+
+// MoveInPlaylist moves a track up (-1) or down (1) in the playlist.
+func (sc *SoundContents) MoveInPlaylist(index, direction int) {
+
+	playlist := sc.Playlist()
+	other := index + direction
+
+	if index < 0 || index >= len(playlist) || other < 0 || other >= len(playlist) {
+		return
+	}
+
+	playlist[index], playlist[other] = playlist[other], playlist[index]
+	sc.SetPlaylist(playlist)
+
+}
+
+// TrackIndex returns the index of the loaded file in the playlist, or -1 if it isn't in it.
+func (sc *SoundContents) TrackIndex() int {
+
+	current := sc.Card.Properties.Get("filepath").AsString()
+
+	for i, path := range sc.Playlist() {
+		if path == current {
+			return i
+		}
+	}
+
+	return -1
+
+}
+
+// PlayTrack switches to the track at the given index in the playlist, carrying on playing if the card was already.
+func (sc *SoundContents) PlayTrack(index int) {
+
+	playlist := sc.Playlist()
+
+	if index < 0 || index >= len(playlist) {
+		return
+	}
+
+	playing := sc.Playing
+
+	// Moving from track to track isn't an edit, so it doesn't create an undo state
+	sc.Card.Properties.Get("filepath").SetRaw(playlist[index])
+	sc.LoadFile()
+
+	sc.Playing = playing
+	sc.changingTrack = true
+
+}
+
+func (sc *SoundContents) Shuffled() bool {
+	prop := sc.Card.Properties.GetIfExists("shuffle")
+	return prop != nil && prop.IsBool() && prop.AsBool()
+}
+
+func (sc *SoundContents) RepeatMode() string {
+	if prop := sc.Card.Properties.GetIfExists("repeat"); prop != nil && prop.IsString() && prop.AsString() != "" {
+		return prop.AsString()
+	}
+	return SoundRepeatAll
+}
+
+// playOrder returns the order the playlist's tracks are played in; when shuffling, this is shuffled again whenever the
+// playlist changes or wraps around.
+func (sc *SoundContents) playOrder(count int) []int {
+
+	if !sc.Shuffled() {
+		order := make([]int, count)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	if len(sc.shuffleOrder) != count {
+		sc.shuffleOrder = rand.Perm(count)
+	}
+
+	return sc.shuffleOrder
+
+}
+
+// NextTrack returns the index of the track that comes step tracks after (or before, if negative) the current one, and
+// false if that goes past the end of the playlist without wrapping around.
+func (sc *SoundContents) NextTrack(step int, wrap bool) (int, bool) {
+
+	count := len(sc.Playlist())
+
+	if count == 0 {
+		return 0, false
+	}
+
+	order := sc.playOrder(count)
+
+	pos := -1
+	current := sc.TrackIndex()
+	for i, index := range order {
+		if index == current {
+			pos = i
+			break
+		}
+	}
+
+	next := pos + step
+	if pos < 0 {
+		next = 0
+	}
+
+	if next < 0 || next >= count {
+
+		if !wrap {
+			return 0, false
+		}
+
+		if sc.Shuffled() && next >= count {
+			sc.shuffleOrder = nil
+			order = sc.playOrder(count)
+		}
+
+		next = (next%count + count) % count
+
+	}
+
+	return order[next], true
+
+}
+
+// SkipTrack moves step tracks along the playlist, wrapping around at either end.
+func (sc *SoundContents) SkipTrack(step int) {
+	if next, ok := sc.NextTrack(step, true); ok {
+		sc.PlayTrack(next)
+	}
+}
+
+// advancePlaylist is called when a track finishes playing, and moves onto the next track according to the repeat
+// mode. It returns true if it switched to another track.
+func (sc *SoundContents) advancePlaylist() bool {
+
+	repeat := sc.RepeatMode()
+
+	if repeat == SoundRepeatOne {
+		return false
+	}
+
+	next, ok := sc.NextTrack(1, repeat == SoundRepeatAll)
+
+	if !ok {
+		if repeat == SoundRepeatOff {
+			sc.Playing = false
+		}
+		return false
+	}
+
+	if next == sc.TrackIndex() {
+		return false
+	}
+
+	sc.PlayTrack(next)
+	return true
+
+}
+
+// Markers returns all of the card's markers, across all tracks.
+func (sc *SoundContents) Markers() []SoundMarker {
+	markers := []SoundMarker{}
+	sc.readList("markers", &markers)
+	return markers
+}
+
+func (sc *SoundContents) SetMarkers(markers []SoundMarker) {
+
+	sort.SliceStable(markers, func(i, j int) bool {
+		if markers[i].File != markers[j].File {
+			return markers[i].File < markers[j].File
+		}
+		return markers[i].Time < markers[j].Time
+	})
+
+	sc.writeList("markers", markers, len(markers) == 0)
+
+}
+
+// TrackMarkers returns the markers on the current track, in order.
+func (sc *SoundContents) TrackMarkers() []SoundMarker {
+
+	markers := []SoundMarker{}
+	name := sc.trackName()
+
+	for _, marker := range sc.Markers() {
+		if marker.File == name {
+			markers = append(markers, marker)
+		}
+	}
+
+	return markers
+
+}
+
+// AddMarker adds a marker at the current playback position.
+func (sc *SoundContents) AddMarker(name string) {
+
+	if sc.Sound == nil {
+		return
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("Marker %d", len(sc.TrackMarkers())+1)
+	}
+
+	sc.SetMarkers(append(sc.Markers(), SoundMarker{
+		Name: name,
+		Time: sc.Sound.Position().Seconds(),
+		File: sc.trackName(),
+	}))
+
+}
+
+// UpdateMarker replaces the given marker with another.
+func (sc *SoundContents) UpdateMarker(marker SoundMarker, replacement *SoundMarker) {
+
+	markers := sc.Markers()
+
+	for i, m := range markers {
+		if m == marker {
+			if replacement != nil {
+				markers[i] = *replacement
+			} else {
+				markers = append(markers[:i], markers[i+1:]...)
+			}
+			break
+		}
+	}
+
+	sc.SetMarkers(markers)
+
+}
+
+// JumpTo seeks to the given time (in seconds) in the current track.
+func (sc *SoundContents) JumpTo(seconds float64) {
+	if sc.Sound != nil {
+		sc.Sound.Seek(secondsToDuration(seconds))
+	}
+}
+
+// trackRange returns the span of the current track stored in the given property (a list of SoundRanges), if there is
+// one.
+func (sc *SoundContents) trackRange(propName string) (SoundRange, bool) {
+
+	ranges := []SoundRange{}
+	sc.readList(propName, &ranges)
+
+	name := sc.trackName()
+
+	for _, r := range ranges {
+		if r.File == name && r.End > r.Start {
+			return r, true
+		}
+	}
+
+	return SoundRange{File: name}, false
+
+}
+
+func (sc *SoundContents) setTrackRange(propName string, trackRange *SoundRange) {
+
+	ranges := []SoundRange{}
+	sc.readList(propName, &ranges)
+
+	name := sc.trackName()
+
+	for i, r := range ranges {
+		if r.File == name {
+			ranges = append(ranges[:i], ranges[i+1:]...)
+			break
+		}
+	}
+
+	if trackRange != nil {
+		ranges = append(ranges, *trackRange)
+	}
+
+	sc.writeList(propName, ranges, len(ranges) == 0)
+
+}
+
+// setTrackRangePoint sets the start or end of the current track's span in the given property to the playback position.
+// If the other end isn't set or would come out of order, it's moved to the start or end of the track.
+func (sc *SoundContents) setTrackRangePoint(propName string, start bool) {
+
+	if sc.Sound == nil {
+		return
+	}
+
+	position := sc.Sound.Position().Seconds()
+	length := sc.Sound.Length().Seconds()
+
+	r, ok := sc.trackRange(propName)
+	if !ok {
+		r.Start = 0
+		r.End = length
+	}
+
+	if start {
+		r.Start = position
+		if r.End <= position {
+			r.End = length
+		}
+	} else {
+		r.End = position
+		if r.Start >= position {
+			r.Start = 0
+		}
+	}
+
+	if r.End <= r.Start {
+		globals.EventLog.Log("The end needs to come after the start.", true)
+		return
+	}
+
+	sc.setTrackRange(propName, &r)
+
+}
+
+// Loop returns the A-B loop set on the current track, if there is one.
+func (sc *SoundContents) Loop() (SoundRange, bool) {
+	return sc.trackRange("loops")
+}
+
+// SetLoopPoint sets the start (A) or end (B) of the current track's loop to the playback position.
+func (sc *SoundContents) SetLoopPoint(start bool) {
+	sc.setTrackRangePoint("loops", start)
+}
+
+func (sc *SoundContents) ClearLoop() {
+	if _, ok := sc.Loop(); ok {
+		sc.setTrackRange("loops", nil)
+	}
+}
+
+// updateLoop jumps back to the start of the loop once playback reaches its end.
+func (sc *SoundContents) updateLoop() {
+
+	if sc.Sound == nil || !sc.Playing {
+		return
+	}
+
+	if loop, ok := sc.Loop(); ok && sc.Sound.Position().Seconds() >= loop.End {
+		sc.JumpTo(loop.Start)
+	}
+
+}
+
+// drawSeekBarMarks draws the current track's loop and markers over the seek bar.
+func (sc *SoundContents) drawSeekBarMarks() {
+
+	bar := sc.SeekBar.Rect
+
+	// The seek bar's off the bottom of the card if it's too short
+	if sc.Sound == nil || bar.Y+bar.H > sc.Card.DisplayRect.Y+sc.Card.DisplayRect.H {
+		return
+	}
+
+	length := sc.Sound.Length().Seconds()
+
+	if length <= 0 {
+		return
+	}
+
+	camera := sc.Card.Page.Project.Camera
+
+	// This matches how the Scrollbar maps its value to its head
+	toX := func(seconds float64) float32 {
+		return bar.X + 8 + float32(seconds/length)*(bar.W-16)
+	}
+
+	if loop, ok := sc.Loop(); ok {
+
+		color := getThemeColor(GUICompletedColor).Clone()
+		color[3] = 128
+
+		rect := camera.TranslateRect(&sdl.FRect{toX(loop.Start), bar.Y + 2, toX(loop.End) - toX(loop.Start), bar.H - 4})
+		FillRect(rect.X, rect.Y, rect.W, rect.H, color)
+
+	}
+
+	mouse := globals.Mouse.WorldPosition()
+	hovered := ""
+	var hoveredPos Vector
+
+	for _, marker := range sc.TrackMarkers() {
+
+		x := toX(marker.Time)
+		rect := camera.TranslateRect(&sdl.FRect{x - 1, bar.Y - 4, 2, bar.H + 8})
+		FillRect(rect.X, rect.Y, rect.W, rect.H, getThemeColor(GUIFontColor))
+
+		if mouse.Y >= bar.Y-4 && mouse.Y <= bar.Y+bar.H+4 && mouse.X >= x-4 && mouse.X <= x+4 {
+			hovered = marker.Name + " (" + formatPlaybackTime(secondsToDuration(marker.Time)) + ")"
+			hoveredPos = Vector{x, bar.Y - 28}
+		}
+
+	}
+
+	if hovered != "" {
+		DrawLabel(camera.TranslatePoint(hoveredPos), 1, hovered, getThemeColor(GUIMenuColor))
+	}
+
+}
+
+// PlaylistPaths converts the paths in a playlist property's JSON to be relative to the project (for saving), or back
+// to absolute (for loading).
+func (project *Project) PlaylistPaths(data string, relative bool) string {
+
+	paths := []string{}
+	if err := json.Unmarshal([]byte(data), &paths); err != nil {
+		return data
+	}
+
+	for i, path := range paths {
+		if relative {
+			paths[i] = project.PathToRelative(path, false)
+		} else {
+			paths[i] = project.PathToAbsolute(path, false)
+		}
+	}
+
+	out, _ := json.Marshal(paths)
+	return string(out)
+
+}
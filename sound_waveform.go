@@ -0,0 +1,194 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Sound cards draw their sound's waveform behind the seek bar, and can be trimmed to only play part of it. Trims are
+// non-destructive; like loops, they're stored for each track in the card's properties.
+
+// SoundWaveformResolution is how many slices an audio file's waveform is split into.
+const SoundWaveformResolution = 2048
+
+// SoundWaveform is an overview of how loud an audio file is over its length.
+type SoundWaveform struct {
+	Peaks []float32 // The loudest sample in each slice of the sound, from 0 to 1 (relative to the loudest overall)
+	Ready bool
+}
+
+// Waveform returns the waveform of the Resource's audio file. It's worked out in the background the first time it's
+// asked for, and cached along with the Resource from then on; until it's ready, Waveform returns nil.
+func (resource *Resource) Waveform() *SoundWaveform {
+
+	resource.waveformMutex.Lock()
+	defer resource.waveformMutex.Unlock()
+
+	if resource.waveform == nil {
+		resource.waveform = &SoundWaveform{}
+		go resource.computeWaveform(resource.waveform)
+	}
+
+	if !resource.waveform.Ready {
+		return nil
+	}
+
+	return resource.waveform
+
+}
+
+func (resource *Resource) computeWaveform(waveform *SoundWaveform) {
+
+	peaks := []float32{}
+
+	stream, _, err := resource.decodeSound()
+
+	if err == nil {
+
+		defer stream.Close()
+
+		length := stream.Len()
+		sliceSize := max(1, int(math.Ceil(float64(length)/SoundWaveformResolution)))
+		peaks = make([]float32, (length+sliceSize-1)/sliceSize)
+
+		samples := make([][2]float64, 4096)
+		position := 0
+		loudest := float32(0)
+
+		for {
+
+			n, ok := stream.Stream(samples)
+
+			for _, sample := range samples[:n] {
+
+				peak := float32(max(math.Abs(sample[0]), math.Abs(sample[1])))
+
+				if slice := position / sliceSize; slice < len(peaks) && peak > peaks[slice] {
+					peaks[slice] = peak
+					loudest = max(loudest, peak)
+				}
+
+				position++
+
+			}
+
+			if !ok || n == 0 {
+				break
+			}
+
+		}
+
+		if loudest > 0 {
+			for i := range peaks {
+				peaks[i] /= loudest
+			}
+		}
+
+	} else {
+		// This runs in the background, so it's only logged to the console
+		log.Println("couldn't create waveform for", resource.Name, ":", err.Error())
+	}
+
+	resource.waveformMutex.Lock()
+	waveform.Peaks = peaks
+	waveform.Ready = true
+	resource.waveformMutex.Unlock()
+
+}
+
+// drawWaveform draws the sound's waveform behind the seek bar, dimming the parts that have been trimmed off.
+func (sc *SoundContents) drawWaveform() {
+
+	if sc.Resource == nil || !sc.Resource.FinishedDownloading() || !sc.Resource.IsSound() || sc.Sound == nil {
+		return
+	}
+
+	bar := sc.SeekBar.Rect
+
+	if bar.Y+bar.H > sc.Card.DisplayRect.Y+sc.Card.DisplayRect.H {
+		return
+	}
+
+	waveform := sc.Resource.Waveform()
+
+	if waveform == nil || len(waveform.Peaks) == 0 {
+		return
+	}
+
+	length := sc.Sound.Length().Seconds()
+	trim, _ := sc.Trim()
+	camera := sc.Card.Page.Project.Camera
+
+	// As with the markers, this lines up with the Scrollbar's head
+	left := bar.X + 8
+	width := bar.W - 16
+	centerY := bar.Y + bar.H/2
+	height := float32(globals.GridSize) - 4
+	step := float32(2)
+
+	color := getThemeColor(GUIFontColor).Clone()
+
+	for x := float32(0); x < width; x += step {
+
+		start := int(x / width * float32(len(waveform.Peaks)))
+		end := max(start+1, int((x+step)/width*float32(len(waveform.Peaks))))
+
+		peak := float32(0)
+		for _, p := range waveform.Peaks[start:min(end, len(waveform.Peaks))] {
+			peak = max(peak, p)
+		}
+
+		seconds := float64(x/width) * length
+
+		color[3] = 128
+		if seconds < trim.Start || (trim.End > 0 && seconds > trim.End) {
+			color[3] = 32
+		}
+
+		h := max(1, peak*height)
+		rect := camera.TranslateRect(&sdl.FRect{left + x, centerY - h/2, step, h})
+		FillRect(rect.X, rect.Y, rect.W, rect.H, color)
+
+	}
+
+}
+
+// Trim returns the trim set on the current track, if there is one.
+func (sc *SoundContents) Trim() (SoundRange, bool) {
+	return sc.trackRange("trims")
+}
+
+// SetTrimPoint trims the start or end of the current track at the playback position.
+func (sc *SoundContents) SetTrimPoint(start bool) {
+	sc.setTrackRangePoint("trims", start)
+	sc.applyTrim()
+}
+
+func (sc *SoundContents) ResetTrim() {
+	if _, ok := sc.Trim(); ok {
+		sc.setTrackRange("trims", nil)
+		sc.applyTrim()
+	}
+}
+
+// applyTrim passes the current track's trim on to its Sound whenever it changes.
+func (sc *SoundContents) applyTrim() {
+
+	if sc.Sound == nil {
+		return
+	}
+
+	trim, ok := sc.Trim()
+	if !ok {
+		trim = SoundRange{}
+	}
+
+	if sc.Sound != sc.trimmedSound || trim != sc.appliedTrim {
+		sc.Sound.SetTrim(secondsToDuration(trim.Start), secondsToDuration(trim.End))
+		sc.trimmedSound = sc.Sound
+		sc.appliedTrim = trim
+	}
+
+}
@@ -0,0 +1,824 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Table cells other than checkboxes hold text, numbers or dates, or a formula if their text starts with "=".
+// Formulas refer to cells spreadsheet-style, with columns lettered from A and rows numbered from 1 (so "B3" is the
+// second column of the third row). They support + - * / and parentheses, along with these functions, which take
+// cells, ranges ("A1:B4"), whole columns ("A:A") or rows ("2:3"), or other values:
+//
+//	SUM    - The total of the values
+//	COUNT  - How many of the cells are filled in (or checked, for checkboxes)
+//	AVG    - The average of the values
+//	MIN    - The smallest value
+//	MAX    - The largest value
+//
+// In Checkmarks mode, checked cells count as 1 and unchecked cells as 0, while X'ed out cells are skipped; in the other
+// modes, checkbox cells count as the number of the letter or number shown. Dates count as a number of days, so they can
+// be subtracted from each other.
+
+var tableCellTypeNames = []string{"Checkbox", "Text", "Number", "Date"}
+
+var tableDateLayouts = []string{
+	"2006-01-02",
+	"2006/1/2",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+}
+
+const tableCellTextSize = 0.5
+
+// tableCellResult is what a table cell evaluates to.
+type tableCellResult struct {
+	Value  float64
+	Valid  bool   // Whether Value can be used as a number
+	Filled bool   // Whether the cell counts towards COUNT
+	Text   string // What's shown in the cell
+	Err    error
+}
+
+// TableColumnName returns the letters used to refer to a column in formulas (A, B, ... Z, AA, AB, and so on).
+func TableColumnName(column int) string {
+
+	name := ""
+
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = string(rune('A'+(column-1)%26)) + name
+	}
+
+	return name
+
+}
+
+// TableCellName returns the name used to refer to a cell in formulas (i.e. "B3").
+func TableCellName(x, y int) string {
+	return TableColumnName(x) + strconv.Itoa(y+1)
+}
+
+func (tdc *TableDataContents) IsFormula() bool {
+	return tdc.Type != TableCellTypeCheckbox && strings.HasPrefix(strings.TrimSpace(tdc.Text), "=")
+}
+
+// CellPosition returns the position of the given cell in the table, and false if it isn't visible in the table.
+func (td *TableData) CellPosition(cell *TableDataContents) (int, int, bool) {
+
+	for y := 0; y < td.Height && y < len(td.Data); y++ {
+		for x := 0; x < td.Width && x < len(td.Data[y]); x++ {
+			if td.Data[y][x] == cell {
+				return x, y, true
+			}
+		}
+	}
+
+	return 0, 0, false
+
+}
+
+// EditCell opens the table settings menu to edit the given cell's contents.
+func (td *TableData) EditCell(cell *TableDataContents) {
+
+	td.ActiveCell = cell
+	td.FocusFormulaBar = true
+
+	menu := globals.MenuSystem.Get("table settings menu")
+	if !menu.Opened {
+		menu.Open()
+	}
+
+}
+
+// SetCellText sets the contents of a cell. Checkbox cells given text are changed to the type that suits it.
+func (td *TableData) SetCellText(cell *TableDataContents, text string) {
+
+	if cell.Text == text {
+		return
+	}
+
+	if cell.Type == TableCellTypeCheckbox {
+
+		if text == "" {
+			return
+		}
+
+		cell.Type = inferTableCellType(text)
+
+	}
+
+	cell.Text = text
+
+	td.ForceUndoStateCreation()
+
+}
+
+// inferTableCellType returns the type of cell that suits the given text; formulas go in number cells.
+func inferTableCellType(text string) int {
+
+	if strings.HasPrefix(text, "=") {
+		return TableCellTypeNumber
+	} else if _, ok := parseTableNumber(text); ok {
+		return TableCellTypeNumber
+	} else if _, ok := parseTableDate(text); ok {
+		return TableCellTypeDate
+	}
+
+	return TableCellTypeText
+
+}
+
+// SetCellType changes the type of the given cells; checkbox cells don't keep any text.
+func (td *TableData) SetCellType(cellType int, cells ...*TableDataContents) {
+
+	changed := false
+
+	for _, cell := range cells {
+
+		if cell.Type == cellType {
+			continue
+		}
+
+		cell.Type = cellType
+		if cellType == TableCellTypeCheckbox {
+			cell.Text = ""
+		} else {
+			cell.Value = 0
+		}
+
+		changed = true
+
+	}
+
+	if changed {
+		td.ForceUndoStateCreation()
+	}
+
+}
+
+// cellCompletion returns how complete a cell is and how much it could be. Checkbox cells count in Checkmarks mode as
+// they always have, and formula cells count like a checkbox that's as full as their result (clamped between 0 and 1),
+// so a formula like "=AVG(A:A)" can roll a column of checkboxes up into one cell.
+func (td *TableData) cellCompletion(ev *tableEvaluator, x, y int) (float32, float32) {
+
+	cell := td.Data[y][x]
+
+	if cell.Type == TableCellTypeCheckbox {
+
+		if td.ValueDisplayMode != ValueDisplayModeCheck || cell.Value == 2 {
+			return 0, 0
+		}
+
+		if cell.Value == 1 {
+			return 1, 1
+		}
+
+		return 0, 1
+
+	}
+
+	if !cell.IsFormula() {
+		return 0, 0
+	}
+
+	result := ev.Result(x, y)
+	if !result.Valid {
+		return 0, 0
+	}
+
+	return float32(min(max(result.Value, 0), 1)), 1
+
+}
+
+func parseTableNumber(text string) (float64, bool) {
+
+	text = strings.ReplaceAll(strings.TrimSpace(text), ",", "")
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+
+	return value, true
+
+}
+
+// parseTableDate parses a date, returning it as a number of days since the Unix epoch.
+func parseTableDate(text string) (float64, bool) {
+
+	text = strings.TrimSpace(text)
+
+	for _, layout := range tableDateLayouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return float64(date.Unix() / 86400), true
+		}
+	}
+
+	return 0, false
+
+}
+
+func formatTableDate(days float64) string {
+	return time.Unix(int64(math.Floor(days))*86400, 0).UTC().Format("Jan 2")
+}
+
+func formatTableNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// tableEvaluator works out what the cells of a table evaluate to, remembering the results so each cell is only
+// evaluated once.
+type tableEvaluator struct {
+	td       *TableData
+	results  map[*TableDataContents]tableCellResult
+	visiting map[*TableDataContents]bool
+}
+
+func newTableEvaluator(td *TableData) *tableEvaluator {
+	return &tableEvaluator{
+		td:       td,
+		results:  map[*TableDataContents]tableCellResult{},
+		visiting: map[*TableDataContents]bool{},
+	}
+}
+
+func (ev *tableEvaluator) Result(x, y int) tableCellResult {
+
+	cell := ev.td.Data[y][x]
+
+	if result, ok := ev.results[cell]; ok {
+		return result
+	}
+
+	result := tableCellResult{Text: cell.Text}
+
+	switch {
+
+	case cell.Type == TableCellTypeCheckbox:
+
+		if ev.td.ValueDisplayMode == ValueDisplayModeCheck {
+			if cell.Value != 2 {
+				result.Value = float64(cell.Value)
+				result.Valid = true
+				result.Filled = cell.Value == 1
+			}
+		} else {
+			result.Value = float64(cell.Value)
+			result.Valid = true
+			result.Filled = true
+		}
+
+	case cell.IsFormula():
+
+		if ev.visiting[cell] {
+			return tableCellResult{Err: fmt.Errorf("%s is part of a circular reference", TableCellName(x, y))}
+		}
+
+		ev.visiting[cell] = true
+
+		parser := &tableFormulaParser{ev: ev, cell: cell, text: []rune(strings.TrimSpace(cell.Text)[1:])}
+		value, err := parser.Parse()
+
+		delete(ev.visiting, cell)
+
+		if err != nil {
+			result = tableCellResult{Err: err}
+		} else {
+			result.Value = value
+			result.Valid = true
+			result.Filled = true
+			if cell.Type == TableCellTypeDate {
+				result.Text = formatTableDate(value)
+			} else {
+				result.Text = formatTableNumber(value)
+			}
+		}
+
+	case cell.Type == TableCellTypeNumber:
+
+		result.Value, result.Valid = parseTableNumber(cell.Text)
+		result.Filled = cell.Text != ""
+
+	case cell.Type == TableCellTypeDate:
+
+		result.Value, result.Valid = parseTableDate(cell.Text)
+		result.Filled = cell.Text != ""
+		if result.Valid {
+			result.Text = formatTableDate(result.Value)
+		}
+
+	default:
+
+		result.Filled = cell.Text != ""
+
+	}
+
+	if result.Err != nil {
+		result.Text = "ERR"
+	}
+
+	ev.results[cell] = result
+
+	return result
+
+}
+
+// tableFormulaParser evaluates a formula as it parses it.
+type tableFormulaParser struct {
+	ev   *tableEvaluator
+	cell *TableDataContents // The formula's own cell, which ranges skip so totals can cover their own row or column
+	text []rune
+	pos  int
+}
+
+func (p *tableFormulaParser) Parse() (float64, error) {
+
+	if len(p.text) == 0 {
+		return 0, errors.New("the formula is empty")
+	}
+
+	value, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.skipSpaces(); p.pos < len(p.text) {
+		return 0, fmt.Errorf("unexpected \"%s\"", string(p.text[p.pos:]))
+	}
+
+	return value, nil
+
+}
+
+func (p *tableFormulaParser) skipSpaces() {
+	for p.pos < len(p.text) && unicode.IsSpace(p.text[p.pos]) {
+		p.pos++
+	}
+}
+
+// accept moves past the given character if it's next, returning whether it was.
+func (p *tableFormulaParser) accept(char rune) bool {
+
+	p.skipSpaces()
+
+	if p.pos < len(p.text) && p.text[p.pos] == char {
+		p.pos++
+		return true
+	}
+
+	return false
+
+}
+
+func (p *tableFormulaParser) expression() (float64, error) {
+
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+
+		if p.accept('+') {
+			other, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value += other
+		} else if p.accept('-') {
+			other, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value -= other
+		} else {
+			return value, nil
+		}
+
+	}
+
+}
+
+func (p *tableFormulaParser) term() (float64, error) {
+
+	value, err := p.factor()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+
+		if p.accept('*') {
+			other, err := p.factor()
+			if err != nil {
+				return 0, err
+			}
+			value *= other
+		} else if p.accept('/') {
+			other, err := p.factor()
+			if err != nil {
+				return 0, err
+			}
+			if other == 0 {
+				return 0, errors.New("division by zero")
+			}
+			value /= other
+		} else {
+			return value, nil
+		}
+
+	}
+
+}
+
+func (p *tableFormulaParser) factor() (float64, error) {
+
+	if p.accept('-') {
+		value, err := p.factor()
+		return -value, err
+	}
+
+	if p.accept('(') {
+
+		value, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+
+		if !p.accept(')') {
+			return 0, errors.New("missing \")\"")
+		}
+
+		return value, nil
+
+	}
+
+	p.skipSpaces()
+
+	if p.pos >= len(p.text) {
+		return 0, errors.New("the formula ends too early")
+	}
+
+	if char := p.text[p.pos]; unicode.IsDigit(char) || char == '.' {
+
+		start := p.pos
+		for p.pos < len(p.text) && (unicode.IsDigit(p.text[p.pos]) || p.text[p.pos] == '.') {
+			p.pos++
+		}
+
+		value, err := strconv.ParseFloat(string(p.text[start:p.pos]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("\"%s\" isn't a number", string(p.text[start:p.pos]))
+		}
+
+		return value, nil
+
+	}
+
+	letters := p.letters()
+
+	if letters == "" {
+		return 0, fmt.Errorf("unexpected \"%s\"", string(p.text[p.pos]))
+	}
+
+	if p.accept('(') {
+		return p.function(letters)
+	}
+
+	x, y, err := p.reference(letters)
+	if err != nil {
+		return 0, err
+	}
+
+	result := p.ev.Result(x, y)
+
+	if result.Err != nil {
+		return 0, result.Err
+	} else if result.Valid {
+		return result.Value, nil
+	} else if !result.Filled {
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("%s isn't a number", TableCellName(x, y))
+
+}
+
+func (p *tableFormulaParser) letters() string {
+
+	p.skipSpaces()
+
+	start := p.pos
+	for p.pos < len(p.text) && unicode.IsLetter(p.text[p.pos]) {
+		p.pos++
+	}
+
+	return strings.ToUpper(string(p.text[start:p.pos]))
+
+}
+
+func (p *tableFormulaParser) digits() int {
+
+	start := p.pos
+	for p.pos < len(p.text) && unicode.IsDigit(p.text[p.pos]) {
+		p.pos++
+	}
+
+	if start == p.pos {
+		return -1
+	}
+
+	value, _ := strconv.Atoi(string(p.text[start:p.pos]))
+
+	return value
+
+}
+
+// position returns the position in the table of a column's letters and a row's number; either can be left out (as
+// "" or -1), in which case -1 is returned for it.
+func (p *tableFormulaParser) position(letters string, row int) (int, int, error) {
+
+	x := -1
+
+	if letters != "" {
+
+		x = 0
+		for _, char := range letters {
+
+			if char < 'A' || char > 'Z' {
+				return 0, 0, fmt.Errorf("\"%s\" isn't a column", letters)
+			}
+
+			// Checking as we go stops long references from overflowing
+			x = x*26 + int(char-'A') + 1
+			if x > p.ev.td.Width {
+				return 0, 0, fmt.Errorf("column %s is outside of the table", letters)
+			}
+
+		}
+		x--
+
+	}
+
+	if row >= 0 && (row < 1 || row > p.ev.td.Height) {
+		return 0, 0, fmt.Errorf("row %d is outside of the table", row)
+	}
+
+	if row >= 0 {
+		row--
+	}
+
+	return x, row, nil
+
+}
+
+// reference reads the row number of a cell reference following its column letters.
+func (p *tableFormulaParser) reference(letters string) (int, int, error) {
+
+	row := p.digits()
+
+	if row < 0 {
+		return 0, 0, fmt.Errorf("\"%s\" isn't a cell or function", letters)
+	}
+
+	return p.position(letters, row)
+
+}
+
+// function evaluates a function, whose opening parenthesis has already been read.
+func (p *tableFormulaParser) function(name string) (float64, error) {
+
+	values := []float64{}
+	filled := 0
+
+	for args := 0; !p.accept(')'); args++ {
+
+		if args > 0 {
+			if !p.accept(',') {
+				return 0, fmt.Errorf("missing \")\" after %s", name)
+			}
+		}
+
+		x0, y0, x1, y1, isRange, err := p.cellRange()
+		if err != nil {
+			return 0, err
+		}
+
+		if !isRange {
+
+			value, err := p.expression()
+			if err != nil {
+				return 0, err
+			}
+
+			values = append(values, value)
+			filled++
+			continue
+
+		}
+
+		for y := y0; y <= y1; y++ {
+
+			for x := x0; x <= x1; x++ {
+
+				if p.ev.td.Data[y][x] == p.cell {
+					continue
+				}
+
+				result := p.ev.Result(x, y)
+
+				if result.Err != nil {
+					return 0, result.Err
+				}
+
+				if result.Valid {
+					values = append(values, result.Value)
+				}
+
+				if result.Filled {
+					filled++
+				}
+
+			}
+
+		}
+
+	}
+
+	total := float64(0)
+	for _, v := range values {
+		total += v
+	}
+
+	switch name {
+	case "SUM":
+		return total, nil
+	case "COUNT":
+		return float64(filled), nil
+	case "AVG", "AVERAGE":
+		if len(values) == 0 {
+			return 0, errors.New("AVG has no values to average")
+		}
+		return total / float64(len(values)), nil
+	case "MIN", "MAX":
+		if len(values) == 0 {
+			return 0, fmt.Errorf("%s has no values", name)
+		}
+		result := values[0]
+		for _, v := range values[1:] {
+			if name == "MIN" {
+				result = min(result, v)
+			} else {
+				result = max(result, v)
+			}
+		}
+		return result, nil
+	}
+
+	return 0, fmt.Errorf("there's no %s function", name)
+
+}
+
+// cellRange tries to read a cell, range of cells, or whole columns or rows as a function argument. If there isn't one
+// there, nothing is read and isRange is false.
+func (p *tableFormulaParser) cellRange() (x0, y0, x1, y1 int, isRange bool, err error) {
+
+	start := p.pos
+
+	// Each end of a range can be a cell ("B2"), a column ("B") or a row ("2")
+	letters0, row0 := p.letters(), p.digits()
+
+	if letters0 == "" && row0 < 0 {
+		p.pos = start
+		return
+	}
+
+	if !p.accept(':') {
+
+		// A single cell on its own counts as a range, so COUNT can count it even if it isn't a number
+		if letters0 != "" && row0 >= 0 {
+			p.skipSpaces()
+			if p.pos >= len(p.text) || p.text[p.pos] == ',' || p.text[p.pos] == ')' {
+				x0, y0, err = p.position(letters0, row0)
+				return x0, y0, x0, y0, true, err
+			}
+		}
+
+		p.pos = start
+		return
+
+	}
+
+	letters1, row1 := p.letters(), p.digits()
+
+	if x0, y0, err = p.position(letters0, row0); err != nil {
+		return
+	}
+
+	if x1, y1, err = p.position(letters1, row1); err != nil {
+		return
+	}
+
+	switch {
+	case x0 >= 0 && y0 >= 0 && x1 >= 0 && y1 >= 0:
+	case x0 >= 0 && y0 < 0 && x1 >= 0 && y1 < 0:
+		y0, y1 = 0, p.ev.td.Height-1
+	case x0 < 0 && y0 >= 0 && x1 < 0 && y1 >= 0:
+		x0, x1 = 0, p.ev.td.Width-1
+	default:
+		err = errors.New("ranges should be between two cells, columns or rows")
+		return
+	}
+
+	return min(x0, x1), min(y0, y1), max(x0, x1), max(y0, y1), true, nil
+
+}
+
+// drawCellContents draws the text of the table's non-checkbox cells, with the full text of a cell that doesn't fit
+// shown when it's hovered over.
+func (td *TableData) drawCellContents() {
+
+	camera := td.Table.Card.Page.Project.Camera
+	ev := newTableEvaluator(td)
+	mousePos := globals.Mouse.WorldPosition()
+
+	hoveredText := ""
+	var hoveredCell *TableDataContents
+
+	columns := td.VisibleColumns()
+
+	for vy, y := range td.VisibleRows() {
+
+		if vy >= int(td.Table.Card.DisplayRect.H/32) {
+			break
+		}
+
+		for vx, x := range columns {
+
+			if vx >= int(td.Table.Card.DisplayRect.W/32) {
+				break
+			}
+
+			cell := td.Data[y][x]
+
+			if cell.Type == TableCellTypeCheckbox {
+				continue
+			}
+
+			result := ev.Result(x, y)
+			rect := cell.Button.Rect
+
+			runes := []rune(result.Text)
+			fitted := runes
+			for len(fitted) > 0 && globals.TextRenderer.MeasureText(fitted, tableCellTextSize).X > rect.W-4 {
+				fitted = fitted[:len(fitted)-1]
+			}
+
+			if mousePos.Inside(rect) && len(fitted) < len(runes) {
+				hoveredText = result.Text
+				hoveredCell = cell
+			}
+
+			textHeight := globals.TextRenderer.MeasureText([]rune{'0'}, tableCellTextSize).Y
+
+			pos := Vector{rect.X + 2, rect.Y + (rect.H-textHeight)/2}
+			alignment := AlignLeft
+
+			// Numbers and dates line up on the right, like in a spreadsheet
+			if result.Valid {
+				pos.X = rect.X + rect.W - 2
+				alignment = AlignRight
+			}
+
+			color := ColorWhite
+			if result.Err != nil {
+				color = NewColor(255, 120, 120, 255)
+			}
+
+			globals.TextRenderer.QuickRenderText(string(fitted), camera.TranslatePoint(pos), tableCellTextSize*camera.Zoom, color, ColorBlack, alignment)
+
+		}
+
+	}
+
+	if active := td.ActiveCell; active != nil && td.Table.Card.selected && globals.MenuSystem.Get("table settings menu").Opened {
+		if x, y, ok := td.CellPosition(active); ok && !td.ColumnHidden(x) && !td.RowHidden(y) {
+			rect := camera.TranslateRect(active.Button.Rect)
+			ThickRect(int32(rect.X-2), int32(rect.Y-2), int32(rect.W+4), int32(rect.H+4), 2, getThemeColor(GUIFontColor))
+		}
+	}
+
+	if hoveredCell != nil {
+		pos := camera.TranslatePoint(Vector{hoveredCell.Button.Rect.X, hoveredCell.Button.Rect.Y - 28})
+		DrawLabel(pos, 1, hoveredText, getThemeColor(GUIMenuColor))
+	}
+
+}
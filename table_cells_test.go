@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestTable creates a table of the given cells' text (without any GUI), typing each cell as the table
+// settings menu would.
+func newTestTable(rows ...[]string) *TableData {
+
+	td := &TableData{Height: len(rows), ValueDisplayMode: ValueDisplayModeCheck}
+
+	for _, row := range rows {
+
+		td.Width = max(td.Width, len(row))
+
+		cells := []*TableDataContents{}
+		for _, text := range row {
+			cells = append(cells, &TableDataContents{TableData: td, Text: text, Type: inferTableCellType(text)})
+		}
+
+		td.Data = append(td.Data, cells)
+
+	}
+
+	return td
+
+}
+
+func TestTableFormulaResults(t *testing.T) {
+
+	td := newTestTable(
+		[]string{"1", "2", "=A1+B1*2"},
+		[]string{"3", "4", "=SUM(A1:B2)"},
+		[]string{"=AVG(A:A)", "=COUNT(1:2)", "=MAX(A1,B2,10)"},
+	)
+
+	ev := newTableEvaluator(td)
+
+	for _, test := range []struct {
+		X, Y  int
+		Value float64
+	}{
+		{2, 0, 5},
+		{2, 1, 10},
+		{0, 2, 2},
+		{1, 2, 6},
+		{2, 2, 10},
+	} {
+
+		result := ev.Result(test.X, test.Y)
+
+		if result.Err != nil {
+			t.Errorf("%s: unexpected error: %s", TableCellName(test.X, test.Y), result.Err)
+		} else if result.Value != test.Value {
+			t.Errorf("%s: got %v, expected %v", TableCellName(test.X, test.Y), result.Value, test.Value)
+		}
+
+	}
+
+}
+
+func TestTableFormulaErrors(t *testing.T) {
+
+	for _, test := range []struct {
+		Formula string
+		Err     string
+	}{
+		{"=", "empty"},
+		{"=(", "ends too early"},
+		{"=(1", "missing"},
+		{"=1/0", "division by zero"},
+		{"=1+", "ends too early"},
+		{"=ZZZZZZZZZZZZZZ1", "outside of the table"},
+		{"=SUM(ZZZZZZZZZZZZZZ1:A1)", "outside of the table"},
+		{"=SUM(A1:ZZZZZZZZZZZZZZZZZZZZ)", "outside of the table"},
+		{"=C1", "outside of the table"},
+		{"=A3", "outside of the table"},
+		{"=A0", "outside of the table"},
+		{"=A99999999999999999999", "outside of the table"},
+		{"=SUM(1:5)", "outside of the table"},
+		{"=ÉA1", "isn't a column"},
+		{"=NOPE(A1)", "no NOPE function"},
+		{"=A1 B1", "unexpected"},
+		{"=B2", "circular reference"},
+	} {
+
+		td := newTestTable(
+			[]string{"1", "2"},
+			[]string{test.Formula, "=A2"},
+		)
+
+		result := newTableEvaluator(td).Result(0, 1)
+
+		if result.Err == nil {
+			t.Errorf("%s: expected an error, got %v", test.Formula, result.Value)
+		} else if !strings.Contains(result.Err.Error(), test.Err) {
+			t.Errorf("%s: expected an error containing %q, got %q", test.Formula, test.Err, result.Err)
+		}
+
+	}
+
+}
+
+func TestTableSortFormulaRows(t *testing.T) {
+
+	// The first row moves to the end, and the others move up
+	newRows := []int{2, 0, 1}
+
+	for _, test := range []struct {
+		Formula  string
+		Expected string
+	}{
+		{"=A1+B2", "=A3+B1"},
+		{"=SUM(A1:C1)", "=SUM(A3:C3)"},
+		{"=COUNT(1 : 3) + 1.5", "=COUNT(3 : 2) + 1.5"},
+		{"=AVG(B:B)*2", "=AVG(B:B)*2"},
+		{"=A4+10", "=A4+10"},
+	} {
+		if remapped := remapFormulaRows(test.Formula, newRows); remapped != test.Expected {
+			t.Errorf("%s: got %q, expected %q", test.Formula, remapped, test.Expected)
+		}
+	}
+
+}
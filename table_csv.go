@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ncruces/zenity"
+	"github.com/tidwall/sjson"
+	"golang.design/x/clipboard"
+)
+
+// Tables are imported from and exported to CSV and TSV files with their column headings along the first row and
+// their row headings down the first column, so they line up with how they look in a spreadsheet.
+
+// tableCheckboxText is how the values of checkbox cells are written out in each display mode.
+var tableCheckboxText = map[int][]string{
+	ValueDisplayModeCheck:  {"", "✓", "✗"},
+	ValueDisplayModeLetter: {"S", "A", "B", "C", "D", "E", "F"},
+	ValueDisplayModeNumber: {"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+}
+
+// tableCheckboxValue returns the checkbox value that the given text stands for in the given display mode, and false
+// if it doesn't stand for one.
+func tableCheckboxValue(mode int, text string) (int, bool) {
+
+	text = strings.TrimSpace(text)
+
+	if text == "" {
+		return 0, true
+	}
+
+	if mode == ValueDisplayModeCheck {
+
+		switch strings.ToLower(text) {
+		case "false", "no", "n", "☐":
+			return 0, true
+		case "true", "yes", "y", "x", "done", "✓", "✔", "☑":
+			return 1, true
+		case "n/a", "-", "✗", "✘", "☒":
+			return 2, true
+		}
+
+		return 0, false
+
+	}
+
+	for value, valueText := range tableCheckboxText[mode] {
+		if strings.EqualFold(text, valueText) {
+			return value, true
+		}
+	}
+
+	return 0, false
+
+}
+
+// Records returns the table's contents as rows of text, with the headings along the first row and column. Formulas are
+// written out as their results.
+func (td *TableData) Records() [][]string {
+
+	ev := newTableEvaluator(td)
+
+	header := []string{""}
+	for x := 0; x < td.Width; x++ {
+		header = append(header, td.ColumnHeadings[x].Label.TextAsString())
+	}
+
+	records := [][]string{header}
+
+	for y := 0; y < td.Height; y++ {
+
+		record := []string{td.RowHeadings[y].Label.TextAsString()}
+
+		for x := 0; x < td.Width; x++ {
+
+			cell := td.Data[y][x]
+			text := cell.Text
+
+			if cell.Type == TableCellTypeCheckbox {
+
+				if valueTexts := tableCheckboxText[td.ValueDisplayMode]; cell.Value >= 0 && cell.Value < len(valueTexts) {
+					text = valueTexts[cell.Value]
+				}
+
+			} else if cell.IsFormula() {
+
+				result := ev.Result(x, y)
+
+				if result.Err != nil {
+					text = "#ERROR"
+				} else if cell.Type == TableCellTypeDate {
+					text = formatTableDate(result.Value)
+				} else {
+					text = formatTableNumber(result.Value)
+				}
+
+			}
+
+			record = append(record, text)
+
+		}
+
+		records = append(records, record)
+
+	}
+
+	return records
+
+}
+
+// ImportRecords replaces the table's contents with the given rows of text, using the first row and column as the
+// headings and resizing the table to fit. Columns made up entirely of text standing for checkbox values in the table's
+// display mode (like "✓" or "TRUE" for checkmarks) become checkboxes; anything else becomes text, number or date cells,
+// so that a column of numbers or words isn't partly turned into checkboxes.
+func (td *TableData) ImportRecords(records [][]string) error {
+
+	if len(records) == 0 {
+		return errors.New("there's nothing to import")
+	}
+
+	w := 0
+	for _, record := range records {
+		w = max(w, len(record)-1)
+	}
+
+	h := len(records) - 1
+
+	maxCells := int(min(4096, SmallestRendererMaxTextureSize()) / int32(globals.GridSize))
+
+	if w > maxCells || h > maxCells {
+		globals.EventLog.Log("Warning: tables can be at most %d cells across, so some of the imported data has been cut off.", true, maxCells)
+		w = min(w, maxCells)
+		h = min(h, maxCells)
+	}
+
+	w = max(w, 1)
+	h = max(h, 1)
+
+	field := func(record []string, i int) string {
+		if i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	columns := []string{}
+	for x := 0; x < w; x++ {
+		heading := field(records[0], x+1)
+		if heading == "" {
+			heading = "Col " + strconv.Itoa(x+1)
+		}
+		columns = append(columns, heading)
+	}
+
+	cell := func(x, y int) string {
+		if y+1 < len(records) {
+			return strings.TrimSpace(field(records[y+1], x+1))
+		}
+		return ""
+	}
+
+	checkboxColumns := []bool{}
+
+	for x := 0; x < w; x++ {
+
+		checkboxes := true
+
+		for y := 0; y < h && checkboxes; y++ {
+			_, checkboxes = tableCheckboxValue(td.ValueDisplayMode, cell(x, y))
+		}
+
+		checkboxColumns = append(checkboxColumns, checkboxes)
+
+	}
+
+	rows := []string{}
+	values := [][]int{}
+	types := [][]int{}
+	text := [][]string{}
+
+	for y := 0; y < h; y++ {
+
+		record := []string{}
+		if y+1 < len(records) {
+			record = records[y+1]
+		}
+
+		heading := field(record, 0)
+		if heading == "" {
+			heading = "Row " + strconv.Itoa(y+1)
+		}
+		rows = append(rows, heading)
+
+		values = append(values, []int{})
+		types = append(types, []int{})
+		text = append(text, []string{})
+
+		for x := 0; x < w; x++ {
+
+			cellText := cell(x, y)
+
+			if checkboxColumns[x] {
+				value, _ := tableCheckboxValue(td.ValueDisplayMode, cellText)
+				values[y] = append(values[y], value)
+				types[y] = append(types[y], TableCellTypeCheckbox)
+				text[y] = append(text[y], "")
+			} else {
+				values[y] = append(values[y], 0)
+				types[y] = append(types[y], inferTableCellType(cellText))
+				text[y] = append(text[y], cellText)
+			}
+
+		}
+
+	}
+
+	data, _ := sjson.Set("{}", "contents", values)
+	data, _ = sjson.Set(data, "rows", rows)
+	data, _ = sjson.Set(data, "columns", columns)
+	data, _ = sjson.Set(data, "width", w)
+	data, _ = sjson.Set(data, "height", h)
+	data, _ = sjson.Set(data, "mode", td.ValueDisplayMode)
+	data, _ = sjson.Set(data, "types", types)
+	data, _ = sjson.Set(data, "text", text)
+
+	td.Deserialize(data)
+	td.ActiveCell = nil
+	td.Table.Card.Recreate(float32(w)*globals.GridSize, float32(h)*globals.GridSize)
+	td.ForceUndoStateCreation()
+
+	return nil
+
+}
+
+// ParseDelimited reads CSV or TSV text, using the given separator.
+func ParseDelimited(data []byte, separator rune) ([][]string, error) {
+
+	data = bytes.TrimPrefix(data, []byte("\ufeff")) // Spreadsheets often start their CSV files with a byte order mark
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return reader.ReadAll()
+
+}
+
+// delimiterForFile returns the separator to use for a CSV or TSV file; files that are neither are guessed at from their
+// first line.
+func delimiterForFile(path string, data []byte) rune {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".tab":
+		return '\t'
+	case ".csv":
+		return ','
+	}
+
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.ContainsRune(firstLine, '\t') {
+		return '\t'
+	}
+
+	return ','
+
+}
+
+// ExportDelimited writes the table out to a CSV or TSV file.
+func (td *TableData) ExportDelimited(path string, separator rune) error {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = separator
+
+	return writer.WriteAll(td.Records())
+
+}
+
+// CopyAsTSV copies the table to the clipboard as tab-separated text, ready to paste into a spreadsheet.
+func (td *TableData) CopyAsTSV() {
+
+	buffer := &bytes.Buffer{}
+
+	writer := csv.NewWriter(buffer)
+	writer.Comma = '\t'
+	writer.WriteAll(td.Records())
+
+	clipboard.Write(clipboard.FmtText, buffer.Bytes())
+
+	globals.EventLog.Log("Table copied to the clipboard as tab-separated text.", false)
+
+}
+
+// PasteTSV pastes tab-separated text, like cells copied from a spreadsheet, into the table's cells starting from the
+// active cell (or the top-left cell if there isn't one), growing the table to fit. Unlike importing, the text is all
+// cell contents, without headings. Checkbox cells take text standing for checkbox values as their value, and change to
+// suit any other text, like when typing into them.
+func (td *TableData) PasteTSV(text string) {
+
+	records, err := ParseDelimited([]byte(text), '\t')
+
+	if err == nil && len(records) == 0 {
+		err = errors.New("there's nothing to paste")
+	}
+
+	if err != nil {
+		globals.EventLog.Log("Error pasting into table: %s", true, err.Error())
+		return
+	}
+
+	startX, startY, _ := td.CellPosition(td.ActiveCell)
+
+	w := 0
+	for _, record := range records {
+		w = max(w, len(record))
+	}
+
+	h := len(records)
+
+	maxCells := int(min(4096, SmallestRendererMaxTextureSize()) / int32(globals.GridSize))
+
+	if startX+w > maxCells || startY+h > maxCells {
+		globals.EventLog.Log("Warning: tables can be at most %d cells across, so some of the pasted data has been cut off.", true, maxCells)
+		w = min(w, maxCells-startX)
+		h = min(h, maxCells-startY)
+	}
+
+	if startX+w > td.Width || startY+h > td.Height {
+		td.Resize(max(td.Width, startX+w), max(td.Height, startY+h))
+		td.Table.Card.Recreate(float32(td.Width)*globals.GridSize, float32(td.Height)*globals.GridSize)
+	}
+
+	for y := 0; y < h; y++ {
+
+		for x := 0; x < w && x < len(records[y]); x++ {
+
+			cell := td.Data[startY+y][startX+x]
+			cellText := strings.TrimSpace(records[y][x])
+
+			if cell.Type == TableCellTypeCheckbox {
+
+				if value, ok := tableCheckboxValue(td.ValueDisplayMode, cellText); ok {
+					cell.Value = value
+					continue
+				}
+
+				cell.Type = inferTableCellType(cellText)
+
+			}
+
+			cell.Text = cellText
+
+		}
+
+	}
+
+	td.ForceUndoStateCreation()
+
+	globals.EventLog.Log("Pasted %dx%d cells from the clipboard.", false, w, h)
+
+}
+
+// ExportAs prompts for a CSV or TSV file to export the table to.
+func (tc *TableContents) ExportAs() {
+
+	filename, err := zenity.SelectFileSave(zenity.Title("Export Table..."), zenity.ConfirmOverwrite(),
+		zenity.FileFilter{Name: "Comma-Separated Values (*.csv)", Patterns: []string{"*.csv"}},
+		zenity.FileFilter{Name: "Tab-Separated Values (*.tsv)", Patterns: []string{"*.tsv"}},
+	)
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	separator := ','
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tsv", ".tab":
+		separator = '\t'
+	case ".csv":
+	default:
+		filename += ".csv"
+	}
+
+	if err := tc.TableData.ExportDelimited(filename, separator); err != nil {
+		globals.EventLog.Log("Error exporting table: %s", true, err.Error())
+		return
+	}
+
+	globals.EventLog.Log("Table exported to %s.", false, filename)
+
+}
+
+// ImportFrom prompts for a CSV or TSV file to replace the table's contents with.
+func (tc *TableContents) ImportFrom() {
+
+	filename, err := zenity.SelectFile(zenity.Title("Import Table..."),
+		zenity.FileFilter{Name: "CSV or TSV File (*.csv / *.tsv)", Patterns: []string{"*.csv", "*.tsv", "*.tab", "*.txt"}},
+	)
+
+	if err == zenity.ErrCanceled {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	data, err := os.ReadFile(filename)
+
+	var records [][]string
+
+	if err == nil {
+		records, err = ParseDelimited(data, delimiterForFile(filename, data))
+	}
+
+	if err == nil {
+		err = tc.TableData.ImportRecords(records)
+	}
+
+	if err != nil {
+		globals.EventLog.Log("Error importing table: %s", true, err.Error())
+		return
+	}
+
+	globals.EventLog.Log("Table imported from %s.", false, filename)
+
+}
+
+// SelectedTable returns the first selected Table card on the page, or nil if there isn't one.
+func (page *Page) SelectedTable() *TableContents {
+
+	for _, card := range page.Selection.AsSlice() {
+		if card.ContentType == ContentTypeTable {
+			return card.Contents.(*TableContents)
+		}
+	}
+
+	return nil
+
+}
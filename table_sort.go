@@ -0,0 +1,403 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Table filters hide rows or columns from view without removing them; a filtered table's card shrinks to fit what it
+// shows. Filters are stored in the card's properties.
+
+const (
+	TableFilterShowAll        = "Show All"
+	TableFilterHideComplete   = "Hide Complete"
+	TableFilterHideIncomplete = "Hide Incomplete"
+	TableFilterHideEmpty      = "Hide Empty"
+)
+
+var TableFilterOptions = []string{TableFilterShowAll, TableFilterHideComplete, TableFilterHideIncomplete, TableFilterHideEmpty}
+
+const (
+	TablePropertyRowFilter    = "rowfilter"
+	TablePropertyColumnFilter = "columnfilter"
+	TablePropertyFilterText   = "filtertext" // Only rows containing this text are shown
+)
+
+func (td *TableData) filterSetting(name string) string {
+	if prop := td.Table.Card.Properties.GetIfExists(name); prop != nil {
+		return prop.AsString()
+	}
+	return ""
+}
+
+// Filtered returns if any of the table's filters are set.
+func (td *TableData) Filtered() bool {
+	return !td.filterOff(td.filterSetting(TablePropertyRowFilter)) || !td.filterOff(td.filterSetting(TablePropertyColumnFilter)) || strings.TrimSpace(td.filterSetting(TablePropertyFilterText)) != ""
+}
+
+func (td *TableData) filterOff(filter string) bool {
+	return filter == "" || filter == TableFilterShowAll
+}
+
+// ClearFilters turns off all of the table's filters.
+func (td *TableData) ClearFilters() {
+
+	for _, name := range []string{TablePropertyRowFilter, TablePropertyColumnFilter, TablePropertyFilterText} {
+		if prop := td.Table.Card.Properties.GetIfExists(name); prop != nil && prop.AsString() != "" {
+			prop.Set("")
+		}
+	}
+
+}
+
+// updateFilters works out which rows and columns the table's filters hide, and sizes the card to fit the rest. The
+// hidden rows and columns are only worked out again once the table or its filters change.
+func (td *TableData) updateFilters() {
+
+	rowFilter := td.filterSetting(TablePropertyRowFilter)
+	columnFilter := td.filterSetting(TablePropertyColumnFilter)
+	filterText := strings.ToLower(strings.TrimSpace(td.filterSetting(TablePropertyFilterText)))
+
+	filtered := !td.filterOff(rowFilter) || !td.filterOff(columnFilter) || filterText != ""
+	settings := rowFilter + "\n" + columnFilter + "\n" + filterText
+	wasFiltered := false
+
+	if td.filtersOutdated || td.filterSettings != settings {
+
+		wasFiltered = len(td.hiddenRows) > 0 || len(td.hiddenColumns) > 0
+
+		td.filtersOutdated = false
+		td.filterSettings = settings
+
+		td.hiddenRows = td.hiddenRows[:0]
+		td.hiddenColumns = td.hiddenColumns[:0]
+
+		if filtered {
+
+			ev := newTableEvaluator(td)
+
+			for y := 0; y < td.Height; y++ {
+				td.hiddenRows = append(td.hiddenRows, td.filteredOut(ev, rowFilter, y, false) || (filterText != "" && !td.rowContains(ev, y, filterText)))
+			}
+
+			for x := 0; x < td.Width; x++ {
+				td.hiddenColumns = append(td.hiddenColumns, td.filteredOut(ev, columnFilter, x, true))
+			}
+
+		}
+
+	}
+
+	// Once the filters are turned off, the card goes back to covering the whole table
+	if (filtered || wasFiltered) && td.Table.Card.Resizing == "" {
+
+		w := float32(max(len(td.VisibleColumns()), 1)) * globals.GridSize
+		h := float32(max(len(td.VisibleRows()), 1)) * globals.GridSize
+
+		if td.Table.Card.Rect.W != w || td.Table.Card.Rect.H != h {
+			td.Table.Card.Recreate(w, h)
+			td.Table.Card.LockPosition()
+		}
+
+	}
+
+}
+
+// filteredOut returns if the given filter hides a row (or column).
+func (td *TableData) filteredOut(ev *tableEvaluator, filter string, index int, column bool) bool {
+
+	switch filter {
+
+	case TableFilterHideComplete:
+		return td.rowCompletion(ev, index, column) >= 1
+
+	case TableFilterHideIncomplete:
+		completion, max, _ := td.rowCompletionCounts(ev, index, column)
+		return max > 0 && completion < max
+
+	case TableFilterHideEmpty:
+
+		count := td.Width
+		if column {
+			count = td.Height
+		}
+
+		for i := 0; i < count; i++ {
+
+			cell := td.Data[index][i]
+			if column {
+				cell = td.Data[i][index]
+			}
+
+			if cell.Value != 0 || cell.Text != "" {
+				return false
+			}
+
+		}
+
+		return true
+
+	}
+
+	return false
+
+}
+
+// rowContains returns if a row's heading or any of its cells contain the given (lowercase) text.
+func (td *TableData) rowContains(ev *tableEvaluator, y int, text string) bool {
+
+	if strings.Contains(strings.ToLower(td.RowHeadings[y].Label.TextAsString()), text) {
+		return true
+	}
+
+	for x := 0; x < td.Width; x++ {
+		if td.Data[y][x].Type != TableCellTypeCheckbox && strings.Contains(strings.ToLower(ev.Result(x, y).Text), text) {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+func (td *TableData) RowHidden(y int) bool {
+	return y < len(td.hiddenRows) && td.hiddenRows[y]
+}
+
+func (td *TableData) ColumnHidden(x int) bool {
+	return x < len(td.hiddenColumns) && td.hiddenColumns[x]
+}
+
+// VisibleRows returns the indices of the rows that aren't hidden by filters.
+func (td *TableData) VisibleRows() []int {
+
+	rows := []int{}
+
+	for y := 0; y < td.Height; y++ {
+		if !td.RowHidden(y) {
+			rows = append(rows, y)
+		}
+	}
+
+	return rows
+
+}
+
+// VisibleColumns returns the indices of the columns that aren't hidden by filters.
+func (td *TableData) VisibleColumns() []int {
+
+	columns := []int{}
+
+	for x := 0; x < td.Width; x++ {
+		if !td.ColumnHidden(x) {
+			columns = append(columns, x)
+		}
+	}
+
+	return columns
+
+}
+
+// ResizeVisible resizes the table to match its card being resized to the given number of cells. As a filtered table's
+// card only covers the rows and columns it shows, its filters are cleared, and it grows or shrinks by as much as the
+// card did.
+func (td *TableData) ResizeVisible(w, h int) {
+
+	columns := max(len(td.VisibleColumns()), 1)
+	rows := max(len(td.VisibleRows()), 1)
+
+	if columns == td.Width && rows == td.Height {
+		td.Resize(w, h)
+		return
+	}
+
+	w = max(td.Width+w-columns, 1)
+	h = max(td.Height+h-rows, 1)
+
+	td.ClearFilters()
+	td.Resize(w, h)
+	td.Table.Card.Recreate(float32(w)*globals.GridSize, float32(h)*globals.GridSize)
+	td.Table.Card.LockPosition()
+
+	globals.EventLog.Log("The table's filters have been cleared so it could be resized.", false)
+
+}
+
+// sortVisibleHeadings sorts the headings that aren't hidden amongst themselves, leaving hidden ones where they are.
+func (td *TableData) sortVisibleHeadings(headings []*DraggableLabel, hidden []bool, less func(a, b *DraggableLabel) bool) {
+
+	slots := []int{}
+	visible := []*DraggableLabel{}
+
+	for i, heading := range headings {
+		if i >= len(hidden) || !hidden[i] {
+			slots = append(slots, i)
+			visible = append(visible, heading)
+		}
+	}
+
+	sort.SliceStable(visible, func(i, j int) bool { return less(visible[i], visible[j]) })
+
+	for i, slot := range slots {
+		headings[slot] = visible[i]
+	}
+
+}
+
+// tableSortKey is what a row is sorted by; numbers sort before text, and empty cells always sort last.
+type tableSortKey struct {
+	Kind   int // 0 for numbers, 1 for text, 2 for empty cells
+	Number float64
+	Text   string
+}
+
+// SortRows sorts the table's rows (along with their headings) by the values in the given column, or by how complete
+// they are if column is -1. Row references in formulas are changed to follow the rows they referred to.
+func (td *TableData) SortRows(column int, descending bool) {
+
+	ev := newTableEvaluator(td)
+	keys := make([]tableSortKey, td.Height)
+
+	for y := range keys {
+
+		if column < 0 {
+			keys[y].Number = float64(td.rowCompletion(ev, y, false))
+			continue
+		}
+
+		cell := td.Data[y][column]
+
+		if cell.Type == TableCellTypeCheckbox {
+			keys[y].Number = float64(cell.Value)
+		} else if result := ev.Result(column, y); result.Valid {
+			keys[y].Number = result.Value
+		} else if result.Text != "" {
+			keys[y].Kind = 1
+			keys[y].Text = strings.ToLower(result.Text)
+		} else {
+			keys[y].Kind = 2
+		}
+
+	}
+
+	order := make([]int, td.Height)
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+
+		a, b := keys[order[i]], keys[order[j]]
+
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+
+		if descending {
+			a, b = b, a
+		}
+
+		if a.Kind == 0 {
+			return a.Number < b.Number
+		}
+
+		return a.Text < b.Text
+
+	})
+
+	data := append([][]*TableDataContents{}, td.Data...)
+	headings := append([]*DraggableLabel{}, td.RowHeadings...)
+
+	newRows := make([]int, td.Height)
+
+	for i, from := range order {
+		data[i] = td.Data[from]
+		headings[i] = td.RowHeadings[from]
+		newRows[from] = i
+	}
+
+	td.Data = data
+	td.RowHeadings = headings
+
+	for _, row := range td.Data {
+		for _, cell := range row {
+			if cell.IsFormula() {
+				cell.Text = remapFormulaRows(cell.Text, newRows)
+			}
+		}
+	}
+
+	td.ForceUndoStateCreation()
+
+}
+
+// remapFormulaRows returns the formula with the rows it refers to (in cells like "B3" and row ranges like "2:4")
+// changed to the rows they've moved to; newRows holds the new index of each row. Rows outside of the table are left
+// alone.
+func remapFormulaRows(formula string, newRows []int) string {
+
+	text := []rune(formula)
+	out := []rune{}
+
+	remapRow := func(digits []rune) []rune {
+		if row, err := strconv.Atoi(string(digits)); err == nil && row >= 1 && row <= len(newRows) {
+			return []rune(strconv.Itoa(newRows[row-1] + 1))
+		}
+		return digits
+	}
+
+	// nextTo returns the next character that isn't a space, going from the given index in the given direction
+	nextTo := func(index, dir int) rune {
+		for ; index >= 0 && index < len(text); index += dir {
+			if !unicode.IsSpace(text[index]) {
+				return text[index]
+			}
+		}
+		return 0
+	}
+
+	for i := 0; i < len(text); {
+
+		start := i
+
+		if unicode.IsLetter(text[i]) {
+
+			// Column letters directly followed by a row number are a cell
+			for i < len(text) && unicode.IsLetter(text[i]) {
+				i++
+			}
+
+			out = append(out, text[start:i]...)
+
+			digitStart := i
+			for i < len(text) && unicode.IsDigit(text[i]) {
+				i++
+			}
+
+			out = append(out, remapRow(text[digitStart:i])...)
+
+		} else if unicode.IsDigit(text[i]) || text[i] == '.' {
+
+			for i < len(text) && (unicode.IsDigit(text[i]) || text[i] == '.') {
+				i++
+			}
+
+			// A number on its own is only a row when it's one end of a range of rows
+			if nextTo(start-1, -1) == ':' || nextTo(i, 1) == ':' {
+				out = append(out, remapRow(text[start:i])...)
+			} else {
+				out = append(out, text[start:i]...)
+			}
+
+		} else {
+			out = append(out, text[i])
+			i++
+		}
+
+	}
+
+	return string(out)
+
+}
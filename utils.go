@@ -361,6 +361,24 @@ func formatTime(t time.Duration, showMilliseconds bool) string {
 
 }
 
+func formatFileSize(size int64) string {
+
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(size)
+	unit := 0
+
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+
+}
+
 func WriteImageToTemp(clipboardImg []byte) (string, error) {
 
 	var file *os.File